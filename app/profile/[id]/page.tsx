@@ -7,6 +7,7 @@ import { useRouter, useParams } from "next/navigation";
 import Link from "next/link";
 import { useAuth } from "@/contexts/AuthContext";
 import { fetchProfile } from "@/lib/profile";
+import { fetchPostsPage } from "@/lib/posts";
 import { checkSession } from "@/lib/auth";
 import { formatDateTime, formatDateOnly } from "@/utils/formatDate";
 import { Avatar, AvatarFallback, AvatarImage } from "@/components/ui/avatar";
@@ -64,6 +65,8 @@ export default function UserProfilePage() {
   const [followers, setFollowers] = useState<FollowerData[]>([]);
   const [following, setFollowing] = useState<FollowerData[]>([]);
   const [posts, setPosts] = useState<Post[]>([]);
+  const [nextCursor, setNextCursor] = useState<string | null>(null);
+  const [loadingMore, setLoadingMore] = useState(false);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState("");
   const [isAuthenticated, setIsAuthenticated] = useState<boolean | null>(null);
@@ -165,18 +168,11 @@ export default function UserProfilePage() {
           (profile && (isFollowing || profile.profile.user_uuid === userUUID))
         ) {
           try {
-            const myPostsRes = await fetch(
-              `${API_URL}/api/getprofileposts/${userUUID}`,
-              {
-                method: "GET",
-                credentials: "include",
-                headers: { "Content-Type": "application/json" },
-              }
+            const page = await fetchPostsPage(
+              `${API_URL}/api/getprofileposts/${userUUID}`
             );
-            if (myPostsRes.ok) {
-              const posts = await myPostsRes.json();
-              setPosts(posts);
-            }
+            setPosts(page.posts);
+            setNextCursor(page.nextCursor);
           } catch (err) {
             // console.warn("Posts fetch error:", err);
             setPosts([]);
@@ -195,6 +191,20 @@ export default function UserProfilePage() {
     verifySessionAndFetch();
   }, [userUUID, currentUser]);
 
+  const loadMorePosts = async () => {
+    if (!nextCursor) return;
+    setLoadingMore(true);
+    try {
+      const page = await fetchPostsPage(`${API_URL}/api/getprofileposts/${userUUID}`, nextCursor);
+      setPosts((prev) => [...prev, ...page.posts]);
+      setNextCursor(page.nextCursor);
+    } catch (err) {
+      console.error("Failed to fetch more posts:", err);
+    } finally {
+      setLoadingMore(false);
+    }
+  };
+
   const handleFollowToggle = async () => {
     if (!currentUser) {
       router.push("/login");
@@ -552,13 +562,20 @@ export default function UserProfilePage() {
                         <div className="flex gap-4 text-muted-foreground">
                           <span className="flex items-center gap-1 text-xs hover:text-foreground">
                             <MessageCircle className="h-3 w-3" />{" "}
-                            {post.comments ? post.comments.length : 0}
+                            {post.comment_count || 0}
                           </span>
                         </div>
                       </CardContent>
                     </Card>
                   ))
                 )}
+                  {nextCursor && (
+                    <div className="flex justify-center">
+                      <Button variant="outline" onClick={loadMorePosts} disabled={loadingMore}>
+                        {loadingMore ? "Loading..." : "Load more"}
+                      </Button>
+                    </div>
+                  )}
               </CardContent>
             </Card>
           </div>
@@ -7,6 +7,7 @@ import { useRouter } from "next/navigation";
 import Link from "next/link";
 import { useAuth } from "@/contexts/AuthContext";
 import { fetchProfile } from "@/lib/profile";
+import { fetchPostsPage } from "@/lib/posts";
 import { checkSession } from "@/lib/auth";
 import { formatDateTime, formatDateOnly } from "@/utils/formatDate";
 import { Avatar, AvatarFallback, AvatarImage } from "@/components/ui/avatar";
@@ -63,6 +64,8 @@ export default function MyProfilePage() {
   const [followers, setFollowers] = useState<FollowerData[]>([]);
   const [following, setFollowing] = useState<FollowerData[]>([]);
   const [posts, setPosts] = useState<Post[]>([]);
+  const [nextCursor, setNextCursor] = useState<string | null>(null);
+  const [loadingMore, setLoadingMore] = useState(false);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState("");
   const [privacy, setPrivacy] = useState("public");
@@ -132,17 +135,11 @@ export default function MyProfilePage() {
         }
 
         // Fetch posts
-        const myPostsRes = await fetch(
-          `${API_URL}/api/getprofileposts/${currentUser.user_uuid}`,
-          {
-            method: "GET",
-            credentials: "include",
-            headers: { "Content-Type": "application/json" },
-          }
+        const myPosts = await fetchPostsPage(
+          `${API_URL}/api/getprofileposts/${currentUser.user_uuid}`
         );
-        if (!myPostsRes.ok) throw new Error("Failed to getprofileposts");
-        const myPostsData = await myPostsRes.json();
-        setPosts(myPostsData);
+        setPosts(myPosts.posts);
+        setNextCursor(myPosts.nextCursor);
       } catch (err) {
         setIsAuthenticated(false);
         setError((err as Error).message || "Failed to load profile");
@@ -157,6 +154,20 @@ export default function MyProfilePage() {
     verifySessionAndFetch();
   }, [currentUser]);
 
+  const loadMorePosts = async () => {
+    if (!nextCursor) return;
+    setLoadingMore(true);
+    try {
+      const page = await fetchPostsPage(`${API_URL}/api/getprofileposts/${currentUser?.user_uuid}`, nextCursor);
+      setPosts((prev) => [...prev, ...page.posts]);
+      setNextCursor(page.nextCursor);
+    } catch (err) {
+      console.error("Failed to fetch more posts:", err);
+    } finally {
+      setLoadingMore(false);
+    }
+  };
+
   const handlePrivacyToggle = async (newPrivacyValue?: string) => {
     if (!currentUser) {
       router.push("/login");
@@ -358,7 +369,7 @@ export default function MyProfilePage() {
                   <div className="flex gap-4 text-muted-foreground">
                     <span className="flex items-center gap-1 text-xs hover:text-foreground">
                       <MessageCircle className="h-3 w-3" />
-                      {post.comments?.length || 0}
+                      {post.comment_count || 0}
                     </span>
                   </div>
                 </CardContent>
@@ -369,6 +380,13 @@ export default function MyProfilePage() {
               No posts yet.
             </div>
           )}
+          {nextCursor && (
+            <div className="flex justify-center">
+              <Button variant="outline" onClick={loadMorePosts} disabled={loadingMore}>
+                {loadingMore ? "Loading..." : "Load more"}
+              </Button>
+            </div>
+          )}
         </TabsContent>
 
         <TabsContent value="followers" className="space-y-4">
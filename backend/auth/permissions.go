@@ -0,0 +1,85 @@
+package auth
+
+// Roles stored in users.role
+const (
+	RoleUser           = "user"
+	RoleGroupModerator = "group_moderator"
+	RoleAdmin          = "admin"
+)
+
+// ValidRole reports whether role is one users.role accepts
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Permission names an action that depends on who is asking
+type Permission string
+
+const (
+	// PermAdminAccess covers the admin endpoints: login attempts, account deletions, access tokens with the admin scope
+	PermAdminAccess Permission = "admin.access"
+	// PermManageRoles covers changing roles and assigning group moderators
+	PermManageRoles Permission = "users.manage_roles"
+	// PermManageGroup covers join requests and membership decisions for a group
+	PermManageGroup Permission = "groups.manage"
+	// PermModerateContent covers editing and removing other users' posts, comments and events
+	PermModerateContent Permission = "content.moderate"
+)
+
+// Resource is what a permission is checked against. Handlers fill in what they
+// know about it; the zero value stands for the site as a whole.
+type Resource struct {
+	OwnerID        int  // author of the content
+	GroupCreatorID int  // creator of the group the resource belongs to
+	GroupModerator bool // the principal is assigned to moderate that group
+}
+
+// scope is how far a role's grant of a permission reaches
+type scope int
+
+const (
+	scopeOwn      scope = iota + 1 // resources the principal authored, or in groups they created
+	scopeAssigned                  // scopeOwn plus groups the principal is assigned to moderate
+	scopeAll                       // any resource
+)
+
+var rolePermissions = map[string]map[Permission]scope{
+	RoleUser: {
+		PermManageGroup:     scopeOwn,
+		PermModerateContent: scopeOwn,
+	},
+	RoleGroupModerator: {
+		PermManageGroup:     scopeAssigned,
+		PermModerateContent: scopeAssigned,
+	},
+	RoleAdmin: {
+		PermAdminAccess:     scopeAll,
+		PermManageRoles:     scopeAll,
+		PermManageGroup:     scopeAll,
+		PermModerateContent: scopeAll,
+	},
+}
+
+// Can reports whether the principal may perform the action on the resource
+func Can(p *Principal, perm Permission, res Resource) bool {
+	if p == nil {
+		return false
+	}
+	switch rolePermissions[p.Role][perm] {
+	case scopeAll:
+		return true
+	case scopeAssigned:
+		if res.GroupModerator {
+			return true
+		}
+		return res.ownedBy(p.ID)
+	case scopeOwn:
+		return res.ownedBy(p.ID)
+	}
+	return false
+}
+
+func (res Resource) ownedBy(userID int) bool {
+	return userID != 0 && (res.OwnerID == userID || res.GroupCreatorID == userID)
+}
@@ -0,0 +1,66 @@
+// Package auth identifies the user behind a request. The middleware in
+// package middleware resolves the session or access token once and stores the
+// resulting Principal in the request context for handlers to read.
+package auth
+
+import (
+	"context"
+	"net/http"
+	"social_network/dbTools"
+	"social_network/logging"
+	"social_network/utils"
+)
+
+// Principal is the authenticated user of a request
+type Principal struct {
+	ID          int
+	UUID        string
+	Role        string // user, admin, group_moderator
+	Status      string // active, pending_verification
+	AccessToken bool   // authenticated by a personal access token instead of the session cookie
+}
+
+type principalKey struct{}
+
+// WithPrincipal stores the principal in the context
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the principal stored by WithPrincipal, if any
+func FromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// FromRequest returns the principal of the request, if it is authenticated
+func FromRequest(r *http.Request) (*Principal, bool) {
+	return FromContext(r.Context())
+}
+
+// UserID returns the ID of the authenticated user, or 0 for anonymous requests
+func UserID(r *http.Request) int {
+	if p, ok := FromRequest(r); ok {
+		return p.ID
+	}
+	return 0
+}
+
+// Resolve authenticates the request by its access token or session cookie and
+// loads the user. It fails for anonymous requests and for users that can no
+// longer sign in.
+func Resolve(db *dbTools.DB, r *http.Request) (*Principal, error) {
+	userID, err := utils.GetUserIDFromSession(db.GetDB(), db.Sessions(), r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Principal{ID: userID}
+	_, p.AccessToken = utils.AccessTokenUserID(r.Context())
+	p.UUID, p.Role, p.Status, err = db.GetAuthUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	logging.SetUserID(r.Context(), p.ID)
+	return p, nil
+}
@@ -0,0 +1,30 @@
+// Command mockidp is a minimal OpenID Connect provider for local development.
+// It signs in whoever fills in its form, so never expose it outside a dev machine.
+//
+//	go run ./cmd/mockidp -addr :9000
+//	OIDC_PROVIDERS=mock OIDC_MOCK_ISSUER=http://localhost:9000 \
+//	OIDC_MOCK_CLIENT_ID=social-network OIDC_MOCK_CLIENT_SECRET=dev-secret go run .
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"social_network/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL, as reachable by the backend and the browser")
+	clientID := flag.String("client-id", "social-network", "accepted client_id")
+	clientSecret := flag.String("client-secret", "dev-secret", "accepted client secret, empty for a public client")
+	flag.Parse()
+
+	s, err := oidctest.NewServer(*issuer, *clientID, *clientSecret)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Mock IdP %s listening on %s", s.Issuer, *addr)
+	log.Fatal(http.ListenAndServe(*addr, s.Handler()))
+}
@@ -0,0 +1,447 @@
+// Package config loads the server settings. Values come from the built-in
+// defaults, then an optional JSON file, then environment variables, then
+// command line flags; each source overrides the ones before it.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxCommentPreview caps posts.comment_preview; longer threads are paged
+const MaxCommentPreview = 20
+
+// Config holds every setting the server reads at startup
+type Config struct {
+	Server   Server   `json:"server"`
+	Database Database `json:"database"`
+	Uploads  Uploads  `json:"uploads"`
+	Posts    Posts    `json:"posts"`
+	Accounts Accounts `json:"accounts"`
+	CORS     CORS     `json:"cors"`
+	Sessions Sessions `json:"sessions"`
+	Log      Log      `json:"log"`
+}
+
+// Server is where the API listens and how it is reached from outside
+type Server struct {
+	Addr string `json:"addr"` // listen address, e.g. :8080
+	URL  string `json:"url"`  // public base URL, used for OIDC callbacks
+	// AppURL is the public base URL of the frontend. Links in emails and OIDC
+	// redirects point at it, and its origin is trusted for state-changing requests.
+	AppURL string `json:"app_url"`
+	// ShutdownTimeout is how long requests, websocket clients and background work get to finish on SIGTERM
+	ShutdownTimeout Duration `json:"shutdown_timeout"`
+}
+
+// Database is the SQLite file and its migrations
+type Database struct {
+	Path          string `json:"path"`
+	MigrationsDir string `json:"migrations_dir"`
+}
+
+// Uploads is where uploaded files are stored and how large a form may be
+type Uploads struct {
+	Dir          string `json:"dir"`
+	MaxFormBytes int64  `json:"max_form_bytes"` // multipart forms with files
+}
+
+// Posts controls how post listings are put together
+type Posts struct {
+	// CommentPreview is how many of the latest comments come with each listed post; the rest are paged separately
+	CommentPreview int `json:"comment_preview"`
+}
+
+// Accounts are the rules for creating and closing accounts
+type Accounts struct {
+	PasswordPolicy string `json:"password_policy"` // basic or strong
+	// DeletionGracePeriod is how long a deleted account can be restored by logging in before it is erased
+	DeletionGracePeriod Duration `json:"deletion_grace_period"`
+}
+
+// CORS lists the frontends that may call the API from the browser
+type CORS struct {
+	// AllowedOrigins may read responses and send state-changing requests with the session cookie
+	AllowedOrigins []string `json:"allowed_origins"`
+	// TrustedOrigins may also send state-changing requests, without being allowed to read responses
+	TrustedOrigins []string `json:"trusted_origins"`
+}
+
+// Trusted returns every origin that passes the cross-site request check
+func (c CORS) Trusted() []string {
+	return append(append([]string{}, c.AllowedOrigins...), c.TrustedOrigins...)
+}
+
+// TrustedOrigins returns every origin that passes the cross-site request
+// check: the CORS origins and the frontend at Server.AppURL
+func (c *Config) TrustedOrigins() []string {
+	trusted := c.CORS.Trusted()
+	if u, err := url.Parse(c.Server.AppURL); err == nil && u.Host != "" {
+		trusted = append(trusted, u.Scheme+"://"+u.Host)
+	}
+	return trusted
+}
+
+// Sessions are the login session lifetimes
+type Sessions struct {
+	// Duration is the idle timeout: every authenticated request pushes expires_at this far into the future
+	Duration Duration `json:"duration"`
+	// MaxLifetime caps how long a session can be kept alive by activity
+	MaxLifetime Duration `json:"max_lifetime"`
+}
+
+// Log controls what the server logs and in which format
+type Log struct {
+	Level  string `json:"level"`  // debug, info, warn or error
+	Format string `json:"format"` // text or json
+}
+
+// Duration is a time.Duration written as "24h" in the config file
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"24h\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Std returns the value as a time.Duration
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// Default returns the settings used for local development
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Addr:            ":8080",
+			URL:             "http://localhost:8080",
+			AppURL:          "http://localhost:3000",
+			ShutdownTimeout: Duration(15 * time.Second),
+		},
+		Database: Database{
+			Path:          "./db/socnet.db",
+			MigrationsDir: "./db/migrations",
+		},
+		Uploads: Uploads{
+			Dir:          "public/uploads",
+			MaxFormBytes: 10 << 20,
+		},
+		Posts: Posts{
+			CommentPreview: 3,
+		},
+		Accounts: Accounts{
+			PasswordPolicy:      "basic",
+			DeletionGracePeriod: Duration(30 * 24 * time.Hour),
+		},
+		CORS: CORS{
+			AllowedOrigins: []string{"http://localhost:3000"},
+		},
+		Sessions: Sessions{
+			Duration:    Duration(24 * time.Hour),
+			MaxLifetime: Duration(30 * 24 * time.Hour),
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+		},
+	}
+}
+
+// Load registers the config flags on fs, parses args and returns the validated settings.
+// The config file is named by -config or CONFIG_FILE.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	cfg := Default()
+
+	var flags Config
+	var configFile, origins, trusted string
+	var shutdownTimeout, deletionGracePeriod, sessionDuration, sessionMaxLifetime time.Duration
+	fs.StringVar(&configFile, "config", "", "path to a JSON config file (env CONFIG_FILE)")
+	fs.StringVar(&flags.Server.Addr, "addr", "", "listen address (env LISTEN_ADDR)")
+	fs.StringVar(&flags.Server.URL, "url", "", "public base URL of the backend (env BACKEND_URL)")
+	fs.StringVar(&flags.Server.AppURL, "app-url", "", "public base URL of the frontend (env APP_BASE_URL)")
+	fs.DurationVar(&shutdownTimeout, "shutdown-timeout", 0, "time to finish in-flight work on shutdown (env SHUTDOWN_TIMEOUT)")
+	fs.StringVar(&flags.Database.Path, "db", "", "SQLite database file (env DB_PATH)")
+	fs.StringVar(&flags.Database.MigrationsDir, "migrations", "", "migrations directory (env DB_MIGRATIONS_DIR)")
+	fs.StringVar(&flags.Uploads.Dir, "uploads", "", "uploaded files directory (env UPLOADS_DIR)")
+	fs.Int64Var(&flags.Uploads.MaxFormBytes, "max-form-bytes", 0, "largest accepted multipart form (env MAX_FORM_BYTES)")
+	fs.IntVar(&flags.Posts.CommentPreview, "comment-preview", 0, "latest comments listed with each post (env COMMENT_PREVIEW)")
+	fs.StringVar(&flags.Accounts.PasswordPolicy, "password-policy", "", "basic or strong (env PASSWORD_POLICY)")
+	fs.DurationVar(&deletionGracePeriod, "deletion-grace-period", 0, "time to restore a deleted account (env ACCOUNT_DELETION_GRACE_PERIOD)")
+	fs.StringVar(&origins, "cors-origins", "", "comma-separated allowed frontend origins (env CORS_ALLOWED_ORIGINS)")
+	fs.StringVar(&trusted, "trusted-origins", "", "comma-separated extra origins trusted for state-changing requests (env CSRF_TRUSTED_ORIGINS)")
+	fs.DurationVar(&sessionDuration, "session-duration", 0, "session idle timeout (env SESSION_DURATION)")
+	fs.DurationVar(&sessionMaxLifetime, "session-max-lifetime", 0, "longest a session can be kept alive (env SESSION_MAX_LIFETIME)")
+	fs.StringVar(&flags.Log.Level, "log-level", "", "debug, info, warn or error (env LOG_LEVEL)")
+	fs.StringVar(&flags.Log.Format, "log-format", "", "text or json (env LOG_FORMAT)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if configFile == "" {
+		configFile = os.Getenv("CONFIG_FILE")
+	}
+	if configFile != "" {
+		if err := cfg.loadFile(configFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	// Flags only override what was given on the command line
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "addr":
+			cfg.Server.Addr = flags.Server.Addr
+		case "url":
+			cfg.Server.URL = flags.Server.URL
+		case "app-url":
+			cfg.Server.AppURL = flags.Server.AppURL
+		case "shutdown-timeout":
+			cfg.Server.ShutdownTimeout = Duration(shutdownTimeout)
+		case "db":
+			cfg.Database.Path = flags.Database.Path
+		case "migrations":
+			cfg.Database.MigrationsDir = flags.Database.MigrationsDir
+		case "uploads":
+			cfg.Uploads.Dir = flags.Uploads.Dir
+		case "max-form-bytes":
+			cfg.Uploads.MaxFormBytes = flags.Uploads.MaxFormBytes
+		case "comment-preview":
+			cfg.Posts.CommentPreview = flags.Posts.CommentPreview
+		case "password-policy":
+			cfg.Accounts.PasswordPolicy = flags.Accounts.PasswordPolicy
+		case "deletion-grace-period":
+			cfg.Accounts.DeletionGracePeriod = Duration(deletionGracePeriod)
+		case "cors-origins":
+			cfg.CORS.AllowedOrigins = splitList(origins)
+		case "trusted-origins":
+			cfg.CORS.TrustedOrigins = splitList(trusted)
+		case "session-duration":
+			cfg.Sessions.Duration = Duration(sessionDuration)
+		case "session-max-lifetime":
+			cfg.Sessions.MaxLifetime = Duration(sessionMaxLifetime)
+		case "log-level":
+			cfg.Log.Level = flags.Log.Level
+		case "log-format":
+			cfg.Log.Format = flags.Log.Format
+		}
+	})
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile overrides the settings present in a JSON file; unknown keys are an error
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(c); err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// loadEnv overrides the settings that have an environment variable set
+func (c *Config) loadEnv() error {
+	strVars := map[string]*string{
+		"LISTEN_ADDR":       &c.Server.Addr,
+		"BACKEND_URL":       &c.Server.URL,
+		"APP_BASE_URL":      &c.Server.AppURL,
+		"DB_PATH":           &c.Database.Path,
+		"DB_MIGRATIONS_DIR": &c.Database.MigrationsDir,
+		"UPLOADS_DIR":       &c.Uploads.Dir,
+		"PASSWORD_POLICY":   &c.Accounts.PasswordPolicy,
+		"LOG_LEVEL":         &c.Log.Level,
+		"LOG_FORMAT":        &c.Log.Format,
+	}
+	for name, dst := range strVars {
+		if v := os.Getenv(name); v != "" {
+			*dst = v
+		}
+	}
+
+	if v := os.Getenv("MAX_FORM_BYTES"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("MAX_FORM_BYTES: %w", err)
+		}
+		c.Uploads.MaxFormBytes = n
+	}
+	if v := os.Getenv("COMMENT_PREVIEW"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("COMMENT_PREVIEW: %w", err)
+		}
+		c.Posts.CommentPreview = n
+	}
+	if v := os.Getenv("CORS_ALLOWED_ORIGINS"); v != "" {
+		c.CORS.AllowedOrigins = splitList(v)
+	}
+	if v := os.Getenv("CSRF_TRUSTED_ORIGINS"); v != "" {
+		c.CORS.TrustedOrigins = splitList(v)
+	}
+
+	durVars := map[string]*Duration{
+		"SHUTDOWN_TIMEOUT":              &c.Server.ShutdownTimeout,
+		"ACCOUNT_DELETION_GRACE_PERIOD": &c.Accounts.DeletionGracePeriod,
+		"SESSION_DURATION":              &c.Sessions.Duration,
+		"SESSION_MAX_LIFETIME":          &c.Sessions.MaxLifetime,
+	}
+	for name, dst := range durVars {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			*dst = Duration(d)
+		}
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if _, port, err := net.SplitHostPort(c.Server.Addr); err != nil {
+		add("server.addr %q: %v", c.Server.Addr, err)
+	} else if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		add("server.addr %q: invalid port", c.Server.Addr)
+	}
+	if u, err := url.Parse(c.Server.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("server.url %q: must be an absolute http(s) URL", c.Server.URL)
+	}
+	if u, err := url.Parse(c.Server.AppURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("server.app_url %q: must be an absolute http(s) URL", c.Server.AppURL)
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		add("server.shutdown_timeout must be positive")
+	}
+
+	if c.Database.Path == "" {
+		add("database.path is required")
+	} else if dir := filepath.Dir(c.Database.Path); !isDir(dir) {
+		add("database.path %q: directory %s does not exist", c.Database.Path, dir)
+	}
+	if !isDir(c.Database.MigrationsDir) {
+		add("database.migrations_dir %q is not a directory", c.Database.MigrationsDir)
+	}
+
+	if c.Uploads.Dir == "" {
+		add("uploads.dir is required")
+	} else if info, err := os.Stat(c.Uploads.Dir); err == nil && !info.IsDir() {
+		add("uploads.dir %q is not a directory", c.Uploads.Dir)
+	}
+	if c.Uploads.MaxFormBytes <= 0 {
+		add("uploads.max_form_bytes must be positive")
+	}
+
+	if c.Posts.CommentPreview < 0 || c.Posts.CommentPreview > MaxCommentPreview {
+		add("posts.comment_preview must be between 0 and %d", MaxCommentPreview)
+	}
+
+	if c.Accounts.PasswordPolicy != "basic" && c.Accounts.PasswordPolicy != "strong" {
+		add("accounts.password_policy %q: must be basic or strong", c.Accounts.PasswordPolicy)
+	}
+	if c.Accounts.DeletionGracePeriod < 0 {
+		add("accounts.deletion_grace_period cannot be negative")
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		add("cors.allowed_origins needs at least one origin")
+	}
+	for _, origin := range c.CORS.Trusted() {
+		if err := validateOrigin(origin); err != nil {
+			add("cors origin %q: %v", origin, err)
+		}
+	}
+
+	if c.Sessions.Duration <= 0 {
+		add("sessions.duration must be positive")
+	}
+	if c.Sessions.MaxLifetime < c.Sessions.Duration {
+		add("sessions.max_lifetime must be at least sessions.duration")
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		add("log.level %q: must be debug, info, warn or error", c.Log.Level)
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		add("log.format %q: must be text or json", c.Log.Format)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// validateOrigin accepts scheme://host[:port] and nothing else. A wildcard is
+// not allowed since the API is called with credentials.
+func validateOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("scheme must be http or https")
+	}
+	if u.Host == "" || strings.Contains(u.Host, "*") {
+		return errors.New("host is required and cannot be a wildcard")
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("an origin has no path, query or fragment")
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_sessions_user_status;
+DROP INDEX IF EXISTS idx_sessions_public_uuid;
+
+ALTER TABLE sessions DROP COLUMN last_seen_at;
+ALTER TABLE sessions DROP COLUMN ip_address;
+ALTER TABLE sessions DROP COLUMN user_agent;
+ALTER TABLE sessions DROP COLUMN name;
+ALTER TABLE sessions DROP COLUMN public_uuid;
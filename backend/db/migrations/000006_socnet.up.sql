@@ -0,0 +1,16 @@
+-- Device metadata and a public identifier for sessions.
+-- session_uuid is the cookie value and must never leave the server, so the
+-- sessions API addresses sessions by public_uuid instead.
+ALTER TABLE sessions ADD COLUMN public_uuid TEXT;
+ALTER TABLE sessions ADD COLUMN name TEXT;
+ALTER TABLE sessions ADD COLUMN user_agent TEXT;
+ALTER TABLE sessions ADD COLUMN ip_address TEXT;
+ALTER TABLE sessions ADD COLUMN last_seen_at DATETIME;
+
+UPDATE sessions
+SET public_uuid = lower(hex(randomblob(16))),
+    last_seen_at = COALESCE(updated_at, created_at)
+WHERE public_uuid IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_public_uuid ON sessions(public_uuid);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_status ON sessions(user_id, status);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_login_attempts_ip;
+DROP INDEX IF EXISTS idx_login_attempts_email;
+DROP TABLE IF EXISTS login_attempts;
@@ -0,0 +1,15 @@
+-- Audit trail of every login attempt, used for throttling and lockout
+CREATE TABLE IF NOT EXISTS "login_attempts" (
+    attempt_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL,                /* normalised (lowercase) email as submitted */
+    user_id INTEGER,                    /* NULL when the email does not belong to a user */
+    ip_address TEXT NOT NULL,
+    user_agent TEXT,
+    success BOOLEAN NOT NULL,
+    failure_reason TEXT CHECK(failure_reason IN ('invalid_credentials', 'throttled', 'locked')),
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_attempts_email ON login_attempts(email, created_at);
+CREATE INDEX IF NOT EXISTS idx_login_attempts_ip ON login_attempts(ip_address, created_at);
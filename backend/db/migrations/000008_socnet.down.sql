@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS pending_2fa_logins;
+DROP INDEX IF EXISTS idx_recovery_codes_user;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS user_totp;
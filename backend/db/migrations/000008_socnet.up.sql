@@ -0,0 +1,33 @@
+-- TOTP second factor (RFC 6238)
+CREATE TABLE IF NOT EXISTS "user_totp" (
+    user_id INTEGER PRIMARY KEY,
+    secret TEXT NOT NULL,               /* base32 shared secret */
+    status TEXT CHECK(status IN ('pending', 'enabled')) NOT NULL DEFAULT 'pending',
+    last_used_step INTEGER NOT NULL DEFAULT 0,  /* rejects replay of an already used code */
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    enabled_at DATETIME,
+    updated_at DATETIME,
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+    code_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash TEXT NOT NULL,            /* SHA-256 of the normalised code */
+    used_at DATETIME,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recovery_codes_user ON recovery_codes(user_id);
+
+/* issued by /api/login when the password is correct but a second factor is required */
+CREATE TABLE IF NOT EXISTS "pending_2fa_logins" (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    status TEXT CHECK(status IN ('pending', 'used', 'failed')) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
@@ -0,0 +1,12 @@
+-- Single-use password reset tokens; only the SHA-256 of the token is stored
+CREATE TABLE IF NOT EXISTS "password_reset_tokens" (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    ip_address TEXT,                    /* who requested the reset */
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens(user_id, created_at);
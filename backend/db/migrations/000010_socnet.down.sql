@@ -0,0 +1,35 @@
+PRAGMA foreign_keys=off;
+
+CREATE TABLE "users_old" (
+    user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_uuid TEXT NOT NULL UNIQUE,
+    email TEXT NOT NULL UNIQUE,
+    password TEXT NOT NULL,
+    first_name TEXT NOT NULL UNIQUE,
+    last_name TEXT NOT NULL,
+    date_of_birth DATE NOT NULL,
+    nickname TEXT,
+    about_me TEXT,
+    avatar TEXT,
+    privacy TEXT CHECK(privacy IN ('private', 'public')) NOT NULL DEFAULT 'private',
+    role TEXT CHECK(role IN ('user', 'admin', 'group_moderator')) NOT NULL DEFAULT 'user',
+    status TEXT CHECK(status IN ('active', 'inactive')) NOT NULL DEFAULT 'active',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL,
+    updater_id INTEGER,
+    FOREIGN KEY(updater_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Unverified accounts become regular active accounts again
+INSERT INTO users_old (user_id, user_uuid, email, password, first_name, last_name, date_of_birth,
+                       nickname, about_me, avatar, privacy, role, status, created_at, updated_at, updater_id)
+SELECT user_id, user_uuid, email, password, first_name, last_name, date_of_birth,
+       nickname, about_me, avatar, privacy, role,
+       CASE WHEN status = 'pending_verification' THEN 'active' ELSE status END,
+       created_at, updated_at, updater_id
+FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_old RENAME TO users;
+
+PRAGMA foreign_keys=on;
@@ -0,0 +1,41 @@
+-- Email verification: add the 'pending_verification' account status.
+-- SQLite cannot alter a CHECK constraint, so the users table is rebuilt.
+-- The app does not enable foreign key enforcement, so dropping the old table
+-- does not cascade; child tables keep referencing "users" by name.
+PRAGMA foreign_keys=off;
+
+CREATE TABLE "users_new" (
+    user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_uuid TEXT NOT NULL UNIQUE,
+    email TEXT NOT NULL UNIQUE,
+    password TEXT NOT NULL,
+    first_name TEXT NOT NULL UNIQUE,
+    last_name TEXT NOT NULL,
+    date_of_birth DATE NOT NULL,
+    nickname TEXT,
+    about_me TEXT,
+    avatar TEXT,
+    privacy TEXT CHECK(privacy IN ('private', 'public')) NOT NULL DEFAULT 'private',
+    role TEXT CHECK(role IN ('user', 'admin', 'group_moderator')) NOT NULL DEFAULT 'user',
+    status TEXT CHECK(status IN ('active', 'inactive', 'pending_verification')) NOT NULL DEFAULT 'pending_verification',
+    email_verified_at DATETIME,
+    verification_sent_at DATETIME,      /* throttles resending the verification email */
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL,
+    updater_id INTEGER,
+    FOREIGN KEY(updater_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+-- Existing accounts predate verification and are treated as verified
+INSERT INTO users_new (user_id, user_uuid, email, password, first_name, last_name, date_of_birth,
+                       nickname, about_me, avatar, privacy, role, status, email_verified_at,
+                       created_at, updated_at, updater_id)
+SELECT user_id, user_uuid, email, password, first_name, last_name, date_of_birth,
+       nickname, about_me, avatar, privacy, role, status, created_at,
+       created_at, updated_at, updater_id
+FROM users;
+
+DROP TABLE users;
+ALTER TABLE users_new RENAME TO users;
+
+PRAGMA foreign_keys=on;
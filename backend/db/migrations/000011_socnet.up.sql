@@ -0,0 +1,18 @@
+-- Personal access tokens for scripted API access; only the SHA-256 of the token is stored
+CREATE TABLE IF NOT EXISTS "personal_access_tokens" (
+    token_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    public_uuid TEXT NOT NULL UNIQUE,   /* identifies the token in the API, never the secret */
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    token_hint TEXT NOT NULL,           /* last characters, to recognise the token in lists */
+    scopes TEXT NOT NULL,               /* space separated: read write:posts chat admin */
+    status TEXT CHECK(status IN ('active', 'revoked')) NOT NULL DEFAULT 'active',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at DATETIME,
+    expires_at DATETIME,                /* NULL means no expiry */
+    revoked_at DATETIME,
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user ON personal_access_tokens(user_id, status);
@@ -0,0 +1,26 @@
+-- External OpenID Connect identities linked to local accounts
+CREATE TABLE IF NOT EXISTS "user_identities" (
+    identity_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    provider TEXT NOT NULL,             /* provider name from the OIDC configuration */
+    subject TEXT NOT NULL,              /* "sub" claim, stable per provider */
+    email TEXT,                         /* email claim at link time, informational */
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_login_at DATETIME,
+    UNIQUE(provider, subject),
+    UNIQUE(user_id, provider),
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+/* state of an authorization code flow between the redirect to the provider and the callback */
+CREATE TABLE IF NOT EXISTS "oidc_login_states" (
+    state_hash TEXT PRIMARY KEY,
+    provider TEXT NOT NULL,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,        /* PKCE verifier, never sent to the browser */
+    link_user_id INTEGER,               /* set when an existing account links a provider */
+    redirect_path TEXT NOT NULL DEFAULT '/',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL,
+    FOREIGN KEY(link_user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
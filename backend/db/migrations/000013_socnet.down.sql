@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_account_deletions_due;
+DROP INDEX IF EXISTS idx_account_deletions_open;
+DROP TABLE IF EXISTS account_deletions;
+
+ALTER TABLE users DROP COLUMN deleted_at;
+ALTER TABLE users DROP COLUMN deactivated_at;
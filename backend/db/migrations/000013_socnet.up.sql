@@ -0,0 +1,22 @@
+-- Account deactivation and deletion
+ALTER TABLE users ADD COLUMN deactivated_at DATETIME;   /* set while the owner has deactivated the account */
+ALTER TABLE users ADD COLUMN deleted_at DATETIME;       /* set once the account has been erased; the row stays as an anonymous placeholder */
+
+/* deletion requests; each one runs once its grace period is over */
+CREATE TABLE IF NOT EXISTS "account_deletions" (
+    deletion_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    status TEXT CHECK(status IN ('scheduled', 'removing_files', 'completed', 'cancelled')) NOT NULL DEFAULT 'scheduled',
+    requested_at DATETIME NOT NULL,
+    scheduled_for DATETIME NOT NULL,    /* end of the grace period */
+    started_at DATETIME,
+    completed_at DATETIME,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    pending_files TEXT,                 /* JSON list of uploads still to remove from disk */
+    report TEXT,                        /* JSON summary of what was erased */
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_account_deletions_open ON account_deletions(user_id) WHERE status IN ('scheduled', 'removing_files');
+CREATE INDEX IF NOT EXISTS idx_account_deletions_due ON account_deletions(status, scheduled_for);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_group_moderators_user;
+DROP TABLE IF EXISTS group_moderators;
@@ -0,0 +1,13 @@
+-- Group moderators: users with the group_moderator role manage the groups they are assigned to
+CREATE TABLE IF NOT EXISTS "group_moderators" (
+    group_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    assigned_by INTEGER,                /* admin who made the assignment */
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(group_id, user_id),
+    FOREIGN KEY(group_id) REFERENCES groups(group_id) ON DELETE CASCADE,
+    FOREIGN KEY(user_id) REFERENCES users(user_id) ON DELETE CASCADE,
+    FOREIGN KEY(assigned_by) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_moderators_user ON group_moderators(user_id);
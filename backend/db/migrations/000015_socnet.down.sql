@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_interactions_parent;
+DROP INDEX IF EXISTS idx_interactions_user_parent;
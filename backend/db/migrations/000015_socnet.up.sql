@@ -0,0 +1,9 @@
+-- Reactions: each user has at most one interaction per post or comment, which
+-- toggles between like, dislike and cancelled
+DELETE FROM interactions
+WHERE interaction_id NOT IN (
+    SELECT MAX(interaction_id) FROM interactions GROUP BY user_id, parent_type, parent_id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_interactions_user_parent ON interactions(user_id, parent_type, parent_id);
+CREATE INDEX IF NOT EXISTS idx_interactions_parent ON interactions(parent_type, parent_id, interaction_type);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_post_categories_name;
+DROP INDEX IF EXISTS idx_post_categories_post_name;
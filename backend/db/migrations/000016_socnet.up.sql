@@ -0,0 +1,9 @@
+-- Post categories and hashtags: a tag is stored once per post, and the feed
+-- is filtered and the tag directory counted by category_name
+DELETE FROM post_categories
+WHERE category_id NOT IN (
+    SELECT MIN(category_id) FROM post_categories GROUP BY post_id, category_name
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_post_categories_post_name ON post_categories(post_id, category_name);
+CREATE INDEX IF NOT EXISTS idx_post_categories_name ON post_categories(category_name, status);
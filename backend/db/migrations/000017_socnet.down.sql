@@ -0,0 +1,4 @@
+ALTER TABLE comments DROP COLUMN edited_at;
+ALTER TABLE posts DROP COLUMN edited_at;
+DROP INDEX IF EXISTS idx_content_revisions_parent;
+DROP TABLE IF EXISTS content_revisions;
@@ -0,0 +1,17 @@
+-- Revision history of posts and comments: every edit keeps the content it replaced
+CREATE TABLE IF NOT EXISTS "content_revisions" (
+    revision_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    parent_type TEXT CHECK(parent_type IN ('post', 'comment')) NOT NULL,
+    parent_id INTEGER NOT NULL,
+    content TEXT NOT NULL,              /* the content before the edit */
+    written_at DATETIME NOT NULL,       /* when that content was posted or last edited */
+    editor_id INTEGER,                  /* who made the edit: the author or a moderator */
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(editor_id) REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_revisions_parent ON content_revisions(parent_type, parent_id, revision_id);
+
+-- When a post or comment was last edited; updated_at also changes on deletion and is set on seed data
+ALTER TABLE posts ADD COLUMN edited_at DATETIME;
+ALTER TABLE comments ADD COLUMN edited_at DATETIME;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_post_private_viewers_user;
+DROP INDEX IF EXISTS idx_posts_status_created;
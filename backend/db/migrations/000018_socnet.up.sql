@@ -0,0 +1,6 @@
+-- Keyset pagination of post listings: newest active posts first. post_id is the
+-- rowid, so the index is also ordered by it within the same created_at.
+CREATE INDEX IF NOT EXISTS idx_posts_status_created ON posts(status, created_at);
+
+-- Private posts a user was picked to see, for the visibility policy
+CREATE INDEX IF NOT EXISTS idx_post_private_viewers_user ON post_private_viewers(user_id);
@@ -0,0 +1,2 @@
+-- Comment counts, previews and pages of a post's thread: newest active comments first
+CREATE INDEX IF NOT EXISTS idx_comments_post_status_created ON comments(post_id, status, created_at);
@@ -0,0 +1,118 @@
+package dbTools
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// accessTokenTouchInterval limits how often last_used_at is written for busy scripts
+const accessTokenTouchInterval = time.Minute
+
+// CreateAccessToken stores a new personal access token
+func (d *DB) CreateAccessToken(t *AccessToken, tokenHash string) error {
+	defer observe("CreateAccessToken", time.Now())
+	var expiresAt interface{}
+	if t.ExpiresAt != nil {
+		expiresAt = t.ExpiresAt.UTC()
+	}
+	t.CreatedAt = time.Now().UTC()
+	_, err := d.db.Exec(`
+        INSERT INTO personal_access_tokens (public_uuid, user_id, name, token_hash, token_hint, scopes, created_at, expires_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+    `, t.PublicUUID, t.UserID, t.Name, tokenHash, t.TokenHint, strings.Join(t.Scopes, " "), t.CreatedAt, expiresAt)
+	return err
+}
+
+// GetAccessTokens lists the user's active, unexpired tokens, newest first
+func (d *DB) GetAccessTokens(userID int) ([]AccessToken, error) {
+	defer observe("GetAccessTokens", time.Now())
+	rows, err := d.db.Query(`
+        SELECT public_uuid, user_id, name, token_hint, scopes, created_at, last_used_at, expires_at
+        FROM personal_access_tokens
+        WHERE user_id = ? AND status = 'active' AND (expires_at IS NULL OR expires_at > ?)
+        ORDER BY created_at DESC
+    `, userID, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []AccessToken{}
+	for rows.Next() {
+		t, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, rows.Err()
+}
+
+// AuthenticateAccessToken returns the active, unexpired token with the given
+// hash, or sql.ErrNoRows. Tokens of deactivated users do not authenticate.
+func (d *DB) AuthenticateAccessToken(tokenHash string) (*AccessToken, error) {
+	defer observe("AuthenticateAccessToken", time.Now())
+	now := time.Now().UTC()
+	t, err := scanAccessToken(d.db.QueryRow(`
+        SELECT t.public_uuid, t.user_id, t.name, t.token_hint, t.scopes, t.created_at, t.last_used_at, t.expires_at
+        FROM personal_access_tokens t
+        JOIN users u ON u.user_id = t.user_id AND u.status IN ('active', 'pending_verification')
+        WHERE t.token_hash = ? AND t.status = 'active' AND (t.expires_at IS NULL OR t.expires_at > ?)
+    `, tokenHash, now))
+	if err != nil {
+		return nil, err
+	}
+
+	if t.LastUsedAt == nil || t.LastUsedAt.Before(now.Add(-accessTokenTouchInterval)) {
+		if _, err := d.db.Exec(`UPDATE personal_access_tokens SET last_used_at = ? WHERE token_hash = ?`, now, tokenHash); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// RevokeAccessToken revokes one of the user's tokens. It returns false if the token was not found.
+func (d *DB) RevokeAccessToken(userID int, publicUUID string) (bool, error) {
+	defer observe("RevokeAccessToken", time.Now())
+	result, err := d.db.Exec(`
+        UPDATE personal_access_tokens SET status = 'revoked', revoked_at = ?
+        WHERE user_id = ? AND public_uuid = ? AND status = 'active'
+    `, time.Now().UTC(), userID, publicUUID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAccessToken(row rowScanner) (*AccessToken, error) {
+	t := &AccessToken{}
+	var scopes string
+	var lastUsedAt, expiresAt sql.NullTime
+	if err := row.Scan(&t.PublicUUID, &t.UserID, &t.Name, &t.TokenHint, &scopes, &t.CreatedAt, &lastUsedAt, &expiresAt); err != nil {
+		return nil, err
+	}
+	t.Scopes = strings.Fields(scopes)
+	if lastUsedAt.Valid {
+		t.LastUsedAt = &lastUsedAt.Time
+	}
+	if expiresAt.Valid {
+		t.ExpiresAt = &expiresAt.Time
+	}
+	return t, nil
+}
+
+// HasScope reports whether the token was granted scope
+func (t *AccessToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package dbTools
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrEmailTaken is returned when another account already uses the email address
+var ErrEmailTaken = errors.New("email already registered")
+
+// UpdateEmail changes the user's email address. The new address has to be
+// verified again, every other session and all access tokens are revoked and
+// outstanding password reset links stop working.
+func (d *DB) UpdateEmail(userID int, newEmail, keepSessionUUID string) error {
+	defer observe("UpdateEmail", time.Now())
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE lower(email) = lower(?) AND user_id != ?`,
+			newEmail, userID).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrEmailTaken
+		}
+
+		_, err := tx.Exec(`
+            UPDATE users SET email = ?, status = 'pending_verification', email_verified_at = NULL,
+                verification_sent_at = NULL, updated_at = CURRENT_TIMESTAMP, updater_id = ?
+            WHERE user_id = ?
+        `, newEmail, userID, userID)
+		if err != nil {
+			return err
+		}
+		return revokeCredentials(tx, userID, keepSessionUUID)
+	})
+}
+
+// UpdatePassword stores a new password hash, revokes every other session and
+// all access tokens and invalidates outstanding password reset links
+func (d *DB) UpdatePassword(userID int, passwordHash, keepSessionUUID string) error {
+	defer observe("UpdatePassword", time.Now())
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`UPDATE users SET password = ?, updated_at = CURRENT_TIMESTAMP, updater_id = ? WHERE user_id = ?`,
+			passwordHash, userID, userID)
+		if err != nil {
+			return err
+		}
+		return revokeCredentials(tx, userID, keepSessionUUID)
+	})
+}
+
+// revokeCredentials ends all sessions except keepSessionUUID, revokes the
+// user's personal access tokens and burns unused reset tokens
+func revokeCredentials(tx *sql.Tx, userID int, keepSessionUUID string) error {
+	now := time.Now().UTC()
+	_, err := tx.Exec(`
+        UPDATE sessions SET status = 'inactive', updated_at = CURRENT_TIMESTAMP, updater_id = ?
+        WHERE user_id = ? AND status = 'active' AND session_uuid != ?
+    `, userID, userID, keepSessionUUID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE personal_access_tokens SET status = 'revoked', revoked_at = ? WHERE user_id = ? AND status = 'active'`,
+		now, userID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE password_reset_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL`,
+		now, userID)
+	return err
+}
@@ -0,0 +1,435 @@
+package dbTools
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"social_network/utils"
+	"time"
+)
+
+// DeactivateUser hides the account and signs it out everywhere. Logging in again reactivates it.
+func (d *DB) DeactivateUser(userID int) error {
+	defer observe("DeactivateUser", time.Now())
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		return deactivateUser(tx, userID)
+	})
+}
+
+// ScheduleAccountDeletion deactivates the account and schedules its erasure.
+// Logging in before scheduledFor cancels the deletion.
+func (d *DB) ScheduleAccountDeletion(userID int, scheduledFor time.Time) (*AccountDeletion, error) {
+	defer observe("ScheduleAccountDeletion", time.Now())
+	deletion := &AccountDeletion{
+		UserID:       userID,
+		Status:       "scheduled",
+		RequestedAt:  time.Now().UTC(),
+		ScheduledFor: scheduledFor.UTC(),
+	}
+	err := d.WithTransaction(func(tx *sql.Tx) error {
+		if err := deactivateUser(tx, userID); err != nil {
+			return err
+		}
+		result, err := tx.Exec(`
+            INSERT INTO account_deletions (user_id, status, requested_at, scheduled_for)
+            VALUES (?, 'scheduled', ?, ?)
+        `, userID, deletion.RequestedAt, deletion.ScheduledFor)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		deletion.DeletionID = int(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deletion, nil
+}
+
+func deactivateUser(tx *sql.Tx, userID int) error {
+	now := time.Now().UTC()
+	_, err := tx.Exec(`
+        UPDATE users SET status = 'inactive', deactivated_at = COALESCE(deactivated_at, ?), updated_at = ?, updater_id = ?
+        WHERE user_id = ? AND deleted_at IS NULL
+    `, now, now, userID, userID)
+	if err != nil {
+		return err
+	}
+	return revokeCredentials(tx, userID, "")
+}
+
+// ReactivateUser brings back a deactivated account and cancels a scheduled
+// deletion. It reports whether the account was deactivated.
+func (d *DB) ReactivateUser(userID int) (bool, error) {
+	defer observe("ReactivateUser", time.Now())
+	reactivated := false
+	err := d.WithTransaction(func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		result, err := tx.Exec(`
+            UPDATE users
+            SET status = CASE WHEN email_verified_at IS NULL THEN 'pending_verification' ELSE 'active' END,
+                deactivated_at = NULL, updated_at = ?, updater_id = ?
+            WHERE user_id = ? AND status = 'inactive' AND deleted_at IS NULL
+        `, now, userID, userID)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil || n == 0 {
+			return err
+		}
+		reactivated = true
+
+		_, err = tx.Exec(`UPDATE account_deletions SET status = 'cancelled', completed_at = ? WHERE user_id = ? AND status = 'scheduled'`,
+			now, userID)
+		return err
+	})
+	return reactivated, err
+}
+
+// DueAccountDeletions returns the deletions to run now: those past their grace
+// period and those interrupted while removing files
+func (d *DB) DueAccountDeletions(now time.Time) ([]int, error) {
+	defer observe("DueAccountDeletions", time.Now())
+	rows, err := d.db.Query(`
+        SELECT deletion_id FROM account_deletions
+        WHERE (status = 'scheduled' AND scheduled_for <= ?) OR status = 'removing_files'
+        ORDER BY scheduled_for
+    `, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetAccountDeletions lists deletion requests, newest first
+func (d *DB) GetAccountDeletions(status string, limit int) ([]AccountDeletion, error) {
+	defer observe("GetAccountDeletions", time.Now())
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	rows, err := d.db.Query(`
+        SELECT deletion_id, user_id, status, requested_at, scheduled_for, started_at, completed_at,
+               attempts, COALESCE(last_error, ''), COALESCE(report, '')
+        FROM account_deletions
+        WHERE ? = '' OR status = ?
+        ORDER BY deletion_id DESC
+        LIMIT ?
+    `, status, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deletions := []AccountDeletion{}
+	for rows.Next() {
+		var del AccountDeletion
+		var startedAt, completedAt sql.NullTime
+		var report string
+		if err := rows.Scan(&del.DeletionID, &del.UserID, &del.Status, &del.RequestedAt, &del.ScheduledFor,
+			&startedAt, &completedAt, &del.Attempts, &del.LastError, &report); err != nil {
+			return nil, err
+		}
+		if startedAt.Valid {
+			del.StartedAt = &startedAt.Time
+		}
+		if completedAt.Valid {
+			del.CompletedAt = &completedAt.Time
+		}
+		if report != "" {
+			del.Report = &AccountDeletionReport{}
+			if err := json.Unmarshal([]byte(report), del.Report); err != nil {
+				return nil, err
+			}
+		}
+		deletions = append(deletions, del)
+	}
+	return deletions, rows.Err()
+}
+
+// errDeletionNotDue means the deletion was cancelled or already done in the meantime
+var errDeletionNotDue = errors.New("account deletion is not due")
+
+// RunAccountDeletion erases an account. The database part runs in one
+// transaction that also records the uploads to remove; the files are removed
+// after it commits. If the process stops in between, running the deletion
+// again picks up the remaining files. Failures are recorded on the deletion
+// and retried on the next run.
+func (d *DB) RunAccountDeletion(deletionID int) (*AccountDeletionReport, error) {
+	defer observe("RunAccountDeletion", time.Now())
+	report, err := d.runAccountDeletion(deletionID)
+	if errors.Is(err, errDeletionNotDue) {
+		return nil, nil
+	}
+	if err != nil {
+		if _, dbErr := d.db.Exec(`UPDATE account_deletions SET attempts = attempts + 1, last_error = ? WHERE deletion_id = ?`,
+			err.Error(), deletionID); dbErr != nil {
+			slog.ErrorContext(d.Context(), "Failed to record account deletion error", "deletion_id", deletionID, "err", dbErr)
+		}
+	}
+	return report, err
+}
+
+func (d *DB) runAccountDeletion(deletionID int) (*AccountDeletionReport, error) {
+	var userID int
+	var status, pendingFiles, reportJSON string
+	err := d.db.QueryRow(`
+        SELECT user_id, status, COALESCE(pending_files, '[]'), COALESCE(report, '')
+        FROM account_deletions WHERE deletion_id = ?
+    `, deletionID).Scan(&userID, &status, &pendingFiles, &reportJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	report := &AccountDeletionReport{}
+	switch status {
+	case "scheduled":
+		err = d.WithTransaction(func(tx *sql.Tx) error {
+			files, report, err = eraseAccount(tx, deletionID, userID)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	case "removing_files":
+		if err := json.Unmarshal([]byte(pendingFiles), &files); err != nil {
+			return nil, fmt.Errorf("pending files: %w", err)
+		}
+		if reportJSON != "" {
+			if err := json.Unmarshal([]byte(reportJSON), report); err != nil {
+				return nil, fmt.Errorf("report: %w", err)
+			}
+		}
+	default:
+		return nil, errDeletionNotDue
+	}
+
+	// Remove files one by one, saving progress so a retry skips those already gone
+	for len(files) > 0 {
+		err := os.Remove(filepath.Join(d.uploadsDir, files[0]))
+		switch {
+		case err == nil:
+			report.FilesRemoved++
+		case errors.Is(err, os.ErrNotExist):
+			report.FilesMissing++
+		default:
+			return report, fmt.Errorf("remove %s: %w", files[0], err)
+		}
+		files = files[1:]
+		if err := d.saveDeletionProgress(deletionID, files, report); err != nil {
+			return report, err
+		}
+	}
+
+	reportData, err := json.Marshal(report)
+	if err != nil {
+		return report, err
+	}
+	_, err = d.db.Exec(`
+        UPDATE account_deletions SET status = 'completed', completed_at = ?, pending_files = NULL, report = ?, last_error = NULL
+        WHERE deletion_id = ?
+    `, time.Now().UTC(), string(reportData), deletionID)
+	return report, err
+}
+
+func (d *DB) saveDeletionProgress(deletionID int, files []string, report *AccountDeletionReport) error {
+	filesData, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+	reportData, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	_, err = d.db.Exec(`UPDATE account_deletions SET pending_files = ?, report = ? WHERE deletion_id = ?`,
+		string(filesData), string(reportData), deletionID)
+	return err
+}
+
+// eraseAccount removes the user's content and personal data and anonymises
+// the user row, which stays so groups and events the user created keep a
+// valid creator. It returns the upload file names to remove from disk.
+func eraseAccount(tx *sql.Tx, deletionID, userID int) ([]string, *AccountDeletionReport, error) {
+	now := time.Now().UTC()
+
+	// Claim the deletion; a login may have cancelled it since it was picked up
+	result, err := tx.Exec(`
+        UPDATE account_deletions SET status = 'removing_files', started_at = ?
+        WHERE deletion_id = ? AND status = 'scheduled' AND scheduled_for <= ?
+    `, now, deletionID, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		if err == nil {
+			err = errDeletionNotDue
+		}
+		return nil, nil, err
+	}
+
+	const userPosts = `SELECT post_id FROM posts WHERE poster_id = ?`
+	// Comments by the user, and all comments under the user's posts
+	const doomedComments = `SELECT comment_id FROM comments WHERE commenter_id = ? OR post_id IN (` + userPosts + `)`
+	const userChats = `SELECT chat_id FROM chat_messages WHERE sender_id = ? OR receiver_id = ?`
+
+	var avatar, email string
+	if err := tx.QueryRow(`SELECT COALESCE(avatar, ''), email FROM users WHERE user_id = ?`, userID).Scan(&avatar, &email); err != nil {
+		return nil, nil, err
+	}
+
+	var files []string
+	rows, err := tx.Query(`
+        SELECT filename_new FROM files
+        WHERE (parent_type = 'post' AND parent_id IN (`+userPosts+`))
+           OR (parent_type = 'comment' AND parent_id IN (`+doomedComments+`))
+           OR (parent_type = 'chat' AND parent_id IN (`+userChats+`))
+           OR (parent_type = 'profile' AND (uploader_id = ? OR parent_id = ?))
+    `, userID, userID, userID, userID, userID, userID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		files = appendUpload(files, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if avatar != "/uploads/default_avatar.jpg" {
+		files = appendUpload(files, filepath.Base(avatar))
+	}
+
+	report := &AccountDeletionReport{Rows: map[string]int64{}}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM groups WHERE creator_id = ?`, userID).Scan(&report.GroupsRetained); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM events WHERE creator_id = ?`, userID).Scan(&report.EventsRetained); err != nil {
+		return nil, nil, err
+	}
+
+	// Order matters: rows that are found through posts and comments go before those
+	steps := []struct {
+		table string
+		query string
+		args  []interface{}
+	}{
+		{"files", `DELETE FROM files
+            WHERE (parent_type = 'post' AND parent_id IN (` + userPosts + `))
+               OR (parent_type = 'comment' AND parent_id IN (` + doomedComments + `))
+               OR (parent_type = 'chat' AND parent_id IN (` + userChats + `))
+               OR (parent_type = 'profile' AND (uploader_id = ? OR parent_id = ?))`,
+			[]interface{}{userID, userID, userID, userID, userID, userID, userID}},
+		{"interactions", `DELETE FROM interactions
+            WHERE user_id = ?
+               OR (parent_type = 'post' AND parent_id IN (` + userPosts + `))
+               OR (parent_type = 'comment' AND parent_id IN (` + doomedComments + `))`,
+			[]interface{}{userID, userID, userID, userID}},
+		{"content_revisions", `DELETE FROM content_revisions
+            WHERE (parent_type = 'post' AND parent_id IN (` + userPosts + `))
+               OR (parent_type = 'comment' AND parent_id IN (` + doomedComments + `))`,
+			[]interface{}{userID, userID, userID}},
+		{"content_revision_editors", `UPDATE content_revisions SET editor_id = NULL WHERE editor_id = ?`, []interface{}{userID}},
+		{"notifications", `DELETE FROM notifications WHERE receiver_id = ? OR actor_id = ?`, []interface{}{userID, userID}},
+		{"post_private_viewers", `DELETE FROM post_private_viewers WHERE user_id = ? OR post_id IN (` + userPosts + `)`, []interface{}{userID, userID}},
+		{"post_categories", `DELETE FROM post_categories WHERE creator_id = ? OR post_id IN (` + userPosts + `)`, []interface{}{userID, userID}},
+		{"comments", `DELETE FROM comments WHERE comment_id IN (` + doomedComments + `)`, []interface{}{userID, userID}},
+		{"posts", `DELETE FROM posts WHERE poster_id = ?`, []interface{}{userID}},
+		{"chat_messages", `DELETE FROM chat_messages WHERE sender_id = ? OR receiver_id = ?`, []interface{}{userID, userID}},
+		{"follows", `DELETE FROM follows WHERE follower_user_id = ? OR followed_user_id = ?`, []interface{}{userID, userID}},
+		{"group_members", `DELETE FROM group_members WHERE member_id = ?`, []interface{}{userID}},
+		{"group_invitations", `UPDATE group_members SET inviter_id = NULL WHERE inviter_id = ?`, []interface{}{userID}},
+		{"group_moderators", `DELETE FROM group_moderators WHERE user_id = ?`, []interface{}{userID}},
+		{"group_moderator_assignments", `UPDATE group_moderators SET assigned_by = NULL WHERE assigned_by = ?`, []interface{}{userID}},
+		{"event_rsvp", `DELETE FROM event_rsvp WHERE responder_id = ?`, []interface{}{userID}},
+		{"sessions", `DELETE FROM sessions WHERE user_id = ?`, []interface{}{userID}},
+		{"login_attempts", `DELETE FROM login_attempts WHERE user_id = ? OR email = lower(?)`, []interface{}{userID, email}},
+		{"user_totp", `DELETE FROM user_totp WHERE user_id = ?`, []interface{}{userID}},
+		{"recovery_codes", `DELETE FROM recovery_codes WHERE user_id = ?`, []interface{}{userID}},
+		{"pending_2fa_logins", `DELETE FROM pending_2fa_logins WHERE user_id = ?`, []interface{}{userID}},
+		{"password_reset_tokens", `DELETE FROM password_reset_tokens WHERE user_id = ?`, []interface{}{userID}},
+		{"personal_access_tokens", `DELETE FROM personal_access_tokens WHERE user_id = ?`, []interface{}{userID}},
+		{"user_identities", `DELETE FROM user_identities WHERE user_id = ?`, []interface{}{userID}},
+		{"oidc_login_states", `DELETE FROM oidc_login_states WHERE link_user_id = ?`, []interface{}{userID}},
+	}
+	for _, step := range steps {
+		result, err := tx.Exec(step.query, step.args...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("erase %s: %w", step.table, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return nil, nil, err
+		}
+		report.Rows[step.table] = n
+	}
+
+	placeholderName, err := uniqueFirstName(tx, "Deleted user")
+	if err != nil {
+		return nil, nil, err
+	}
+	// A new UUID so old profile links and references in exports lead nowhere
+	newUUID, err := utils.GenerateUUID()
+	if err != nil {
+		return nil, nil, err
+	}
+	result, err = tx.Exec(`
+        UPDATE users SET user_uuid = ?, email = ?, password = '', first_name = ?, last_name = '',
+            date_of_birth = '0001-01-01', nickname = NULL, about_me = NULL, avatar = '/uploads/default_avatar.jpg',
+            privacy = 'private', role = 'user', status = 'inactive', email_verified_at = NULL,
+            verification_sent_at = NULL, deleted_at = ?, updated_at = ?, updater_id = NULL
+        WHERE user_id = ?
+    `, newUUID, "deleted-"+newUUID+"@deleted.invalid", placeholderName, now, now, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("anonymise user: %w", err)
+	}
+	report.Rows["users"], _ = result.RowsAffected()
+
+	filesData, err := json.Marshal(files)
+	if err != nil {
+		return nil, nil, err
+	}
+	reportData, err := json.Marshal(report)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, err = tx.Exec(`UPDATE account_deletions SET pending_files = ?, report = ? WHERE deletion_id = ?`,
+		string(filesData), string(reportData), deletionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return files, report, nil
+}
+
+// appendUpload adds a plain upload file name once, ignoring anything that could point outside the upload folder
+func appendUpload(files []string, name string) []string {
+	if name == "" || name == "." || name != filepath.Base(name) {
+		return files
+	}
+	for _, f := range files {
+		if f == name {
+			return files
+		}
+	}
+	return append(files, name)
+}
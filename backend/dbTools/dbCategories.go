@@ -0,0 +1,116 @@
+package dbTools
+
+import (
+	"database/sql"
+	"social_network/policy"
+	"strings"
+	"time"
+)
+
+// TagCount is an entry of the tag directory
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Posts int    `json:"posts"`
+}
+
+// GetPostCategories returns the active tags of a post
+func (d *DB) GetPostCategories(postID int) ([]string, error) {
+	defer observe("GetPostCategories", time.Now())
+	posts := []PostResponse{{PostID: postID}}
+	if err := d.attachPostCategories(posts); err != nil {
+		return nil, err
+	}
+	return posts[0].Categories, nil
+}
+
+// savePostCategories adds tags to a post, reactivating ones removed by an edit
+func savePostCategories(tx *sql.Tx, postID, userID int, tags []string) error {
+	stmt, err := tx.Prepare(`
+        INSERT INTO post_categories (creator_id, post_id, category_name, created_at)
+        VALUES (?, ?, ?, datetime('now'))
+        ON CONFLICT(post_id, category_name) DO UPDATE SET
+            status = 'active',
+            updated_at = datetime('now'),
+            updater_id = excluded.creator_id
+        WHERE post_categories.status != 'active'
+    `)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for _, tag := range tags {
+		if _, err := stmt.Exec(userID, postID, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTagDirectory lists the tags of the posts the viewer may see, with how
+// many of those posts have each tag, most used first
+func (d *DB) GetTagDirectory(viewerID, limit int) ([]TagCount, error) {
+	defer observe("GetTagDirectory", time.Now())
+	rows, err := d.db.QueryContext(d.Context(), `
+        SELECT pc.category_name, COUNT(*) AS posts
+        FROM post_categories pc
+        JOIN posts p ON p.post_id = pc.post_id
+        JOIN users u ON p.poster_id = u.user_id AND u.status = 'active'
+        WHERE pc.status = 'active'
+          AND p.status = 'active'
+          AND `+policy.VisibleSQL+`
+        GROUP BY pc.category_name
+        ORDER BY posts DESC, pc.category_name
+        LIMIT ?
+    `, append(policy.VisibleArgs(viewerID), limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []TagCount{}
+	for rows.Next() {
+		var t TagCount
+		if err := rows.Scan(&t.Tag, &t.Posts); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+// attachPostCategories fills in the tags of a list of posts
+func (d *DB) attachPostCategories(posts []PostResponse) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(posts))
+	args := make([]interface{}, len(posts))
+	index := make(map[int]int, len(posts))
+	for i, p := range posts {
+		placeholders[i] = "?"
+		args[i] = p.PostID
+		index[p.PostID] = i
+		posts[i].Categories = []string{}
+	}
+	rows, err := d.db.QueryContext(d.Context(), `
+        SELECT post_id, category_name
+        FROM post_categories
+        WHERE post_id IN (`+strings.Join(placeholders, ",")+`) AND status = 'active'
+        ORDER BY category_id
+    `, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var tag string
+		if err := rows.Scan(&postID, &tag); err != nil {
+			return err
+		}
+		i := index[postID]
+		posts[i].Categories = append(posts[i].Categories, tag)
+	}
+	return rows.Err()
+}
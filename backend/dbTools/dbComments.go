@@ -0,0 +1,190 @@
+package dbTools
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// commentColumns are the columns scanComment reads, from comments aliased c,
+// their posts p, commenters u and attachments f (see commentJoins)
+const commentColumns = `
+            c.comment_id,
+            c.commenter_id,
+            p.post_uuid,
+            c.group_id,
+            c.content,
+            p.privacy,
+            c.status,
+            c.created_at,
+            c.edited_at,
+            CAST(c.created_at AS TEXT),
+            COALESCE(u.nickname, '') as nickname,
+            u.avatar,
+            COALESCE(f.file_id, 0) as file_id,
+            f.filename_new`
+
+const commentJoins = `
+        JOIN posts p ON c.post_id = p.post_id
+        JOIN users u ON c.commenter_id = u.user_id
+        LEFT JOIN files f ON f.parent_type = 'comment' AND f.parent_id = c.comment_id AND f.status = 'active'`
+
+func scanComment(rows *sql.Rows) (CommentResponse, error) {
+	var comment CommentResponse
+	var groupID sql.NullInt64
+	var fileID sql.NullInt64
+	var filenameNew sql.NullString
+	var editedAt sql.NullTime
+
+	err := rows.Scan(
+		&comment.CommentID,
+		&comment.CommenterID,
+		&comment.PostUUID,
+		&groupID,
+		&comment.Content,
+		&comment.PostPrivacy,
+		&comment.CommentStatus,
+		&comment.CommentCreatedAt,
+		&editedAt,
+		&comment.sortKey,
+		&comment.Nickname,
+		&comment.Avatar,
+		&fileID,
+		&filenameNew,
+	)
+	if err != nil {
+		return comment, err
+	}
+	if groupID.Valid {
+		gid := int(groupID.Int64)
+		comment.GroupID = &gid
+	}
+	if fileID.Valid {
+		fid := int(fileID.Int64)
+		comment.FileID = &fid
+	}
+	if filenameNew.Valid {
+		fn := filenameNew.String
+		comment.FilenameNew = &fn
+	}
+	comment.setEdited(editedAt)
+	return comment, nil
+}
+
+// GetComments returns a page of the active comments of a post, newest first,
+// with their reactions as seen by viewerID, and the cursor of the next page
+func (d *DB) GetComments(postID, viewerID int, page Page) ([]CommentResponse, string, error) {
+	defer observe("GetComments", time.Now())
+	keyset, keysetArgs := page.keysetSQL("c.created_at", "c.comment_id")
+	rows, err := d.db.QueryContext(d.Context(), `
+        SELECT `+commentColumns+`
+        FROM comments c`+commentJoins+`
+        WHERE c.post_id = ? AND c.status = 'active'
+        `+keyset+`
+        ORDER BY c.created_at DESC, c.comment_id DESC
+        LIMIT ?
+    `, append(append([]interface{}{postID}, keysetArgs...), page.limitArg())...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	comments := []CommentResponse{}
+	for rows.Next() {
+		comment, err := scanComment(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		comments = append(comments, comment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	comments, next := page.nextCommentPage(comments)
+	if err := d.attachCommentReactions(comments, viewerID); err != nil {
+		return nil, "", err
+	}
+	return comments, next, nil
+}
+
+// attachCommentPreviews fills in the comment count and the latest preview
+// comments of a page of posts, whatever its size, in two queries
+func (d *DB) attachCommentPreviews(posts []PostResponse, viewerID, preview int) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(posts))
+	args := make([]interface{}, len(posts))
+	byID := make(map[int]int, len(posts))
+	byUUID := make(map[string]int, len(posts))
+	for i, p := range posts {
+		placeholders[i] = "?"
+		args[i] = p.PostID
+		byID[p.PostID] = i
+		byUUID[p.PostUUID] = i
+		posts[i].Comments = []CommentResponse{}
+		posts[i].CommentCount = 0
+	}
+	in := strings.Join(placeholders, ",")
+
+	rows, err := d.db.QueryContext(d.Context(), `
+        SELECT post_id, COUNT(*) FROM comments
+        WHERE post_id IN (`+in+`) AND status = 'active'
+        GROUP BY post_id
+    `, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID, count int
+		if err := rows.Scan(&postID, &count); err != nil {
+			return err
+		}
+		posts[byID[postID]].CommentCount = count
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if preview <= 0 {
+		return nil
+	}
+
+	previewRows, err := d.db.QueryContext(d.Context(), `
+        SELECT `+commentColumns+`
+        FROM (
+            SELECT comment_id,
+                   ROW_NUMBER() OVER (PARTITION BY post_id ORDER BY created_at DESC, comment_id DESC) AS position
+            FROM comments
+            WHERE post_id IN (`+in+`) AND status = 'active'
+        ) latest
+        JOIN comments c ON c.comment_id = latest.comment_id`+commentJoins+`
+        WHERE latest.position <= ?
+        ORDER BY c.post_id, c.created_at DESC, c.comment_id DESC
+    `, append(args, preview)...)
+	if err != nil {
+		return err
+	}
+	defer previewRows.Close()
+
+	var all []CommentResponse
+	for previewRows.Next() {
+		comment, err := scanComment(previewRows)
+		if err != nil {
+			return err
+		}
+		all = append(all, comment)
+	}
+	if err := previewRows.Err(); err != nil {
+		return err
+	}
+	if err := d.attachCommentReactions(all, viewerID); err != nil {
+		return err
+	}
+	for _, comment := range all {
+		i := byUUID[comment.PostUUID]
+		posts[i].Comments = append(posts[i].Comments, comment)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package dbTools
+
+import (
+	"time"
+)
+
+// GetUserStatus returns the account status of a user: active, inactive or pending_verification
+func (d *DB) GetUserStatus(userID int) (string, error) {
+	defer observe("GetUserStatus", time.Now())
+	var status string
+	err := d.db.QueryRow(`SELECT status FROM users WHERE user_id = ?`, userID).Scan(&status)
+	return status, err
+}
+
+// MarkEmailVerified activates a pending account if email is still its address.
+// It returns false if there was nothing to verify.
+func (d *DB) MarkEmailVerified(userID int, email string) (bool, error) {
+	defer observe("MarkEmailVerified", time.Now())
+	result, err := d.db.Exec(`
+        UPDATE users SET status = 'active', email_verified_at = ?, updated_at = CURRENT_TIMESTAMP, updater_id = ?
+        WHERE user_id = ? AND lower(email) = ? AND status = 'pending_verification'
+    `, time.Now().UTC(), userID, userID, email)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// IsEmailVerified reports whether email is the verified address of the user
+func (d *DB) IsEmailVerified(userID int, email string) (bool, error) {
+	defer observe("IsEmailVerified", time.Now())
+	var count int
+	err := d.db.QueryRow(`
+        SELECT COUNT(*) FROM users
+        WHERE user_id = ? AND lower(email) = ? AND email_verified_at IS NOT NULL
+    `, userID, email).Scan(&count)
+	return count > 0, err
+}
+
+// ClaimVerificationEmail records that a verification email is about to be sent.
+// It returns false if the account is not pending or the last email was sent
+// less than minInterval ago.
+func (d *DB) ClaimVerificationEmail(userID int, minInterval time.Duration) (bool, error) {
+	defer observe("ClaimVerificationEmail", time.Now())
+	now := time.Now().UTC()
+	result, err := d.db.Exec(`
+        UPDATE users SET verification_sent_at = ?
+        WHERE user_id = ? AND status = 'pending_verification'
+          AND (verification_sent_at IS NULL OR verification_sent_at < ?)
+    `, now, userID, now.Add(-minInterval))
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
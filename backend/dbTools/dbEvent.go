@@ -2,10 +2,12 @@ package dbTools
 
 import (
 	"database/sql"
+	"time"
 )
 
 // CreateEvent creates a new event in the database for a group
 func (db *DB) CreateEvent(e *Event) (*Event, error) {
+	defer observe("CreateEvent", time.Now())
 	query := `INSERT INTO events (creator_id, group_id, title, description, event_date_time, status, updater_id) VALUES (?, ?, ?, ?, ?, ?, ?)`
 	result, err := db.db.Exec(query, e.CreatorID, e.GroupID, e.Title, e.Description, e.EventDateTime, e.Status, e.CreatorID)
 	if err != nil {
@@ -21,6 +23,7 @@ func (db *DB) CreateEvent(e *Event) (*Event, error) {
 
 // GetEventByID retrieves an event by its ID
 func (db *DB) GetEventByID(id int) (*Event, error) {
+	defer observe("GetEventByID", time.Now())
 	query := `SELECT event_id, creator_id, group_id, title, description, event_date_time, status, created_at FROM events WHERE event_id = ?`
 	row := db.db.QueryRow(query, id)
 	e := &Event{}
@@ -36,6 +39,7 @@ func (db *DB) GetEventByID(id int) (*Event, error) {
 
 // GetEventsByGroupID retrieves all events for a specific group
 func (db *DB) GetEventsByGroupID(groupID int) ([]*Event, error) {
+	defer observe("GetEventsByGroupID", time.Now())
 	query := `SELECT event_id, creator_id, group_id, title, description, event_date_time, status, created_at FROM events WHERE group_id = ?`
 	rows, err := db.db.Query(query, groupID)
 	if err != nil {
@@ -56,6 +60,7 @@ func (db *DB) GetEventsByGroupID(groupID int) ([]*Event, error) {
 
 // RespondToEvent allows a user to RSVP to an event
 func (db *DB) RespondToEvent(eventID, userID int, response string) error {
+	defer observe("RespondToEvent", time.Now())
 	// First, try to update existing RSVP
 	updateQuery := `UPDATE event_rsvp SET response = ?, updated_at = CURRENT_TIMESTAMP, updater_id = ? 
 	                WHERE event_id = ? AND responder_id = ?`
@@ -81,6 +86,7 @@ func (db *DB) RespondToEvent(eventID, userID int, response string) error {
 
 // GetEventRSVPs retrieves all RSVPs for an event
 func (db *DB) GetEventRSVPs(eventID int) ([]*EventRSVP, error) {
+	defer observe("GetEventRSVPs", time.Now())
 	query := `SELECT rsvp_id, event_id, responder_id, response, created_at FROM event_rsvp WHERE event_id = ?`
 	rows, err := db.db.Query(query, eventID)
 	if err != nil {
@@ -101,6 +107,7 @@ func (db *DB) GetEventRSVPs(eventID int) ([]*EventRSVP, error) {
 
 // GetUserEventResponse retrieves a user's RSVP response for a specific event
 func (db *DB) GetUserEventResponse(eventID, userID int) (string, error) {
+	defer observe("GetUserEventResponse", time.Now())
 	query := `SELECT response FROM event_rsvp WHERE event_id = ? AND responder_id = ?`
 	var response string
 	err := db.db.QueryRow(query, eventID, userID).Scan(&response)
@@ -115,6 +122,7 @@ func (db *DB) GetUserEventResponse(eventID, userID int) (string, error) {
 
 // GetUserEvents retrieves all events from groups that a user is a member of
 func (db *DB) GetUserEvents(userID int) ([]*Event, error) {
+	defer observe("GetUserEvents", time.Now())
 	query := `SELECT DISTINCT e.event_id, e.creator_id, e.group_id, e.title, e.description, e.event_date_time, e.status, e.created_at
 	          FROM events e
 	          JOIN group_members gm ON e.group_id = gm.group_id
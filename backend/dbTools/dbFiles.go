@@ -1,21 +1,25 @@
 package dbTools
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"io"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
 	"social_network/utils"
 	"strings"
+	"time"
 )
 
 // FileUpload handles file uploads and saves them to the db
 func (d *DB) FileUpload(file multipart.File, f *File, r *http.Request, w http.ResponseWriter) error {
-	uploadDir := "public/uploads"
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		fmt.Printf("Failed to create upload directory: %v\n", err)
+	defer observe("FileUpload", time.Now())
+	if err := os.MkdirAll(d.uploadsDir, 0755); err != nil {
+		slog.ErrorContext(d.Context(), "Failed to create upload directory", "dir", d.uploadsDir, "err", err)
 		return err
 	}
 
@@ -27,23 +31,25 @@ func (d *DB) FileUpload(file multipart.File, f *File, r *http.Request, w http.Re
 	if f.FileUUID == "" {
 		fileUUID, err := utils.GenerateUUID()
 		if err != nil {
-			fmt.Printf("File upload error: %v\n", err)
+			slog.ErrorContext(d.Context(), "Failed to generate file UUID", "err", err)
 			return err
 		}
 		f.FileUUID = fileUUID
 	}
 	filenameNew := f.FileUUID + ext
 	f.FilenameNew = filenameNew
-	dst, err := os.Create(filepath.Join(uploadDir, filenameNew))
+	dst, err := os.Create(filepath.Join(d.uploadsDir, filenameNew))
 	if err != nil {
-		fmt.Printf("File save error: %v\n", err)
+		slog.ErrorContext(d.Context(), "Failed to create upload file", "err", err)
 		return err
 	}
 	defer dst.Close()
-	if _, err := io.Copy(dst, file); err != nil {
-		fmt.Printf("File copy error: %v\n", err)
+	written, err := io.Copy(dst, file)
+	if err != nil {
+		slog.ErrorContext(d.Context(), "Failed to write upload file", "err", err)
 		return err
 	}
+	RecordUpload(f.ParentType, written)
 	f.FileID, err = d.InsertFile(f)
 	if err != nil {
 		return err
@@ -53,6 +59,7 @@ func (d *DB) FileUpload(file multipart.File, f *File, r *http.Request, w http.Re
 
 // InsertFile inserts a new file into the database and sets the FileID on success.
 func (d *DB) InsertFile(f *File) (int, error) {
+	defer observe("InsertFile", time.Now())
 	// Generate UUID for the file if not already set
 	if f.FileUUID == "" {
 		uuid, err := utils.GenerateUUID()
@@ -87,3 +94,43 @@ func (d *DB) InsertFile(f *File) (int, error) {
 	f.FileID = int(id)
 	return f.FileID, nil
 }
+
+// GetUploadPost returns the post an uploaded file is attached to, directly or
+// through a comment. attached is false for other uploads, such as avatars.
+// The post is nil when the file, its comment or its post has been removed.
+func (d *DB) GetUploadPost(ctx context.Context, filenameNew string) (post *Post, attached bool, err error) {
+	defer observe("GetUploadPost", time.Now())
+	var parentType, status string
+	var parentID int
+	err = d.db.QueryRowContext(ctx, `
+        SELECT parent_type, parent_id, status FROM files WHERE filename_new = ?
+    `, filenameNew).Scan(&parentType, &parentID, &status)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	switch parentType {
+	case "post":
+	case "comment":
+		err = d.db.QueryRowContext(ctx, `
+            SELECT post_id FROM comments WHERE comment_id = ? AND status = 'active'
+        `, parentID).Scan(&parentID)
+		if err == sql.ErrNoRows {
+			return nil, true, nil
+		}
+		if err != nil {
+			return nil, true, err
+		}
+	default:
+		return nil, false, nil
+	}
+	if status != "active" {
+		return nil, true, nil
+	}
+
+	post, err = d.GetPostByID(ctx, parentID)
+	return post, true, err
+}
@@ -29,6 +29,7 @@ func scanGroupMember(rows *sql.Rows) (*GroupMember, error) {
 
 // CreateGroup creates a new group in the database
 func (db *DB) CreateGroup(g *Group) (*Group, error) {
+	defer observe("CreateGroup", time.Now())
 	query := `INSERT INTO groups (title, description, creator_id) VALUES (?, ?, ?)`
 	result, err := db.db.Exec(query, g.Title, g.Description, g.CreatorID)
 	if err != nil {
@@ -55,6 +56,7 @@ func (db *DB) CreateGroup(g *Group) (*Group, error) {
 
 // GetGroupByID retrieves a group by its ID
 func (db *DB) GetGroupByID(id int) (*Group, error) {
+	defer observe("GetGroupByID", time.Now())
 	query := `SELECT g.group_id, g.title, g.description, g.creator_id, g.created_at,
 	          COALESCE(f.filename_new, '') as avatar
 	          FROM groups g
@@ -86,20 +88,22 @@ func (db *DB) GetGroupByID(id int) (*Group, error) {
 
 // GetAllGroups retrieves all groups for browsing with creator names, member counts, and optional user status
 func (db *DB) GetAllGroups(userID ...int) ([]map[string]interface{}, error) {
+	defer observe("GetAllGroups", time.Now())
 	if len(userID) > 0 && userID[0] > 0 {
 		return db.getAllGroupsWithUserStatus(userID[0])
 	}
 	return db.getAllGroupsBasic()
 }
 
-// getAllGroupsBasic retrieves groups without user-specific status
+// getAllGroupsBasic retrieves groups without user-specific status.
+// Groups of erased accounts stay listed for their members.
 func (db *DB) getAllGroupsBasic() ([]map[string]interface{}, error) {
 	query := `SELECT g.group_id, g.title, g.description, g.creator_id, g.created_at,
 	          COALESCE(u.nickname, u.first_name) as creator_name,
 	          COALESCE(COUNT(gm.member_id), 0) as member_count,
 	          COALESCE(f.filename_new, '') as avatar
 	          FROM groups g
-	          JOIN users u ON g.creator_id = u.user_id AND u.status = 'active'
+	          JOIN users u ON g.creator_id = u.user_id AND (u.status = 'active' OR u.deleted_at IS NOT NULL)
 	          LEFT JOIN group_members gm ON g.group_id = gm.group_id AND gm.status = 'accepted'
 	          LEFT JOIN files f ON f.parent_type = 'group' AND f.parent_id = g.group_id AND f.status = 'active'
 	          GROUP BY g.group_id, g.title, g.description, g.creator_id, g.created_at, u.nickname, u.first_name, f.filename_new`
@@ -129,7 +133,7 @@ func (db *DB) getAllGroupsWithUserStatus(userID int) ([]map[string]interface{},
 	          COALESCE(gm_user.status, 'none') as user_status,
 	          COALESCE(f.filename_new, '') as avatar
 	          FROM groups g
-	          JOIN users u ON g.creator_id = u.user_id AND u.status = 'active'
+	          JOIN users u ON g.creator_id = u.user_id AND (u.status = 'active' OR u.deleted_at IS NOT NULL)
 	          LEFT JOIN group_members gm_all ON g.group_id = gm_all.group_id AND gm_all.status = 'accepted'
 	          LEFT JOIN group_members gm_user ON g.group_id = gm_user.group_id AND gm_user.member_id = ?
 	          LEFT JOIN files f ON f.parent_type = 'group' AND f.parent_id = g.group_id AND f.status = 'active'
@@ -200,6 +204,7 @@ func scanGroupResult(rows *sql.Rows, includeUserStatus bool) (map[string]interfa
 
 // GetGroupsByUserID retrieves all groups a user is a member of (accepted status)
 func (db *DB) GetGroupsByUserID(userID int) ([]*Group, error) {
+	defer observe("GetGroupsByUserID", time.Now())
 	query := `SELECT g.group_id, g.title, g.description, g.creator_id, g.created_at,
 	          COALESCE(f.filename_new, '') as avatar
 	          FROM groups g
@@ -235,6 +240,7 @@ func (db *DB) GetGroupsByUserID(userID int) ([]*Group, error) {
 
 // InviteToGroup invites a user to a group
 func (db *DB) InviteToGroup(groupID, inviterID, inviteeID int) error {
+	defer observe("InviteToGroup", time.Now())
 	query := `INSERT INTO group_members (inviter_id, member_id, group_id, status) VALUES (?, ?, ?, 'invited')`
 	_, err := db.db.Exec(query, inviterID, inviteeID, groupID)
 	return err
@@ -242,6 +248,7 @@ func (db *DB) InviteToGroup(groupID, inviterID, inviteeID int) error {
 
 // RequestToJoinGroup allows a user to request to join a group
 func (db *DB) RequestToJoinGroup(groupID, userID int) error {
+	defer observe("RequestToJoinGroup", time.Now())
 	query := `INSERT INTO group_members (member_id, group_id, status) VALUES (?, ?, 'requested')`
 	_, err := db.db.Exec(query, userID, groupID)
 	return err
@@ -249,6 +256,7 @@ func (db *DB) RequestToJoinGroup(groupID, userID int) error {
 
 // UpdateMembershipStatus updates the status of a group membership
 func (db *DB) UpdateMembershipStatus(groupID, memberID int, status string) error {
+	defer observe("UpdateMembershipStatus", time.Now())
 	query := `UPDATE group_members SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE group_id = ? AND member_id = ?`
 	_, err := db.db.Exec(query, status, groupID, memberID)
 	return err
@@ -256,18 +264,21 @@ func (db *DB) UpdateMembershipStatus(groupID, memberID int, status string) error
 
 // GetGroupMembers retrieves all members of a group with their status
 func (db *DB) GetGroupMembers(groupID int) ([]*GroupMember, error) {
+	defer observe("GetGroupMembers", time.Now())
 	query := `SELECT membership_id, inviter_id, member_id, group_id, status, created_at FROM group_members WHERE group_id = ?`
 	return db.queryGroupMembers(query, groupID)
 }
 
 // GetInvitationsByUserID retrieves all invitations for a user
 func (db *DB) GetInvitationsByUserID(userID int) ([]*GroupMember, error) {
+	defer observe("GetInvitationsByUserID", time.Now())
 	query := `SELECT membership_id, inviter_id, member_id, group_id, status, created_at FROM group_members WHERE member_id = ? AND status = 'invited'`
 	return db.queryGroupMembers(query, userID)
 }
 
 // GetRequestsByGroupID retrieves all join requests for a group
 func (db *DB) GetRequestsByGroupID(groupID int) ([]*GroupMember, error) {
+	defer observe("GetRequestsByGroupID", time.Now())
 	query := `SELECT membership_id, inviter_id, member_id, group_id, status, created_at FROM group_members WHERE group_id = ? AND status = 'requested'`
 	return db.queryGroupMembers(query, groupID)
 }
@@ -293,6 +304,7 @@ func (db *DB) queryGroupMembers(query string, param int) ([]*GroupMember, error)
 
 // IsGroupMember checks if a user is a member of a group with accepted status
 func (db *DB) IsGroupMember(groupID, userID int) (bool, error) {
+	defer observe("IsGroupMember", time.Now())
 	query := `SELECT COUNT(*) FROM group_members WHERE group_id = ? AND member_id = ? AND status = 'accepted'`
 	var count int
 	err := db.db.QueryRow(query, groupID, userID).Scan(&count)
@@ -304,6 +316,7 @@ func (db *DB) IsGroupMember(groupID, userID int) (bool, error) {
 
 // IsGroupCreator checks if a user is the creator of a group
 func (db *DB) IsGroupCreator(groupID, userID int) (bool, error) {
+	defer observe("IsGroupCreator", time.Now())
 	query := `SELECT COUNT(*) FROM groups WHERE group_id = ? AND creator_id = ?`
 	var count int
 	err := db.db.QueryRow(query, groupID, userID).Scan(&count)
@@ -0,0 +1,75 @@
+package dbTools
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// MigrationStatus compares the schema version of the database with the
+// newest migration in the migrations directory
+type MigrationStatus struct {
+	Current  int  `json:"current"` // -1 when no migration has run
+	Expected int  `json:"expected"`
+	Dirty    bool `json:"dirty"`
+}
+
+// UpToDate reports whether every migration has been applied cleanly
+func (s MigrationStatus) UpToDate() bool {
+	return !s.Dirty && s.Current == s.Expected
+}
+
+// Ping checks that the database answers
+func (d *DB) Ping(ctx context.Context) error {
+	defer observe("Ping", time.Now())
+	return d.db.PingContext(ctx)
+}
+
+// MigrationStatus reads the applied schema version and compares it with the
+// newest migration found when the database was opened
+func (d *DB) MigrationStatus(ctx context.Context) (MigrationStatus, error) {
+	defer observe("MigrationStatus", time.Now())
+	status := MigrationStatus{Current: -1, Expected: d.expectedVersion}
+	err := d.db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&status.Current, &status.Dirty)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return status, fmt.Errorf("schema version: %w", err)
+	}
+	return status, nil
+}
+
+// latestMigration returns the version of the newest migration in dir
+func latestMigration(dir string) (int, error) {
+	src, err := source.Open("file://" + filepath.ToSlash(dir))
+	if err != nil {
+		return 0, fmt.Errorf("load migrations: %w", err)
+	}
+	defer src.Close()
+	latest := 0
+	version, err := src.First()
+	for err == nil {
+		latest = int(version)
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return 0, fmt.Errorf("read migrations: %w", err)
+	}
+	return latest, nil
+}
+
+// CheckUploadsWritable creates and removes a file in the uploads directory
+func (d *DB) CheckUploadsWritable() error {
+	f, err := os.CreateTemp(d.uploadsDir, ".readyz-*")
+	if err != nil {
+		return err
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
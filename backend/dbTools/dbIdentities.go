@@ -0,0 +1,204 @@
+package dbTools
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrIdentityLinked is returned when an external identity already belongs to
+// another account, or the account already has an identity at that provider
+var ErrIdentityLinked = errors.New("identity already linked")
+
+// CreateOIDCLoginState stores the state of an authorization code flow until the callback
+func (d *DB) CreateOIDCLoginState(stateHash string, s OIDCLoginState, expiresAt time.Time) error {
+	defer observe("CreateOIDCLoginState", time.Now())
+	var linkUserID sql.NullInt64
+	if s.LinkUserID > 0 {
+		linkUserID = sql.NullInt64{Int64: int64(s.LinkUserID), Valid: true}
+	}
+	_, err := d.db.Exec(`
+        INSERT INTO oidc_login_states (state_hash, provider, nonce, code_verifier, link_user_id, redirect_path, expires_at)
+        VALUES (?, ?, ?, ?, ?, ?, ?)
+    `, stateHash, s.Provider, s.Nonce, s.CodeVerifier, linkUserID, s.RedirectPath, expiresAt.UTC())
+	return err
+}
+
+// ConsumeOIDCLoginState deletes and returns an unexpired state for the provider.
+// It returns sql.ErrNoRows if there is none, so each state can only be used once.
+func (d *DB) ConsumeOIDCLoginState(stateHash, provider string) (*OIDCLoginState, error) {
+	defer observe("ConsumeOIDCLoginState", time.Now())
+	s := &OIDCLoginState{}
+	var linkUserID sql.NullInt64
+	err := d.db.QueryRow(`
+        DELETE FROM oidc_login_states
+        WHERE state_hash = ? AND provider = ? AND expires_at > ?
+        RETURNING provider, nonce, code_verifier, link_user_id, redirect_path
+    `, stateHash, provider, time.Now().UTC()).Scan(&s.Provider, &s.Nonce, &s.CodeVerifier, &linkUserID, &s.RedirectPath)
+	if err != nil {
+		return nil, err
+	}
+	s.LinkUserID = int(linkUserID.Int64)
+	return s, nil
+}
+
+// GetUserIDByIdentity returns the account linked to an external identity, or sql.ErrNoRows
+func (d *DB) GetUserIDByIdentity(provider, subject string) (int, error) {
+	defer observe("GetUserIDByIdentity", time.Now())
+	var userID int
+	err := d.db.QueryRow(`
+        SELECT i.user_id FROM user_identities i
+        JOIN users u ON u.user_id = i.user_id AND u.deleted_at IS NULL
+        WHERE i.provider = ? AND i.subject = ?
+    `, provider, subject).Scan(&userID)
+	return userID, err
+}
+
+// GetUserIDByEmail returns the account using email (case-insensitive) and its status, or sql.ErrNoRows
+func (d *DB) GetUserIDByEmail(email string) (int, string, error) {
+	defer observe("GetUserIDByEmail", time.Now())
+	var userID int
+	var status string
+	err := d.db.QueryRow(`SELECT user_id, status FROM users WHERE lower(email) = lower(?)`, email).Scan(&userID, &status)
+	return userID, status, err
+}
+
+// LinkIdentity links an external identity to a user. When emailVerified is
+// true and matches the account's email, a pending account is verified too.
+func (d *DB) LinkIdentity(userID int, provider, subject, email string, emailVerified bool) error {
+	defer observe("LinkIdentity", time.Now())
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		var count int
+		if err := tx.QueryRow(`
+            SELECT COUNT(*) FROM user_identities
+            WHERE (provider = ? AND subject = ?) OR (provider = ? AND user_id = ?)
+        `, provider, subject, provider, userID).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrIdentityLinked
+		}
+
+		if err := insertIdentity(tx, userID, provider, subject, email); err != nil {
+			return err
+		}
+		if emailVerified {
+			_, err := tx.Exec(`
+                UPDATE users SET status = 'active', email_verified_at = ?, updated_at = CURRENT_TIMESTAMP
+                WHERE user_id = ? AND status = 'pending_verification' AND lower(email) = lower(?)
+            `, time.Now().UTC(), userID, email)
+			return err
+		}
+		return nil
+	})
+}
+
+// CreateOIDCUser creates an account from ID token claims and links the identity.
+// The account is active right away if the provider verified the email.
+func (d *DB) CreateOIDCUser(u *User, passwordHash, provider, subject string, emailVerified bool) (int, error) {
+	defer observe("CreateOIDCUser", time.Now())
+	var userID int
+	err := d.WithTransaction(func(tx *sql.Tx) error {
+		firstName, err := uniqueFirstName(tx, u.FirstName)
+		if err != nil {
+			return err
+		}
+		u.FirstName = firstName
+
+		status, verifiedAt := "pending_verification", sql.NullTime{}
+		if emailVerified {
+			status, verifiedAt = "active", sql.NullTime{Time: time.Now().UTC(), Valid: true}
+		}
+		u.Status = status
+
+		now := time.Now().UTC()
+		result, err := tx.Exec(`
+            INSERT INTO users (user_uuid, email, password, first_name, last_name, date_of_birth,
+                               avatar, privacy, role, status, email_verified_at, created_at, updated_at)
+            VALUES (?, ?, ?, ?, ?, ?, ?, 'private', 'user', ?, ?, ?, ?)
+        `, u.UserUUID, u.Email, passwordHash, u.FirstName, u.LastName, u.DateOfBirth.Format("2006-01-02"),
+			u.Avatar, status, verifiedAt, now, now)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		userID = int(id)
+		return insertIdentity(tx, userID, provider, subject, u.Email)
+	})
+	return userID, err
+}
+
+// TouchIdentity records a login through an external identity
+func (d *DB) TouchIdentity(provider, subject string) error {
+	defer observe("TouchIdentity", time.Now())
+	_, err := d.db.Exec(`UPDATE user_identities SET last_login_at = ? WHERE provider = ? AND subject = ?`,
+		time.Now().UTC(), provider, subject)
+	return err
+}
+
+// GetIdentities lists the external identities linked to a user
+func (d *DB) GetIdentities(userID int) ([]UserIdentity, error) {
+	defer observe("GetIdentities", time.Now())
+	rows, err := d.db.Query(`
+        SELECT identity_id, user_id, provider, subject, COALESCE(email, ''), created_at, last_login_at
+        FROM user_identities WHERE user_id = ? ORDER BY created_at
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []UserIdentity{}
+	for rows.Next() {
+		var i UserIdentity
+		var lastLoginAt sql.NullTime
+		if err := rows.Scan(&i.IdentityID, &i.UserID, &i.Provider, &i.Subject, &i.Email, &i.CreatedAt, &lastLoginAt); err != nil {
+			return nil, err
+		}
+		if lastLoginAt.Valid {
+			i.LastLoginAt = &lastLoginAt.Time
+		}
+		identities = append(identities, i)
+	}
+	return identities, rows.Err()
+}
+
+// UnlinkIdentity removes one of the user's identities. It returns false if it was not found.
+func (d *DB) UnlinkIdentity(userID, identityID int) (bool, error) {
+	defer observe("UnlinkIdentity", time.Now())
+	result, err := d.db.Exec(`DELETE FROM user_identities WHERE identity_id = ? AND user_id = ?`, identityID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+func insertIdentity(tx *sql.Tx, userID int, provider, subject, email string) error {
+	_, err := tx.Exec(`
+        INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+        VALUES (?, ?, ?, ?, ?)
+    `, userID, provider, subject, email, time.Now().UTC())
+	return err
+}
+
+// uniqueFirstName returns base, or base with a number appended, such that no
+// other user has that first name (first_name is unique in the schema)
+func uniqueFirstName(tx *sql.Tx, base string) (string, error) {
+	candidate := base
+	for n := 2; n < 1000; n++ {
+		var count int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM users WHERE first_name = ?`, candidate).Scan(&count); err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s %d", base, n)
+	}
+	return "", fmt.Errorf("no free first name for %q", base)
+}
@@ -0,0 +1,118 @@
+package dbTools
+
+import (
+	"strings"
+	"time"
+)
+
+// Reactions a user can have on a post or comment
+const (
+	ReactionLike    = "like"
+	ReactionDislike = "dislike"
+)
+
+// ValidReaction reports whether reaction is a like or a dislike
+func ValidReaction(reaction string) bool {
+	return reaction == ReactionLike || reaction == ReactionDislike
+}
+
+// ToggleReaction applies a like or dislike by the user to a post or comment.
+// Repeating the user's current reaction cancels it; the other reaction replaces
+// it. It returns the user's reaction afterwards, "" when it was cancelled.
+func (d *DB) ToggleReaction(userID int, parentType string, parentID int, reaction string) (string, error) {
+	defer observe("ToggleReaction", time.Now())
+	// One statement, so two quick clicks cannot both insert or both cancel
+	var result string
+	err := d.db.QueryRowContext(d.Context(), `
+        INSERT INTO interactions (user_id, interaction_type, parent_type, parent_id, status, created_at)
+        VALUES (?, ?, ?, ?, 'active', datetime('now'))
+        ON CONFLICT(user_id, parent_type, parent_id) DO UPDATE SET
+            interaction_type = CASE
+                WHEN interactions.status = 'active' AND interactions.interaction_type = excluded.interaction_type THEN 'cancelled'
+                ELSE excluded.interaction_type
+            END,
+            status = 'active',
+            updated_at = datetime('now'),
+            updater_id = excluded.user_id
+        RETURNING interaction_type
+    `, userID, reaction, parentType, parentID).Scan(&result)
+	if err != nil {
+		return "", err
+	}
+	if result == "cancelled" {
+		return "", nil
+	}
+	return result, nil
+}
+
+// GetReactions counts the likes and dislikes of posts or comments and finds
+// the viewer's own reaction. Parents nobody reacted to are missing from the map.
+func (d *DB) GetReactions(parentType string, parentIDs []int, viewerID int) (map[int]Reactions, error) {
+	defer observe("GetReactions", time.Now())
+	reactions := make(map[int]Reactions)
+	if len(parentIDs) == 0 {
+		return reactions, nil
+	}
+
+	placeholders := make([]string, len(parentIDs))
+	args := []interface{}{viewerID, parentType}
+	for i, id := range parentIDs {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	rows, err := d.db.QueryContext(d.Context(), `
+        SELECT parent_id,
+               SUM(interaction_type = 'like'),
+               SUM(interaction_type = 'dislike'),
+               COALESCE(MAX(CASE WHEN user_id = ? AND interaction_type != 'cancelled' THEN interaction_type END), '')
+        FROM interactions
+        WHERE parent_type = ? AND parent_id IN (`+strings.Join(placeholders, ",")+`) AND status = 'active'
+        GROUP BY parent_id
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var parentID int
+		var r Reactions
+		if err := rows.Scan(&parentID, &r.Likes, &r.Dislikes, &r.MyReaction); err != nil {
+			return nil, err
+		}
+		reactions[parentID] = r
+	}
+	return reactions, rows.Err()
+}
+
+// attachPostReactions fills in the reactions of a list of posts
+func (d *DB) attachPostReactions(posts []PostResponse, viewerID int) error {
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.PostID
+	}
+	reactions, err := d.GetReactions("post", ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].Reactions = reactions[posts[i].PostID]
+	}
+	return nil
+}
+
+// attachCommentReactions fills in the reactions of a list of comments
+func (d *DB) attachCommentReactions(comments []CommentResponse, viewerID int) error {
+	ids := make([]int, len(comments))
+	for i, c := range comments {
+		ids[i] = int(c.CommentID)
+	}
+	reactions, err := d.GetReactions("comment", ids, viewerID)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		comments[i].Reactions = reactions[int(comments[i].CommentID)]
+	}
+	return nil
+}
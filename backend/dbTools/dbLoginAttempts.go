@@ -0,0 +1,66 @@
+package dbTools
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// LoginAttemptFilter narrows down GetLoginAttempts. Zero values mean "any".
+type LoginAttemptFilter struct {
+	Email      string
+	IPAddress  string
+	FailedOnly bool
+	Limit      int
+}
+
+// GetLoginAttempts returns the most recent login attempts matching the filter
+func (d *DB) GetLoginAttempts(filter LoginAttemptFilter) ([]LoginAttempt, error) {
+	defer observe("GetLoginAttempts", time.Now())
+	conditions := []string{"1 = 1"}
+	args := []interface{}{}
+	if filter.Email != "" {
+		conditions = append(conditions, "email = ?")
+		args = append(args, strings.ToLower(strings.TrimSpace(filter.Email)))
+	}
+	if filter.IPAddress != "" {
+		conditions = append(conditions, "ip_address = ?")
+		args = append(args, filter.IPAddress)
+	}
+	if filter.FailedOnly {
+		conditions = append(conditions, "success = 0")
+	}
+	if filter.Limit <= 0 || filter.Limit > 500 {
+		filter.Limit = 100
+	}
+	args = append(args, filter.Limit)
+
+	rows, err := d.db.Query(`
+        SELECT attempt_id, email, user_id, ip_address, COALESCE(user_agent, ''), success,
+               COALESCE(failure_reason, ''), created_at
+        FROM login_attempts
+        WHERE `+strings.Join(conditions, " AND ")+`
+        ORDER BY attempt_id DESC
+        LIMIT ?
+    `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	attempts := []LoginAttempt{}
+	for rows.Next() {
+		var a LoginAttempt
+		var userID sql.NullInt64
+		if err := rows.Scan(&a.AttemptID, &a.Email, &userID, &a.IPAddress, &a.UserAgent,
+			&a.Success, &a.FailureReason, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if userID.Valid {
+			uid := int(userID.Int64)
+			a.UserID = &uid
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
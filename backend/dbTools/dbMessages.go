@@ -1,9 +1,13 @@
 package dbTools
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // AddMessageToDB inserts a new message and returns its new chat_id.
 func (database *DB) AddMessageToDB(msg *ChatMessage) (int, error) {
+	defer observe("AddMessageToDB", time.Now())
 	const insertSQL = `
 		INSERT INTO chat_messages
 		  (sender_id, receiver_id, group_id, content, status, updated_at, updater_id)
@@ -33,6 +37,7 @@ func (database *DB) AddMessageToDB(msg *ChatMessage) (int, error) {
 }
 
 func (database *DB) GetAllMessagesFromDB() ([]ChatMessage, error) {
+	defer observe("GetAllMessagesFromDB", time.Now())
 	const selectSQL = `
 		SELECT
 		  chat_id, sender_id, receiver_id, group_id,
@@ -81,6 +86,7 @@ func (database *DB) GetAllMessagesFromDB() ([]ChatMessage, error) {
 }
 
 func (database *DB) GetMessagesBetweenUsers(user1ID, user2ID int) ([]ChatMessage, error) {
+	defer observe("GetMessagesBetweenUsers", time.Now())
 	var (
 		qry  string
 		args []any
@@ -130,6 +136,7 @@ func (database *DB) GetMessagesBetweenUsers(user1ID, user2ID int) ([]ChatMessage
 }
 
 func (database *DB) GetMessagesForGroup(groupID int) ([]ChatMessage, error) {
+	defer observe("GetMessagesForGroup", time.Now())
 	qry := `
             SELECT chat_id, sender_id, group_id, content, created_at
               FROM chat_messages
@@ -1,8 +1,9 @@
 package dbTools
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 )
 
 // NotificationService handles all notification-related database operations
@@ -15,11 +16,19 @@ func NewNotificationService(db *DB) *NotificationService {
 	return &NotificationService{db: db}
 }
 
+// Context returns the context of the database the service works on
+func (ns *NotificationService) Context() context.Context {
+	return ns.db.Context()
+}
+
 // CreateNotification creates a new notification in the database
 func (ns *NotificationService) CreateNotification(receiverID, actorID int, actionType, parentType string, parentID int, content string) error {
 	query := `INSERT INTO notifications (receiver_id, actor_id, action_type, parent_type, parent_id, content, status, created_at)
 	          VALUES (?, ?, ?, ?, ?, ?, 'unread', datetime('now'))`
 	_, err := ns.db.Exec(query, receiverID, actorID, actionType, parentType, parentID, content)
+	if err == nil {
+		notificationsCreated.Inc(actionType)
+	}
 	return err
 }
 
@@ -187,7 +196,7 @@ func (nh *NotificationHelpers) CreateGroupEventNotification(creatorID, groupID,
 		if member.Status == "accepted" && member.MemberID != creatorID {
 			err := nh.service.CreateNotification(member.MemberID, creatorID, "group_event", "group", groupID, content)
 			if err != nil {
-				log.Printf("Failed to create event notification for member %d: %v", member.MemberID, err)
+				slog.ErrorContext(nh.db.Context(), "Failed to create event notification", "member_id", member.MemberID, "err", err)
 				// Continue with other members even if one fails
 			}
 		}
@@ -196,6 +205,30 @@ func (nh *NotificationHelpers) CreateGroupEventNotification(creatorID, groupID,
 	return nil
 }
 
+// CreateLikeNotification tells the author of a post or comment that it was
+// liked. A user who likes the same thing again after cancelling does not
+// notify the author a second time.
+func (nh *NotificationHelpers) CreateLikeNotification(likerID, authorID int, parentType string, parentID int) error {
+	if likerID == authorID {
+		return nil
+	}
+	var notified bool
+	err := nh.db.QueryRow(`
+        SELECT EXISTS(SELECT 1 FROM notifications
+                      WHERE receiver_id = ? AND actor_id = ? AND action_type = 'like' AND parent_type = ? AND parent_id = ?)
+    `, authorID, likerID, parentType, parentID).Scan(&notified)
+	if err != nil || notified {
+		return err
+	}
+
+	likerName, err := nh.getUserNickname(likerID)
+	if err != nil {
+		likerName = "Someone"
+	}
+	content := fmt.Sprintf("%s liked your %s", likerName, parentType)
+	return nh.service.CreateNotification(authorID, likerID, "like", parentType, parentID, content)
+}
+
 // UpdateFollowRequestNotificationStatus updates follow request notification status to read
 func (nh *NotificationHelpers) UpdateFollowRequestNotificationStatus(followID, updaterID int) error {
 	query := `UPDATE notifications
@@ -207,7 +240,7 @@ func (nh *NotificationHelpers) UpdateFollowRequestNotificationStatus(followID, u
 	}
 
 	rowsAffected, _ := result.RowsAffected()
-	log.Printf("Updated %d notification(s) for follow_id %d to status 'read'", rowsAffected, followID)
+	slog.DebugContext(nh.db.Context(), "Marked follow request notifications read", "follow_id", followID, "rows", rowsAffected)
 	return nil
 }
 
@@ -222,7 +255,7 @@ func (nh *NotificationHelpers) UpdateGroupInvitationNotificationStatus(groupID,
 	}
 
 	rowsAffected, _ := result.RowsAffected()
-	log.Printf("Updated %d group invitation notification(s) for group_id %d to status '%s'", rowsAffected, groupID, status)
+	slog.DebugContext(nh.db.Context(), "Updated group invitation notifications", "group_id", groupID, "status", status, "rows", rowsAffected)
 	return nil
 }
 
@@ -237,7 +270,7 @@ func (nh *NotificationHelpers) UpdateGroupJoinRequestNotificationStatus(groupID,
 	}
 
 	rowsAffected, _ := result.RowsAffected()
-	log.Printf("Updated %d group join request notification(s) for group_id %d and requester_id %d to status '%s'", rowsAffected, groupID, requesterID, status)
+	slog.DebugContext(nh.db.Context(), "Updated group join request notifications", "group_id", groupID, "requester_id", requesterID, "status", status, "rows", rowsAffected)
 	return nil
 }
 
@@ -271,7 +304,7 @@ func (nh *NotificationHelpers) CreateGroupPostNotification(posterID, groupID, po
 		if member.Status == "accepted" && member.MemberID != posterID {
 			err := nh.service.CreateNotification(member.MemberID, posterID, "post", "group", groupID, content)
 			if err != nil {
-				log.Printf("Failed to create post notification for member %d: %v", member.MemberID, err)
+				slog.ErrorContext(nh.db.Context(), "Failed to create post notification", "member_id", member.MemberID, "err", err)
 				// Continue with other members even if one fails
 			}
 		}
@@ -0,0 +1,99 @@
+package dbTools
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// Page sizes of post and comment listings
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// ErrInvalidCursor is returned for cursors this server did not hand out
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is where a page ended. Posts and comments are listed newest first,
+// by created_at as stored and then by ID.
+type Cursor struct {
+	CreatedAt string `json:"t"`
+	ID        int    `json:"id"`
+}
+
+// Encode makes the cursor opaque to clients
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor reads a cursor made by Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.CreatedAt == "" || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// Page selects a page of a listing. A nil After is the first page; a Limit
+// out of range is replaced by the default.
+type Page struct {
+	After *Cursor
+	Limit int
+}
+
+// PostPage selects a page of posts
+type PostPage struct {
+	Page
+	// CommentPreview is how many of their latest comments posts come with
+	CommentPreview int
+}
+
+func (p Page) limit() int {
+	if p.Limit <= 0 || p.Limit > MaxPageSize {
+		return DefaultPageSize
+	}
+	return p.Limit
+}
+
+// keysetSQL is the condition for rows that come after the cursor, with its
+// arguments. The query must order by createdAt DESC, id DESC.
+func (p Page) keysetSQL(createdAt, id string) (string, []interface{}) {
+	if p.After == nil {
+		return "", nil
+	}
+	return "AND (" + createdAt + ", " + id + ") < (?, ?)", []interface{}{p.After.CreatedAt, p.After.ID}
+}
+
+// limitArg is the LIMIT of the query: one more than the page, to tell whether
+// there is a next page
+func (p Page) limitArg() int {
+	return p.limit() + 1
+}
+
+// nextPostPage drops the extra post fetched through limitArg and returns the
+// cursor of the following page, or "" on the last page
+func (p Page) nextPostPage(posts []PostResponse) ([]PostResponse, string) {
+	if len(posts) <= p.limit() {
+		return posts, ""
+	}
+	posts = posts[:p.limit()]
+	last := posts[len(posts)-1]
+	return posts, Cursor{CreatedAt: last.sortKey, ID: last.PostID}.Encode()
+}
+
+// nextCommentPage is nextPostPage for comments
+func (p Page) nextCommentPage(comments []CommentResponse) ([]CommentResponse, string) {
+	if len(comments) <= p.limit() {
+		return comments, ""
+	}
+	comments = comments[:p.limit()]
+	last := comments[len(comments)-1]
+	return comments, Cursor{CreatedAt: last.sortKey, ID: int(last.CommentID)}.Encode()
+}
@@ -0,0 +1,98 @@
+package dbTools
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestDecodeCursor(t *testing.T) {
+	c := Cursor{CreatedAt: "2026-01-02 03:04:05+00:00", ID: 42}
+	got, err := DecodeCursor(c.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *got != c {
+		t.Errorf("DecodeCursor(Encode()) = %+v, want %+v", *got, c)
+	}
+
+	encoded := c.Encode()
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for name, cursor := range map[string]string{
+		"not base64":       "%%%",
+		"padded base64":    base64.URLEncoding.EncodeToString([]byte(`{"t":"x","id":1}`)) + "=",
+		"truncated":        encoded[:len(encoded)-3],
+		"changed byte":     "A" + encoded[1:],
+		"not JSON":         raw("t=x&id=1"),
+		"missing time":     raw(`{"id":1}`),
+		"zero ID":          raw(`{"t":"x","id":0}`),
+		"negative ID":      raw(`{"t":"x","id":-5}`),
+		"ID of wrong type": raw(`{"t":"x","id":"1"}`),
+	} {
+		if _, err := DecodeCursor(cursor); err != ErrInvalidCursor {
+			t.Errorf("%s: DecodeCursor(%q) error = %v, want ErrInvalidCursor", name, cursor, err)
+		}
+	}
+}
+
+// TestFeedPagesWithEqualTimestamps pages through posts that mostly share one
+// created_at and checks every post is listed exactly once, in order. The posts
+// are tagged so the feed leaves out the posts the migrations seed.
+func TestFeedPagesWithEqualTimestamps(t *testing.T) {
+	d := openTestDB(t)
+	author := insertTestUser(t, d, "alice")
+
+	same := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	times := []time.Time{same.Add(-time.Hour)}
+	for i := 0; i < 8; i++ {
+		times = append(times, same)
+	}
+	times = append(times, same.Add(time.Hour))
+
+	var ids []int
+	for i, createdAt := range times {
+		id, err := d.InsertPostToDB(&Post{PosterID: author, Content: fmt.Sprint("post ", i), Privacy: "public", CreatedAt: createdAt}, []string{"paging"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	// Newest first: the last post, then the equal ones by descending ID, then the first
+	want := []int{ids[len(ids)-1]}
+	for i := len(ids) - 2; i >= 1; i-- {
+		want = append(want, ids[i])
+	}
+	want = append(want, ids[0])
+
+	for _, limit := range []int{1, 3, 4, 10} {
+		var got []int
+		page := PostPage{Page: Page{Limit: limit}}
+		for pages := 0; ; pages++ {
+			if pages > len(times) {
+				t.Fatalf("limit %d: paging did not end, listed %v", limit, got)
+			}
+			posts, next, err := d.GetFeedPosts(author, "paging", page)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(posts) > limit {
+				t.Fatalf("limit %d: page has %d posts", limit, len(posts))
+			}
+			for _, p := range posts {
+				got = append(got, p.PostID)
+			}
+			if next == "" {
+				break
+			}
+			after, err := DecodeCursor(next)
+			if err != nil {
+				t.Fatalf("limit %d: next cursor %q: %v", limit, next, err)
+			}
+			page.After = after
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("limit %d: listed %v, want %v", limit, got, want)
+		}
+	}
+}
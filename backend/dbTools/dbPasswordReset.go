@@ -0,0 +1,76 @@
+package dbTools
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrInvalidResetToken is returned for unknown, expired or already used reset tokens
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CountRecentPasswordResets returns how many reset tokens were issued for the user since the given time
+func (d *DB) CountRecentPasswordResets(userID int, since time.Time) (int, error) {
+	defer observe("CountRecentPasswordResets", time.Now())
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM password_reset_tokens WHERE user_id = ? AND created_at > ?`,
+		userID, since.UTC()).Scan(&count)
+	return count, err
+}
+
+// CreatePasswordResetToken stores a new reset token hash. Earlier unused tokens
+// of the user stop working, so only the latest email link is valid.
+func (d *DB) CreatePasswordResetToken(userID int, tokenHash, ipAddress string, expiresAt time.Time) error {
+	defer observe("CreatePasswordResetToken", time.Now())
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+		if _, err := tx.Exec(`UPDATE password_reset_tokens SET used_at = ? WHERE user_id = ? AND used_at IS NULL`,
+			now, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+            INSERT INTO password_reset_tokens (token_hash, user_id, ip_address, created_at, expires_at)
+            VALUES (?, ?, ?, ?, ?)
+        `, tokenHash, userID, ipAddress, now, expiresAt.UTC())
+		return err
+	})
+}
+
+// ResetPassword consumes a reset token, stores the new password hash and
+// revokes every session and access token of the user. Deactivated accounts can reset too and
+// are reactivated by the next login. It returns the user's ID, or
+// ErrInvalidResetToken if the token cannot be used.
+func (d *DB) ResetPassword(tokenHash, passwordHash string) (int, error) {
+	defer observe("ResetPassword", time.Now())
+	var userID int
+	err := d.WithTransaction(func(tx *sql.Tx) error {
+		now := time.Now().UTC()
+
+		// Claiming the token with a conditional update makes it single use even under concurrent requests
+		err := tx.QueryRow(`
+            UPDATE password_reset_tokens SET used_at = ?
+            WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+            RETURNING user_id
+        `, now, tokenHash, now).Scan(&userID)
+		if err == sql.ErrNoRows {
+			return ErrInvalidResetToken
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := tx.Exec(`
+            UPDATE users SET password = ?, updated_at = CURRENT_TIMESTAMP, updater_id = ?
+            WHERE user_id = ? AND status IN ('active', 'pending_verification', 'inactive') AND deleted_at IS NULL
+        `, passwordHash, userID, userID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return ErrInvalidResetToken
+		}
+
+		return revokeCredentials(tx, userID, "")
+	})
+	return userID, err
+}
@@ -3,12 +3,16 @@ package dbTools
 import (
 	"context"
 	"database/sql"
-	"log"
+	"log/slog"
+	"social_network/policy"
 	"social_network/utils"
+	"time"
 )
 
-// InsertPostToDB inserts a new post into the database and sets the PostID on success.
-func (d *DB) InsertPostToDB(p *Post) (int, error) {
+// InsertPostToDB inserts a new post with its tags into the database and sets
+// the PostID on success. Tags must already be normalized.
+func (d *DB) InsertPostToDB(p *Post, tags []string) (int, error) {
+	defer observe("InsertPostToDB", time.Now())
 	// log.Print("InsertPostToDB called with post:", p)
 	// Generate UUID for the post if not already set
 	if p.PostUUID == "" {
@@ -24,35 +28,46 @@ func (d *DB) InsertPostToDB(p *Post) (int, error) {
             (post_uuid, poster_id, group_id, content, privacy, created_at)
         VALUES (?, ?, ?, ?, ?, ?)
     `
-	result, err := d.Exec(
-		query,
-		p.PostUUID,
-		p.PosterID,
-		p.GroupID,
-		p.Content,
-		p.Privacy,
-		p.CreatedAt,
-	)
-	if err != nil {
-		return -1, err
-	}
-	id, err := result.LastInsertId()
+	err := d.WithTransaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec(
+			query,
+			p.PostUUID,
+			p.PosterID,
+			p.GroupID,
+			p.Content,
+			p.Privacy,
+			p.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		if err := savePostCategories(tx, int(id), p.PosterID, tags); err != nil {
+			return err
+		}
+		p.PostID = int(id)
+		return nil
+	})
 	if err != nil {
 		return -1, err
 	}
-	p.PostID = int(id)
 	return p.PostID, nil
 }
 
-// GetFeedPosts retrieves all public posts,
-// semi-private posts if user is a follower,
-// private posts if user is selectedFollower,
-// and all the user's own posts.
-func (d *DB) GetFeedPosts(userID int) ([]PostResponse, error) {
+// GetFeedPosts retrieves a page of the posts the user may see under the
+// visibility policy, including the user's own posts, newest first, and the
+// cursor of the next page. A non-empty tag, normalized, keeps only the posts
+// with that category or hashtag.
+func (d *DB) GetFeedPosts(userID int, tag string, page PostPage) ([]PostResponse, string, error) {
+	defer observe("GetFeedPosts", time.Now())
 	// log.Print("GetFeedPosts called for userID:", userID)
+	keyset, keysetArgs := page.keysetSQL("p.created_at", "p.post_id")
 	rows, err := d.GetDB().Query(`
         SELECT 
-            p.post_id, p.post_uuid, p.poster_id, p.group_id, p.content, p.privacy, p.status, p.created_at, 
+            p.post_id, p.post_uuid, p.poster_id, p.group_id, p.content, p.privacy, p.status, p.created_at, p.edited_at, CAST(p.created_at AS TEXT),
             COALESCE(u.nickname, '') as nickname, u.avatar,
             COALESCE(f.file_id, 0) as file_id, 
             f.filename_new
@@ -60,24 +75,16 @@ func (d *DB) GetFeedPosts(userID int) ([]PostResponse, error) {
         JOIN users u ON p.poster_id = u.user_id AND u.status = 'active'
         LEFT JOIN files f ON f.parent_type = 'post' AND f.parent_id = p.post_id AND f.status = 'active'
         WHERE p.status = 'active'
-          AND (
-            p.poster_id = ? -- always include user's own posts
-            OR p.privacy = 'public'
-            OR (
-                (p.privacy = 'semi-private' OR p.privacy = 'private')
-                AND EXISTS (
-                    SELECT 1 FROM post_private_viewers 
-                    WHERE post_id = p.post_id 
-                      AND user_id = ?
-                )
-            )
-          )
-        ORDER BY p.created_at DESC
-    `, userID, userID)
+          AND `+policy.VisibleSQL+`
+          AND (? = '' OR EXISTS (SELECT 1 FROM post_categories pc WHERE pc.post_id = p.post_id AND pc.category_name = ? AND pc.status = 'active'))
+        `+keyset+`
+        ORDER BY p.created_at DESC, p.post_id DESC
+        LIMIT ?
+    `, append(append(append(policy.VisibleArgs(userID), tag, tag), keysetArgs...), page.limitArg())...)
 	// COALESCE(f.file_id, 0): If f.file_id != NULL, use its value. If f.file_id = NULL (no file w/post), use 0 instead.
 	if err != nil {
-		log.Print("GetFeedPosts: Error querying posts:", err)
-		return nil, err
+		slog.ErrorContext(d.Context(), "GetFeedPosts: querying posts failed", "err", err)
+		return nil, "", err
 	}
 	defer rows.Close()
 
@@ -87,6 +94,7 @@ func (d *DB) GetFeedPosts(userID int) ([]PostResponse, error) {
 		var groupID sql.NullInt64
 		var fileID sql.NullInt64
 		var filenameNew sql.NullString
+		var editedAt sql.NullTime
 
 		err := rows.Scan(
 			&postResponse.PostID,
@@ -97,14 +105,16 @@ func (d *DB) GetFeedPosts(userID int) ([]PostResponse, error) {
 			&postResponse.Privacy,
 			&postResponse.PostStatus,
 			&postResponse.PostCreatedAt,
+			&editedAt,
+			&postResponse.sortKey,
 			&postResponse.Nickname,
 			&postResponse.Avatar,
 			&fileID,
 			&filenameNew,
 		)
 		if err != nil {
-			log.Print("GetFeedPosts: Error scanning post row:", err)
-			return nil, err
+			slog.ErrorContext(d.Context(), "GetFeedPosts: scanning post row failed", "err", err)
+			return nil, "", err
 		}
 		if groupID.Valid {
 			gid := int(groupID.Int64)
@@ -124,21 +134,25 @@ func (d *DB) GetFeedPosts(userID int) ([]PostResponse, error) {
 		} else {
 			postResponse.FilenameNew = nil
 		}
-
-		comments, err := d.GetCommentsForPost(context.Background(), postResponse.PostUUID)
-		if err != nil {
-			return nil, err
-		}
-
-		postResponse.Comments = comments
+		postResponse.setEdited(editedAt)
 		postsResponse = append(postsResponse, postResponse)
 	}
-	log.Print("GetFeedPosts: Retrieved posts:", postsResponse)
-	return postsResponse, nil
+	postsResponse, next := page.nextPostPage(postsResponse)
+	if err := d.attachPostReactions(postsResponse, userID); err != nil {
+		return nil, "", err
+	}
+	if err := d.attachPostCategories(postsResponse); err != nil {
+		return nil, "", err
+	}
+	if err := d.attachCommentPreviews(postsResponse, userID, page.CommentPreview); err != nil {
+		return nil, "", err
+	}
+	return postsResponse, next, nil
 }
 
-// GetProfilePosts retrieves all the targetUser's viewable posts
-func (d *DB) GetProfilePosts(currentUserID int, targetUserUUID string) ([]PostResponse, error) {
+// GetProfilePosts retrieves a page of the targetUser's viewable posts and the cursor of the next page
+func (d *DB) GetProfilePosts(currentUserID int, targetUserUUID string, page PostPage) ([]PostResponse, string, error) {
+	defer observe("GetProfilePosts", time.Now())
 	// log.Print("GetProfilePosts called")
 	// Convert targetUserUUID to targetUserID and get targetUserPrivacy
 	var targetUserID int
@@ -148,71 +162,32 @@ func (d *DB) GetProfilePosts(currentUserID int, targetUserUUID string) ([]PostRe
 	).Scan(&targetUserID, &targetUserPrivacy)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, nil // User not found, return empty slice
+			return nil, "", nil // User not found, return empty slice
 		}
-		return nil, err
+		return nil, "", err
 	}
 
-	// Determine which posts to show based on user
-	var rows *sql.Rows
-	if currentUserID == targetUserID {
-		// Show all posts for self
-		rows, err = d.GetDB().Query(`
-            SELECT 
-                p.post_id, p.post_uuid, p.poster_id, p.group_id, p.content, p.privacy, p.status, p.created_at, 
-                COALESCE(u.nickname, '') as nickname, u.avatar, 
-                COALESCE(f.file_id, 0) as file_id, 
-                f.filename_new
-            FROM posts p
-            JOIN users u ON p.poster_id = u.user_id AND u.status = 'active'
-            LEFT JOIN files f ON f.parent_type = 'post' AND f.parent_id = p.post_id AND f.status = 'active'
-            WHERE p.poster_id = ?
-              AND p.status = 'active'
-            ORDER BY p.created_at DESC
-        `, targetUserID)
-		/* } else if targetUserPrivacy == "private" {
-				// Only show public posts if profile is private and not the owner
-				rows, err = d.GetDB().Query(`
-		            SELECT
-		                p.post_id, p.post_uuid, p.poster_id, p.group_id, p.content, p.privacy, p.status, p.created_at,
-		                u.nickname, u.avatar,
-		                COALESCE(f.file_id, 0) as file_id,
-		                f.filename_new
-		            FROM posts p
-		            JOIN users u ON p.poster_id = u.user_id AND u.status = 'active'
-		            LEFT JOIN files f ON f.parent_type = 'post' AND f.parent_id = p.post_id AND f.status = 'active'
-		            WHERE p.poster_id = ?
-		              AND p.status = 'active'
-		              AND p.privacy = 'public'
-		            ORDER BY p.created_at DESC
-		        `, targetUserID) */
-	} else {
-		// Show public posts and posts where currentUserID is in post_private_viewers
-		rows, err = d.GetDB().Query(`
-            SELECT 
-                p.post_id, p.post_uuid, p.poster_id, p.group_id, p.content, p.privacy, p.status, p.created_at, 
-                COALESCE(u.nickname, '') as nickname, u.avatar, 
-                COALESCE(f.file_id, 0) as file_id, 
-                f.filename_new
-            FROM posts p
-            JOIN users u ON p.poster_id = u.user_id AND u.status = 'active'
-            LEFT JOIN files f ON f.parent_type = 'post' AND f.parent_id = p.post_id AND f.status = 'active'
-            WHERE p.poster_id = ?
-              AND p.status = 'active'
-              AND (
-                p.privacy = 'public'
-                OR EXISTS (
-                    SELECT 1 FROM post_private_viewers v
-                    WHERE v.post_id = p.post_id
-                      AND v.user_id = ?
-                )
-              )
-            ORDER BY p.created_at DESC
-        `, targetUserID, currentUserID)
-	}
+	// Authors see all their posts, everyone else what the visibility policy allows
+	keyset, keysetArgs := page.keysetSQL("p.created_at", "p.post_id")
+	rows, err := d.GetDB().Query(`
+        SELECT 
+            p.post_id, p.post_uuid, p.poster_id, p.group_id, p.content, p.privacy, p.status, p.created_at, p.edited_at, CAST(p.created_at AS TEXT),
+            COALESCE(u.nickname, '') as nickname, u.avatar, 
+            COALESCE(f.file_id, 0) as file_id, 
+            f.filename_new
+        FROM posts p
+        JOIN users u ON p.poster_id = u.user_id AND u.status = 'active'
+        LEFT JOIN files f ON f.parent_type = 'post' AND f.parent_id = p.post_id AND f.status = 'active'
+        WHERE p.poster_id = ?
+          AND p.status = 'active'
+          AND `+policy.VisibleSQL+`
+        `+keyset+`
+        ORDER BY p.created_at DESC, p.post_id DESC
+        LIMIT ?
+    `, append(append(append([]interface{}{targetUserID}, policy.VisibleArgs(currentUserID)...), keysetArgs...), page.limitArg())...)
 	if err != nil {
 		// log.Print("GetProfilePosts: Error querying posts:", err)
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
@@ -222,6 +197,7 @@ func (d *DB) GetProfilePosts(currentUserID int, targetUserUUID string) ([]PostRe
 		var groupID sql.NullInt64
 		var fileID sql.NullInt64
 		var filenameNew sql.NullString
+		var editedAt sql.NullTime
 
 		err := rows.Scan(
 			&postResponse.PostID,
@@ -232,6 +208,8 @@ func (d *DB) GetProfilePosts(currentUserID int, targetUserUUID string) ([]PostRe
 			&postResponse.Privacy,
 			&postResponse.PostStatus,
 			&postResponse.PostCreatedAt,
+			&editedAt,
+			&postResponse.sortKey,
 			&postResponse.Nickname,
 			&postResponse.Avatar,
 			&fileID,
@@ -239,7 +217,7 @@ func (d *DB) GetProfilePosts(currentUserID int, targetUserUUID string) ([]PostRe
 		)
 		if err != nil {
 			// log.Print("GetProfilePosts: Error scanning post row:", err)
-			return nil, err
+			return nil, "", err
 		}
 		if groupID.Valid {
 			gid := int(groupID.Int64)
@@ -259,22 +237,25 @@ func (d *DB) GetProfilePosts(currentUserID int, targetUserUUID string) ([]PostRe
 		} else {
 			postResponse.FilenameNew = nil
 		}
-
-		comments, err := d.GetCommentsForPost(context.Background(), postResponse.PostUUID)
-		if err != nil {
-			// log.Print("GetProfilePosts: Error getting comments for post:", err)
-			return nil, err
-		}
-		// log.Print("GetProfilePosts: Retrieved comments for post:", postResponse.PostUUID, comments)
-
-		postResponse.Comments = comments
+		postResponse.setEdited(editedAt)
 		postsResponse = append(postsResponse, postResponse)
 	}
 
-	return postsResponse, nil
+	postsResponse, next := page.nextPostPage(postsResponse)
+	if err := d.attachPostReactions(postsResponse, currentUserID); err != nil {
+		return nil, "", err
+	}
+	if err := d.attachPostCategories(postsResponse); err != nil {
+		return nil, "", err
+	}
+	if err := d.attachCommentPreviews(postsResponse, currentUserID, page.CommentPreview); err != nil {
+		return nil, "", err
+	}
+	return postsResponse, next, nil
 }
 
 func (d *DB) GetPostByUUID(ctx context.Context, postUUID string) (*Post, error) {
+	defer observe("GetPostByUUID", time.Now())
 	var post Post
 	err := d.db.QueryRowContext(ctx, `
 	SELECT post_id, post_uuid, poster_id, group_id, content, privacy, status, created_at
@@ -299,8 +280,71 @@ func (d *DB) GetPostByUUID(ctx context.Context, postUUID string) (*Post, error)
 	return &post, nil
 }
 
+// PolicyPost describes the post for the visibility policy
+func (p *Post) PolicyPost() policy.Post {
+	return policy.Post{AuthorID: p.PosterID, Privacy: p.Privacy, InGroup: p.GroupID != nil}
+}
+
+// PostRelation loads what the visibility policy needs to know about the viewer and the post
+func (d *DB) PostRelation(ctx context.Context, post *Post, viewerID int) (policy.Relation, error) {
+	defer observe("PostRelation", time.Now())
+	var rel policy.Relation
+	if viewerID == 0 {
+		return rel, nil
+	}
+	groupID := 0
+	if post.GroupID != nil {
+		groupID = *post.GroupID
+	}
+	err := d.db.QueryRowContext(ctx, `
+        SELECT
+            EXISTS(SELECT 1 FROM follows WHERE followed_user_id = ? AND follower_user_id = ? AND status = 'accepted'),
+            EXISTS(SELECT 1 FROM post_private_viewers WHERE post_id = ? AND user_id = ?),
+            EXISTS(SELECT 1 FROM group_members WHERE group_id = ? AND member_id = ? AND status = 'accepted')
+    `, post.PosterID, viewerID, post.PostID, viewerID, groupID, viewerID).Scan(&rel.Follower, &rel.SelectedViewer, &rel.GroupMember)
+	return rel, err
+}
+
+// CanViewPost applies the visibility policy to a single post
+func (d *DB) CanViewPost(ctx context.Context, post *Post, viewerID int) (bool, error) {
+	defer observe("CanViewPost", time.Now())
+	rel, err := d.PostRelation(ctx, post, viewerID)
+	if err != nil {
+		return false, err
+	}
+	return policy.CanView(post.PolicyPost(), viewerID, rel), nil
+}
+
+// GetPostByID returns an active post, or nil if there is none
+func (d *DB) GetPostByID(ctx context.Context, postID int) (*Post, error) {
+	defer observe("GetPostByID", time.Now())
+	var post Post
+	err := d.db.QueryRowContext(ctx, `
+	SELECT post_id, post_uuid, poster_id, group_id, content, privacy, status, created_at
+	FROM posts
+	WHERE post_id = ? AND status = 'active'
+	`, postID).Scan(
+		&post.PostID,
+		&post.PostUUID,
+		&post.PosterID,
+		&post.GroupID,
+		&post.Content,
+		&post.Privacy,
+		&post.Status,
+		&post.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &post, nil
+}
+
 // InsertCommentToDB inserts a new comment into the database and sets the CommentID on success.
 func (d *DB) InsertCommentToDB(c *Comment) (int, error) {
+	defer observe("InsertCommentToDB", time.Now())
 	query := `
         INSERT INTO comments 
             (commenter_id, post_id, group_id, content, post_privacy, created_at)
@@ -326,76 +370,36 @@ func (d *DB) InsertCommentToDB(c *Comment) (int, error) {
 	return c.CommentID, nil
 }
 
-func (d *DB) GetCommentsForPost(ctx context.Context, postUUID string) ([]CommentResponse, error) {
-	rows, err := d.db.QueryContext(ctx, `
-        SELECT 
-            c.comment_id,
-			c.commenter_id,
-            p.post_uuid,
-            c.group_id,
-            c.content,
-            p.privacy,
-            c.status,
-            c.created_at,
-            COALESCE(u.nickname, '') as nickname,
-			u.avatar,
-            COALESCE(f.file_id, 0) as file_id,
-            f.filename_new
-        FROM comments c
-        JOIN posts p ON c.post_id = p.post_id
-        JOIN users u ON c.commenter_id = u.user_id
-        LEFT JOIN files f ON f.parent_type = 'comment' AND f.parent_id = c.comment_id AND f.status = 'active'
-        WHERE p.post_uuid = ?
-        ORDER BY c.created_at DESC
-    `, postUUID)
+// GetCommentByID returns an active comment, or nil if there is none
+func (d *DB) GetCommentByID(ctx context.Context, commentID int) (*Comment, error) {
+	defer observe("GetCommentByID", time.Now())
+	var c Comment
+	err := d.db.QueryRowContext(ctx, `
+	SELECT comment_id, commenter_id, post_id, group_id, content, post_privacy, status, created_at
+	FROM comments
+	WHERE comment_id = ? AND status = 'active'
+	`, commentID).Scan(
+		&c.CommentID,
+		&c.CommenterID,
+		&c.PostID,
+		&c.GroupID,
+		&c.Content,
+		&c.PostPrivacy,
+		&c.Status,
+		&c.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var comments []CommentResponse
-	for rows.Next() {
-		var comment CommentResponse
-		var groupID sql.NullInt64
-		var fileID sql.NullInt64
-		var filenameNew sql.NullString
-
-		err := rows.Scan(
-			&comment.CommentID,
-			&comment.CommenterID,
-			&comment.PostUUID,
-			&groupID,
-			&comment.Content,
-			&comment.PostPrivacy,
-			&comment.CommentStatus,
-			&comment.CommentCreatedAt,
-			&comment.Nickname,
-			&comment.Avatar,
-			&fileID,
-			&filenameNew,
-		)
-		if err != nil {
-			return nil, err
-		}
-		if groupID.Valid {
-			gid := int(groupID.Int64)
-			comment.GroupID = &gid
-		}
-		if fileID.Valid {
-			fid := int(fileID.Int64)
-			comment.FileID = &fid
-		}
-		if filenameNew.Valid {
-			fn := filenameNew.String
-			comment.FilenameNew = &fn
-		}
-		comments = append(comments, comment)
-	}
-	return comments, nil
+	return &c, nil
 }
 
 // InsertSelectedFollowers inserts selected follower user_ids for a post (for semi-private/private posts)
 func (d *DB) InsertSelectedFollowers(postID int, selectedFollowersUUIDs []string) error {
+	defer observe("InsertSelectedFollowers", time.Now())
 	// log.Print("InsertSelectedFollowers called with postID:", postID, "and selectedFollowersUUIDs:", selectedFollowersUUIDs)
 	if len(selectedFollowersUUIDs) == 0 {
 		return nil
@@ -433,7 +437,9 @@ func (d *DB) InsertSelectedFollowers(postID int, selectedFollowersUUIDs []string
 	return tx.Commit()
 }
 
-func (d *DB) GetGroupPosts(userID int, groupID int) ([]PostResponse, error) {
+// GetGroupPosts retrieves a page of a group's posts for one of its members and the cursor of the next page
+func (d *DB) GetGroupPosts(userID int, groupID int, page PostPage) ([]PostResponse, string, error) {
+	defer observe("GetGroupPosts", time.Now())
 	//log.Print("GetGroupPosts called for userID:", userID, "and groupID:", groupID)
 
 	// Check if user is an accepted member of the group
@@ -446,16 +452,17 @@ func (d *DB) GetGroupPosts(userID int, groupID int) ([]PostResponse, error) {
     `, groupID, userID).Scan(&isMember)
 	if err != nil {
 		//log.Print("GetGroupPosts: Error checking group membership:", err)
-		return nil, err
+		return nil, "", err
 	}
 	if !isMember {
 		//log.Print("GetGroupPosts: User is not a member of the group")
-		return nil, sql.ErrNoRows
+		return nil, "", sql.ErrNoRows
 	}
 
+	keyset, keysetArgs := page.keysetSQL("p.created_at", "p.post_id")
 	rows, err := d.GetDB().Query(`
         SELECT 
-            p.post_id, p.post_uuid, p.poster_id, p.group_id, p.content, p.privacy, p.status, p.created_at, 
+            p.post_id, p.post_uuid, p.poster_id, p.group_id, p.content, p.privacy, p.status, p.created_at, p.edited_at, CAST(p.created_at AS TEXT),
             COALESCE(u.nickname, '') as nickname, u.avatar,
             COALESCE(f.file_id, 0) as file_id, 
             f.filename_new
@@ -464,12 +471,14 @@ func (d *DB) GetGroupPosts(userID int, groupID int) ([]PostResponse, error) {
         LEFT JOIN files f ON f.parent_type = 'post' AND f.parent_id = p.post_id AND f.status = 'active'
         WHERE p.status = 'active'
           AND p.group_id = ?
-		  AND p.privacy = 'semi-private'
-        ORDER BY p.created_at DESC
-    `, groupID)
+          AND `+policy.VisibleSQL+`
+        `+keyset+`
+        ORDER BY p.created_at DESC, p.post_id DESC
+        LIMIT ?
+    `, append(append(append([]interface{}{groupID}, policy.VisibleArgs(userID)...), keysetArgs...), page.limitArg())...)
 	if err != nil {
 		//log.Print("GetGroupPosts: Error querying posts:", err)
-		return nil, err
+		return nil, "", err
 	}
 	defer rows.Close()
 
@@ -479,6 +488,7 @@ func (d *DB) GetGroupPosts(userID int, groupID int) ([]PostResponse, error) {
 		var groupID sql.NullInt64
 		var fileID sql.NullInt64
 		var filenameNew sql.NullString
+		var editedAt sql.NullTime
 
 		err := rows.Scan(
 			&postResponse.PostID,
@@ -489,13 +499,15 @@ func (d *DB) GetGroupPosts(userID int, groupID int) ([]PostResponse, error) {
 			&postResponse.Privacy,
 			&postResponse.PostStatus,
 			&postResponse.PostCreatedAt,
+			&editedAt,
+			&postResponse.sortKey,
 			&postResponse.Nickname,
 			&postResponse.Avatar,
 			&fileID,
 			&filenameNew,
 		)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 		if groupID.Valid {
 			gid := int(groupID.Int64)
@@ -515,16 +527,20 @@ func (d *DB) GetGroupPosts(userID int, groupID int) ([]PostResponse, error) {
 		} else {
 			postResponse.FilenameNew = nil
 		}
-
-		comments, err := d.GetCommentsForPost(context.Background(), postResponse.PostUUID)
-		if err != nil {
-			return nil, err
-		}
-
-		postResponse.Comments = comments
+		postResponse.setEdited(editedAt)
 		postsResponse = append(postsResponse, postResponse)
 	}
 
 	//log.Print("GetGroupPosts: Retrieved posts:", postsResponse)
-	return postsResponse, nil
+	postsResponse, next := page.nextPostPage(postsResponse)
+	if err := d.attachPostReactions(postsResponse, userID); err != nil {
+		return nil, "", err
+	}
+	if err := d.attachPostCategories(postsResponse); err != nil {
+		return nil, "", err
+	}
+	if err := d.attachCommentPreviews(postsResponse, userID, page.CommentPreview); err != nil {
+		return nil, "", err
+	}
+	return postsResponse, next, nil
 }
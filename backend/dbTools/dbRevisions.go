@@ -0,0 +1,192 @@
+package dbTools
+
+import (
+	"database/sql"
+	"time"
+)
+
+// setEdited fills in the marker from the edited_at column of a post or comment
+func (e *EditMarker) setEdited(editedAt sql.NullTime) {
+	if editedAt.Valid {
+		e.Edited = true
+		e.EditedAt = &editedAt.Time
+	}
+}
+
+// EditPost replaces the content and tags of an active post, keeping the old
+// content in its revision history. It returns false if the post is gone.
+func (d *DB) EditPost(postID, editorID int, content string, tags []string, editedAt time.Time) (bool, error) {
+	defer observe("EditPost", time.Now())
+	tx, err := d.db.BeginTx(d.Context(), nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if ok, err := saveRevision(tx, "post", postID, editorID); err != nil || !ok {
+		return false, err
+	}
+	if _, err := tx.Exec(`
+        UPDATE posts SET content = ?, edited_at = ?, updated_at = ?, updater_id = ? WHERE post_id = ?
+    `, content, editedAt, editedAt, editorID, postID); err != nil {
+		return false, err
+	}
+	// The tags follow the new content: old ones are deactivated, the current ones (re)activated
+	if _, err := tx.Exec(`
+        UPDATE post_categories SET status = 'inactive', updated_at = datetime('now'), updater_id = ?
+        WHERE post_id = ? AND status = 'active'
+    `, editorID, postID); err != nil {
+		return false, err
+	}
+	if err := savePostCategories(tx, postID, editorID, tags); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// EditComment replaces the content of an active comment, keeping the old
+// content in its revision history. It returns false if the comment is gone.
+func (d *DB) EditComment(commentID, editorID int, content string, editedAt time.Time) (bool, error) {
+	defer observe("EditComment", time.Now())
+	tx, err := d.db.BeginTx(d.Context(), nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if ok, err := saveRevision(tx, "comment", commentID, editorID); err != nil || !ok {
+		return false, err
+	}
+	if _, err := tx.Exec(`
+        UPDATE comments SET content = ?, edited_at = ?, updated_at = ?, updater_id = ? WHERE comment_id = ?
+    `, content, editedAt, editedAt, editorID, commentID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// saveRevision copies the current content of an active post or comment into
+// content_revisions. It returns false if there is no such post or comment.
+func saveRevision(tx *sql.Tx, parentType string, parentID, editorID int) (bool, error) {
+	query := `
+        INSERT INTO content_revisions (parent_type, parent_id, content, written_at, editor_id)
+        SELECT 'post', post_id, content, COALESCE(edited_at, created_at), ?
+        FROM posts WHERE post_id = ? AND status = 'active'
+    `
+	if parentType == "comment" {
+		query = `
+        INSERT INTO content_revisions (parent_type, parent_id, content, written_at, editor_id)
+        SELECT 'comment', comment_id, content, COALESCE(edited_at, created_at), ?
+        FROM comments WHERE comment_id = ? AND status = 'active'
+    `
+	}
+	result, err := tx.Exec(query, editorID, parentID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DeletePost soft-deletes a post together with its comments, its tags and the
+// files attached to the post and its comments. It returns false if the post
+// was already gone.
+func (d *DB) DeletePost(postID, userID int) (bool, error) {
+	defer observe("DeletePost", time.Now())
+	tx, err := d.db.BeginTx(d.Context(), nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+        UPDATE posts SET status = 'inactive', updated_at = ?, updater_id = ?
+        WHERE post_id = ? AND status = 'active'
+    `, now, userID, postID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	// Files go first, while their comments can still be told apart from ones deleted earlier
+	steps := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`UPDATE files SET status = 'inactive', updated_at = ?, updater_id = ?
+            WHERE status = 'active'
+              AND ((parent_type = 'post' AND parent_id = ?)
+                OR (parent_type = 'comment' AND parent_id IN (SELECT comment_id FROM comments WHERE post_id = ? AND status = 'active')))`,
+			[]interface{}{now, userID, postID, postID}},
+		{`UPDATE comments SET status = 'inactive', updated_at = ?, updater_id = ?
+            WHERE post_id = ? AND status = 'active'`,
+			[]interface{}{now, userID, postID}},
+		{`UPDATE post_categories SET status = 'inactive', updated_at = ?, updater_id = ?
+            WHERE post_id = ? AND status = 'active'`,
+			[]interface{}{now, userID, postID}},
+	}
+	for _, step := range steps {
+		if _, err := tx.Exec(step.query, step.args...); err != nil {
+			return false, err
+		}
+	}
+	return true, tx.Commit()
+}
+
+// DeleteComment soft-deletes a comment and the files attached to it. It
+// returns false if the comment was already gone.
+func (d *DB) DeleteComment(commentID, userID int) (bool, error) {
+	defer observe("DeleteComment", time.Now())
+	tx, err := d.db.BeginTx(d.Context(), nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.Exec(`
+        UPDATE comments SET status = 'inactive', updated_at = ?, updater_id = ?
+        WHERE comment_id = ? AND status = 'active'
+    `, now, userID, commentID)
+	if err != nil {
+		return false, err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if _, err := tx.Exec(`
+        UPDATE files SET status = 'inactive', updated_at = ?, updater_id = ?
+        WHERE parent_type = 'comment' AND parent_id = ? AND status = 'active'
+    `, now, userID, commentID); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// GetRevisions returns the earlier versions of a post or comment, newest first
+func (d *DB) GetRevisions(parentType string, parentID int) ([]Revision, error) {
+	defer observe("GetRevisions", time.Now())
+	rows, err := d.db.QueryContext(d.Context(), `
+        SELECT revision_id, content, written_at, editor_id, created_at
+        FROM content_revisions
+        WHERE parent_type = ? AND parent_id = ?
+        ORDER BY revision_id DESC
+    `, parentType, parentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		var rev Revision
+		if err := rows.Scan(&rev.RevisionID, &rev.Content, &rev.WrittenAt, &rev.EditorID, &rev.EditedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
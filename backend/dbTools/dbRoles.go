@@ -0,0 +1,84 @@
+package dbTools
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SetUserRole changes a user's role. Group moderator assignments only mean
+// something with the group_moderator role, so they are dropped when it is taken away.
+func (d *DB) SetUserRole(userID int, role string, updaterID int) error {
+	defer observe("SetUserRole", time.Now())
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(`UPDATE users SET role = ?, updated_at = ?, updater_id = ? WHERE user_id = ?`,
+			role, time.Now().UTC(), updaterID, userID)
+		if err != nil {
+			return err
+		}
+		if role != "group_moderator" {
+			_, err = tx.Exec(`DELETE FROM group_moderators WHERE user_id = ?`, userID)
+		}
+		return err
+	})
+}
+
+// AssignGroupModerator makes the user a moderator of the group; assigning twice is a no-op
+func (d *DB) AssignGroupModerator(groupID, userID, assignedBy int) error {
+	defer observe("AssignGroupModerator", time.Now())
+	_, err := d.db.Exec(`
+        INSERT INTO group_moderators (group_id, user_id, assigned_by, created_at) VALUES (?, ?, ?, ?)
+        ON CONFLICT(group_id, user_id) DO NOTHING
+    `, groupID, userID, assignedBy, time.Now().UTC())
+	return err
+}
+
+// RemoveGroupModerator removes an assignment. It returns false if there was none.
+func (d *DB) RemoveGroupModerator(groupID, userID int) (bool, error) {
+	defer observe("RemoveGroupModerator", time.Now())
+	result, err := d.db.Exec(`DELETE FROM group_moderators WHERE group_id = ? AND user_id = ?`, groupID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// IsGroupModerator reports whether the user is assigned to moderate the group
+func (d *DB) IsGroupModerator(groupID, userID int) (bool, error) {
+	defer observe("IsGroupModerator", time.Now())
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM group_moderators WHERE group_id = ? AND user_id = ?`, groupID, userID).Scan(&count)
+	return count > 0, err
+}
+
+// GetGroupModerators lists the moderators assigned to a group
+func (d *DB) GetGroupModerators(groupID int) ([]GroupModerator, error) {
+	defer observe("GetGroupModerators", time.Now())
+	rows, err := d.db.Query(`
+        SELECT u.user_id, u.user_uuid, u.first_name, u.last_name, COALESCE(u.nickname, ''),
+               gm.assigned_by, gm.created_at
+        FROM group_moderators gm
+        JOIN users u ON u.user_id = gm.user_id
+        WHERE gm.group_id = ?
+        ORDER BY gm.created_at
+    `, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	moderators := []GroupModerator{}
+	for rows.Next() {
+		m := GroupModerator{GroupID: groupID}
+		var assignedBy sql.NullInt64
+		if err := rows.Scan(&m.UserID, &m.UserUUID, &m.FirstName, &m.LastName, &m.Nickname, &assignedBy, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		if assignedBy.Valid {
+			id := int(assignedBy.Int64)
+			m.AssignedBy = &id
+		}
+		moderators = append(moderators, m)
+	}
+	return moderators, rows.Err()
+}
@@ -0,0 +1,98 @@
+package dbTools
+
+import (
+	"database/sql"
+	"time"
+)
+
+// GetActiveSessions lists the user's active, unexpired sessions, most recently used first.
+// currentSessionUUID is the cookie value of the caller and is used to flag the current session.
+func (d *DB) GetActiveSessions(userID int, currentSessionUUID string) ([]SessionInfo, error) {
+	defer observe("GetActiveSessions", time.Now())
+	rows, err := d.db.Query(`
+        SELECT session_uuid, public_uuid, COALESCE(name, ''), COALESCE(user_agent, ''),
+               COALESCE(ip_address, ''), created_at, last_seen_at, expires_at
+        FROM sessions
+        WHERE user_id = ? AND status = 'active' AND expires_at > CURRENT_TIMESTAMP
+        ORDER BY COALESCE(last_seen_at, created_at) DESC
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []SessionInfo{}
+	for rows.Next() {
+		var s SessionInfo
+		var sessionUUID string
+		var lastSeen sql.NullTime
+		if err := rows.Scan(&sessionUUID, &s.PublicUUID, &s.Name, &s.UserAgent,
+			&s.IPAddress, &s.CreatedAt, &lastSeen, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		if lastSeen.Valid {
+			s.LastSeenAt = &lastSeen.Time
+		}
+		s.Current = sessionUUID == currentSessionUUID
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// RenameSession sets a user-chosen label on one of the user's active sessions.
+// It returns false if no such session exists.
+func (d *DB) RenameSession(userID int, publicUUID, name string) (bool, error) {
+	defer observe("RenameSession", time.Now())
+	result, err := d.db.Exec(`
+        UPDATE sessions SET name = ?, updated_at = CURRENT_TIMESTAMP, updater_id = ?
+        WHERE public_uuid = ? AND user_id = ? AND status = 'active'
+    `, name, userID, publicUUID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// RevokeSession deactivates one of the user's sessions.
+// It returns the revoked session's cookie value, or "" if no such session exists.
+func (d *DB) RevokeSession(userID int, publicUUID string) (string, error) {
+	defer observe("RevokeSession", time.Now())
+	var sessionUUID string
+	err := d.db.QueryRow(`SELECT session_uuid FROM sessions WHERE public_uuid = ? AND user_id = ? AND status = 'active'`,
+		publicUUID, userID).Scan(&sessionUUID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = d.db.Exec(`
+        UPDATE sessions SET status = 'inactive', updated_at = CURRENT_TIMESTAMP, updater_id = ?
+        WHERE session_uuid = ?
+    `, userID, sessionUUID)
+	if err != nil {
+		return "", err
+	}
+	return sessionUUID, nil
+}
+
+// RevokeOtherSessions deactivates every active session of the user except keepSessionUUID
+func (d *DB) RevokeOtherSessions(userID int, keepSessionUUID string) (int64, error) {
+	defer observe("RevokeOtherSessions", time.Now())
+	result, err := d.db.Exec(`
+        UPDATE sessions SET status = 'inactive', updated_at = CURRENT_TIMESTAMP, updater_id = ?
+        WHERE user_id = ? AND status = 'active' AND session_uuid != ?
+    `, userID, userID, keepSessionUUID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// RevokeAllSessions deactivates every active session of the user
+func (d *DB) RevokeAllSessions(userID int) (int64, error) {
+	defer observe("RevokeAllSessions", time.Now())
+	return d.RevokeOtherSessions(userID, "")
+}
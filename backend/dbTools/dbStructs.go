@@ -34,6 +34,94 @@ type Session struct {
 	UpdaterID   int        `json:"updater_id"`
 }
 
+// SessionInfo is the user-facing view of a session. It deliberately omits
+// session_uuid, which is the cookie value.
+type SessionInfo struct {
+	PublicUUID string     `json:"uuid"`
+	Name       string     `json:"name,omitempty"`
+	UserAgent  string     `json:"user_agent,omitempty"`
+	IPAddress  string     `json:"ip_address,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current"`
+}
+
+type LoginAttempt struct {
+	AttemptID     int       `json:"attempt_id"`
+	Email         string    `json:"email"`
+	UserID        *int      `json:"user_id,omitempty"` // Null when the email is unknown
+	IPAddress     string    `json:"ip_address"`
+	UserAgent     string    `json:"user_agent,omitempty"`
+	Success       bool      `json:"success"`
+	FailureReason string    `json:"failure_reason,omitempty"` // invalid_credentials, throttled, locked
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// AccessToken is a personal access token as shown to its owner; the secret is never stored
+type AccessToken struct {
+	PublicUUID string     `json:"uuid"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	TokenHint  string     `json:"token_hint"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
+
+// UserIdentity is an external OpenID Connect account linked to a user
+type UserIdentity struct {
+	IdentityID  int        `json:"identity_id"`
+	UserID      int        `json:"-"`
+	Provider    string     `json:"provider"`
+	Subject     string     `json:"-"`
+	Email       string     `json:"email,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+}
+
+// OIDCLoginState is the server-side half of an authorization code flow
+type OIDCLoginState struct {
+	Provider     string
+	Nonce        string
+	CodeVerifier string
+	LinkUserID   int // 0 for a login, the user's ID when linking a provider
+	RedirectPath string
+}
+
+// AccountDeletion is a request to erase an account once its grace period is over
+type AccountDeletion struct {
+	DeletionID   int                    `json:"deletion_id"`
+	UserID       int                    `json:"user_id"`
+	Status       string                 `json:"status"` // scheduled, removing_files, completed, cancelled
+	RequestedAt  time.Time              `json:"requested_at"`
+	ScheduledFor time.Time              `json:"scheduled_for"`
+	StartedAt    *time.Time             `json:"started_at,omitempty"`
+	CompletedAt  *time.Time             `json:"completed_at,omitempty"`
+	Attempts     int                    `json:"attempts"`
+	LastError    string                 `json:"last_error,omitempty"`
+	Report       *AccountDeletionReport `json:"report,omitempty"`
+}
+
+// AccountDeletionReport summarises what an account deletion erased
+type AccountDeletionReport struct {
+	Rows           map[string]int64 `json:"rows"`            // rows deleted per table; users counts the anonymised account row
+	GroupsRetained int              `json:"groups_retained"` // groups the user created stay for their members, credited to the anonymised account
+	EventsRetained int              `json:"events_retained"`
+	FilesRemoved   int              `json:"files_removed"`
+	FilesMissing   int              `json:"files_missing"` // already gone from disk
+}
+
+type UserTOTP struct {
+	UserID       int        `json:"user_id"`
+	Secret       string     `json:"-"`
+	Status       string     `json:"status"` // pending, enabled
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	EnabledAt    *time.Time `json:"enabled_at,omitempty"`
+}
+
 type File struct {
 	FileID       int        `json:"file_id"`
 	FileUUID     string     `json:"file_uuid"`
@@ -74,7 +162,26 @@ type PostResponse struct {
 	Avatar        string            `json:"avatar"` // User's avatar
 	FileID        *int              `json:"file_id,omitempty"`
 	FilenameNew   *string           `json:"filename_new,omitempty"`
-	Comments      []CommentResponse `json:"comments,omitempty"` // Comments on the post
+	Categories    []string          `json:"categories"`    // categories and hashtags, normalized
+	Comments      []CommentResponse `json:"comments"`      // the latest comments, newest first
+	CommentCount  int               `json:"comment_count"` // all the active comments
+	Reactions
+	EditMarker
+
+	sortKey string // created_at as stored, for the page cursor
+}
+
+// EditMarker marks posts and comments changed since they were written
+type EditMarker struct {
+	Edited   bool       `json:"edited"`
+	EditedAt *time.Time `json:"edited_at,omitempty"`
+}
+
+// Reactions are the likes and dislikes of a post or comment, as seen by the viewer
+type Reactions struct {
+	Likes      int    `json:"likes"`
+	Dislikes   int    `json:"dislikes"`
+	MyReaction string `json:"my_reaction"` // like, dislike, or "" when the viewer has not reacted
 }
 
 type Comment struct {
@@ -103,6 +210,19 @@ type CommentResponse struct {
 	Avatar           string    `json:"avatar"` // User's avatar
 	FileID           *int      `json:"file_id,omitempty"`
 	FilenameNew      *string   `json:"filename_new,omitempty"`
+	Reactions
+	EditMarker
+
+	sortKey string // created_at as stored, for the page cursor
+}
+
+// Revision is an earlier version of an edited post or comment
+type Revision struct {
+	RevisionID int       `json:"revision_id"`
+	Content    string    `json:"content"`    // the content before the edit
+	WrittenAt  time.Time `json:"written_at"` // when that content was posted or last edited
+	EditorID   *int      `json:"editor_id"`  // who made the edit; nil once their account is gone
+	EditedAt   time.Time `json:"edited_at"`
 }
 
 type PostCategory struct {
@@ -149,6 +269,18 @@ type GroupMember struct {
 	UpdaterID    int        `json:"updater_id"`
 }
 
+// GroupModerator is a user assigned to moderate a group
+type GroupModerator struct {
+	GroupID    int       `json:"group_id"`
+	UserID     int       `json:"user_id"`
+	UserUUID   string    `json:"user_uuid"`
+	FirstName  string    `json:"first_name"`
+	LastName   string    `json:"last_name"`
+	Nickname   string    `json:"nickname,omitempty"`
+	AssignedBy *int      `json:"assigned_by"` // Null once the assigning admin's account is erased
+	CreatedAt  time.Time `json:"created_at"`
+}
+
 type Event struct {
 	EventID       int        `json:"event_id"`
 	CreatorID     int        `json:"creator_id"`
@@ -0,0 +1,199 @@
+package dbTools
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrTOTPAlreadyEnabled is returned when enrolling a user who already has 2FA enabled
+var ErrTOTPAlreadyEnabled = errors.New("two-factor authentication already enabled")
+
+// GetTOTP returns the user's TOTP enrollment, or nil if there is none
+func (d *DB) GetTOTP(userID int) (*UserTOTP, error) {
+	defer observe("GetTOTP", time.Now())
+	t := &UserTOTP{}
+	var enabledAt sql.NullTime
+	err := d.db.QueryRow(`
+        SELECT user_id, secret, status, last_used_step, created_at, enabled_at
+        FROM user_totp WHERE user_id = ?
+    `, userID).Scan(&t.UserID, &t.Secret, &t.Status, &t.LastUsedStep, &t.CreatedAt, &enabledAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if enabledAt.Valid {
+		t.EnabledAt = &enabledAt.Time
+	}
+	return t, nil
+}
+
+// IsTOTPEnabled reports whether the user must pass a second factor to log in
+func (d *DB) IsTOTPEnabled(userID int) (bool, error) {
+	defer observe("IsTOTPEnabled", time.Now())
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM user_totp WHERE user_id = ? AND status = 'enabled'`, userID).Scan(&count)
+	return count > 0, err
+}
+
+// SavePendingTOTP stores a new, unconfirmed secret for the user, replacing any previous pending one
+func (d *DB) SavePendingTOTP(userID int, secret string) error {
+	defer observe("SavePendingTOTP", time.Now())
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		var status string
+		err := tx.QueryRow(`SELECT status FROM user_totp WHERE user_id = ?`, userID).Scan(&status)
+		if err == nil && status == "enabled" {
+			return ErrTOTPAlreadyEnabled
+		}
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+
+		_, err = tx.Exec(`
+            INSERT INTO user_totp (user_id, secret, status, last_used_step, created_at)
+            VALUES (?, ?, 'pending', 0, CURRENT_TIMESTAMP)
+            ON CONFLICT(user_id) DO UPDATE SET secret = excluded.secret, status = 'pending',
+                last_used_step = 0, created_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+        `, userID, secret)
+		return err
+	})
+}
+
+// EnableTOTP confirms the pending enrollment and stores a fresh set of recovery code hashes
+func (d *DB) EnableTOTP(userID int, step int64, recoveryCodeHashes []string) error {
+	defer observe("EnableTOTP", time.Now())
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+            UPDATE user_totp SET status = 'enabled', last_used_step = ?, enabled_at = CURRENT_TIMESTAMP,
+                updated_at = CURRENT_TIMESTAMP
+            WHERE user_id = ? AND status = 'pending'
+        `, step, userID)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return sql.ErrNoRows
+		}
+		return replaceRecoveryCodes(tx, userID, recoveryCodeHashes)
+	})
+}
+
+// DisableTOTP removes the user's second factor and recovery codes
+func (d *DB) DisableTOTP(userID int) error {
+	defer observe("DisableTOTP", time.Now())
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`DELETE FROM user_totp WHERE user_id = ?`, userID)
+		return err
+	})
+}
+
+// MarkTOTPStepUsed records step as used. It returns false if that step (or a
+// later one) was already used, which means the code is being replayed.
+func (d *DB) MarkTOTPStepUsed(userID int, step int64) (bool, error) {
+	defer observe("MarkTOTPStepUsed", time.Now())
+	result, err := d.db.Exec(`
+        UPDATE user_totp SET last_used_step = ?, updated_at = CURRENT_TIMESTAMP
+        WHERE user_id = ? AND status = 'enabled' AND last_used_step < ?
+    `, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// ReplaceRecoveryCodes invalidates the user's recovery codes and stores new ones
+func (d *DB) ReplaceRecoveryCodes(userID int, codeHashes []string) error {
+	defer observe("ReplaceRecoveryCodes", time.Now())
+	return d.WithTransaction(func(tx *sql.Tx) error {
+		return replaceRecoveryCodes(tx, userID, codeHashes)
+	})
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID int, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(`INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, hash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseRecoveryCode consumes an unused recovery code. It returns false if the code is unknown or already used.
+func (d *DB) UseRecoveryCode(userID int, codeHash string) (bool, error) {
+	defer observe("UseRecoveryCode", time.Now())
+	result, err := d.db.Exec(`
+        UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+        WHERE code_id = (
+            SELECT code_id FROM recovery_codes
+            WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+            LIMIT 1
+        )
+    `, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// CountUnusedRecoveryCodes returns how many recovery codes the user has left
+func (d *DB) CountUnusedRecoveryCodes(userID int) (int, error) {
+	defer observe("CountUnusedRecoveryCodes", time.Now())
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL`, userID).Scan(&count)
+	return count, err
+}
+
+// CreatePending2FALogin stores the hash of a short-lived token that lets the
+// holder finish a login by presenting a second factor
+func (d *DB) CreatePending2FALogin(userID int, tokenHash string, expiresAt time.Time) error {
+	defer observe("CreatePending2FALogin", time.Now())
+	_, err := d.db.Exec(`
+        INSERT INTO pending_2fa_logins (token_hash, user_id, status, attempts, expires_at)
+        VALUES (?, ?, 'pending', 0, ?)
+    `, tokenHash, userID, expiresAt.UTC())
+	return err
+}
+
+// GetPending2FALogin returns the user ID and failed attempt count of a pending,
+// unexpired 2FA login. It returns sql.ErrNoRows if the token is not usable.
+func (d *DB) GetPending2FALogin(tokenHash string) (userID int, attempts int, err error) {
+	defer observe("GetPending2FALogin", time.Now())
+	err = d.db.QueryRow(`
+        SELECT user_id, attempts FROM pending_2fa_logins
+        WHERE token_hash = ? AND status = 'pending' AND expires_at > ?
+    `, tokenHash, time.Now().UTC()).Scan(&userID, &attempts)
+	return userID, attempts, err
+}
+
+// RecordPending2FAFailure counts a wrong code and burns the token once maxAttempts is reached
+func (d *DB) RecordPending2FAFailure(tokenHash string, maxAttempts int) error {
+	defer observe("RecordPending2FAFailure", time.Now())
+	_, err := d.db.Exec(`
+        UPDATE pending_2fa_logins
+        SET attempts = attempts + 1,
+            status = CASE WHEN attempts + 1 >= ? THEN 'failed' ELSE status END
+        WHERE token_hash = ? AND status = 'pending'
+    `, maxAttempts, tokenHash)
+	return err
+}
+
+// ConsumePending2FALogin marks the token as used. It returns false if it was already consumed.
+func (d *DB) ConsumePending2FALogin(tokenHash string) (bool, error) {
+	defer observe("ConsumePending2FALogin", time.Now())
+	result, err := d.db.Exec(`UPDATE pending_2fa_logins SET status = 'used' WHERE token_hash = ? AND status = 'pending'`, tokenHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
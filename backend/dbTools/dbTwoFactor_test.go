@@ -0,0 +1,99 @@
+package dbTools
+
+import "testing"
+
+func TestMarkTOTPStepUsedRejectsReplays(t *testing.T) {
+	d := openTestDB(t)
+	userID := insertTestUser(t, d, "alice")
+
+	if err := d.SavePendingTOTP(userID, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"); err != nil {
+		t.Fatal(err)
+	}
+	// Enrolling uses up the step of the confirmation code
+	if err := d.EnableTOTP(userID, 100, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		step int64
+		ok   bool
+	}{
+		{"code used to enroll", 100, false},
+		{"earlier step", 99, false},
+		{"next step", 101, true},
+		{"same step again", 101, false},
+		{"earlier step inside the window", 100, false},
+		{"later step", 102, true},
+	}
+	for _, tt := range tests {
+		ok, err := d.MarkTOTPStepUsed(userID, tt.step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.ok {
+			t.Errorf("%s: MarkTOTPStepUsed(%d) = %v, want %v", tt.name, tt.step, ok, tt.ok)
+		}
+	}
+
+	totp, err := d.GetTOTP(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if totp.LastUsedStep != 102 {
+		t.Errorf("last_used_step = %d, want 102", totp.LastUsedStep)
+	}
+}
+
+func TestMarkTOTPStepUsedNeedsEnabledTOTP(t *testing.T) {
+	d := openTestDB(t)
+	userID := insertTestUser(t, d, "bob")
+
+	if err := d.SavePendingTOTP(userID, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"); err != nil {
+		t.Fatal(err)
+	}
+	ok, err := d.MarkTOTPStepUsed(userID, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("MarkTOTPStepUsed accepted a code before 2FA was enabled")
+	}
+}
+
+func TestUseRecoveryCodeOnce(t *testing.T) {
+	d := openTestDB(t)
+	userID := insertTestUser(t, d, "carol")
+
+	if err := d.SavePendingTOTP(userID, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.EnableTOTP(userID, 1, []string{"hash-a", "hash-b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		hash string
+		ok   bool
+	}{
+		{"hash-a", true},
+		{"hash-a", false},
+		{"hash-c", false},
+		{"hash-b", true},
+	} {
+		ok, err := d.UseRecoveryCode(userID, tt.hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != tt.ok {
+			t.Errorf("UseRecoveryCode(%s) = %v, want %v", tt.hash, ok, tt.ok)
+		}
+	}
+	left, err := d.CountUnusedRecoveryCodes(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if left != 0 {
+		t.Errorf("%d recovery codes left, want 0", left)
+	}
+}
@@ -3,9 +3,11 @@ package dbTools
 import (
 	"social_network/utils"
 	"strings"
+	"time"
 )
 
 func (db *DB) InsertUser(u *User) (UserID int, err error) {
+	defer observe("InsertUser", time.Now())
 
 	// Generate UUID for the user if not already set
 	if u.UserUUID == "" {
@@ -32,6 +34,7 @@ func (db *DB) InsertUser(u *User) (UserID int, err error) {
 }
 
 func (db *DB) GetUserByID(id int) (*User, error) {
+	defer observe("GetUserByID", time.Now())
 	query := `SELECT user_id, user_uuid, email, password, firstname, lastname, dateofbirth, nickname, about_me, privacy, role, status
 			  FROM users WHERE user_id = ?`
 
@@ -58,6 +61,7 @@ type UserAPI struct {
 
 // FetchUserByID fetches a single user by ID
 func (d *DB) FetchUserByID(userID int) (*UserAPI, error) {
+	defer observe("FetchUserByID", time.Now())
 	query := `
 		SELECT user_id, user_uuid, first_name, last_name, 
 		       COALESCE(nickname, '') as nickname, 
@@ -82,6 +86,7 @@ func (d *DB) FetchUserByID(userID int) (*UserAPI, error) {
 
 // FetchUserByUUID fetches a single active user by their UUID
 func (d *DB) FetchUserByUUID(userUUID string) (*User, error) {
+	defer observe("FetchUserByUUID", time.Now())
 	const query = `
         SELECT
           user_id,
@@ -132,6 +137,7 @@ func (d *DB) FetchUserByUUID(userUUID string) (*User, error) {
 
 // FetchUsersByIDs fetches multiple users by their IDs
 func (d *DB) FetchUsersByIDs(userIDs []int) ([]UserAPI, error) {
+	defer observe("FetchUsersByIDs", time.Now())
 	if len(userIDs) == 0 {
 		return []UserAPI{}, nil
 	}
@@ -178,3 +184,13 @@ func (d *DB) FetchUsersByIDs(userIDs []int) ([]UserAPI, error) {
 
 	return users, nil
 }
+
+// GetAuthUser returns what requests need to know about a user who may be signed in
+func (d *DB) GetAuthUser(userID int) (userUUID, role, status string, err error) {
+	defer observe("GetAuthUser", time.Now())
+	err = d.db.QueryRow(`
+        SELECT user_uuid, role, status FROM users
+        WHERE user_id = ? AND status IN ('active', 'pending_verification')
+    `, userID).Scan(&userUUID, &role, &status)
+	return userUUID, role, status, err
+}
@@ -1,8 +1,13 @@
 package dbTools
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"social_network/config"
+	"time"
 
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
@@ -11,48 +16,85 @@ import (
 )
 
 type DB struct {
-	db *sql.DB
+	db         *sql.DB
+	migrations string
+	// expectedVersion is the newest migration, read once at startup
+	expectedVersion int
+	uploadsDir      string
+	sessions        config.Sessions
+	ctx             context.Context
 }
 
-func (d *DB) OpenDB() (*DB, error) {
-	log.Println("[DB] Opening database at ./db/socnet.db ...")
-	db, err := sql.Open("sqlite3", "./db/socnet.db")
+// OpenDB opens the database file, brings the schema up to date and keeps the
+// storage settings the rest of the package needs
+func (d *DB) OpenDB(cfg *config.Config) (*DB, error) {
+	d.migrations = cfg.Database.MigrationsDir
+	d.uploadsDir = cfg.Uploads.Dir
+	d.sessions = cfg.Sessions
+
+	slog.Info("Opening database", "path", cfg.Database.Path)
+	db, err := sql.Open("sqlite3", cfg.Database.Path)
 	if err != nil {
-		log.Printf("[DB] sql.Open error: %v", err)
 		return nil, err
 	}
 	d.db = db
 
 	err = d.RunMigration()
 	if err != nil {
-		log.Printf("[DB] Migration error: %v", err)
-		d.db.Close()
+		return nil, err
 	}
 	return d, nil
 }
 
+// UploadsDir is where uploaded files are stored
+func (d *DB) UploadsDir() string {
+	return d.uploadsDir
+}
+
+// Sessions returns the session lifetimes
+func (d *DB) Sessions() config.Sessions {
+	return d.sessions
+}
+
+// WithContext returns a copy of the DB bound to ctx, usually the context of a
+// request, so that what the package logs carries the request ID
+func (d *DB) WithContext(ctx context.Context) *DB {
+	c := *d
+	c.ctx = ctx
+	return &c
+}
+
+// Context returns the context the DB is bound to, or context.Background()
+func (d *DB) Context() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
+}
+
 func (d *DB) RunMigration() error {
-	log.Println("[DB] Running migrations from ./db/migrations ...")
+	slog.Info("Running migrations", "dir", d.migrations)
 	driver, err := sqlite3.WithInstance(d.db, &sqlite3.Config{})
 	if err != nil {
-		log.Printf("[DB] sqlite3.WithInstance error: %v", err)
 		d.db.Close()
-		return err
+		return fmt.Errorf("migration driver: %w", err)
 	}
 
-	m, err := migrate.NewWithDatabaseInstance("file://./db/migrations", "sqlite3", driver)
+	m, err := migrate.NewWithDatabaseInstance("file://"+filepath.ToSlash(d.migrations), "sqlite3", driver)
 	if err != nil {
-		log.Printf("[DB] migrate.NewWithDatabaseInstance error: %v", err)
 		d.db.Close()
-		return err
+		return fmt.Errorf("load migrations: %w", err)
 	}
 
 	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		log.Printf("[DB] m.Up() error: %v", err)
+		d.db.Close()
+		return fmt.Errorf("apply migrations: %w", err)
+	}
+	if d.expectedVersion, err = latestMigration(d.migrations); err != nil {
 		d.db.Close()
 		return err
 	}
-	log.Println("[DB] Migration complete.")
+	slog.Info("Migrations complete")
 	return nil
 }
 
@@ -69,6 +111,7 @@ func (d *DB) GetDB() *sql.DB {
 }
 
 func (d *DB) WithTransaction(fn func(*sql.Tx) error) error {
+	defer observe("WithTransaction", time.Now())
 	tx, err := d.db.Begin()
 	if err != nil {
 		return err
@@ -83,14 +126,17 @@ func (d *DB) WithTransaction(fn func(*sql.Tx) error) error {
 }
 
 func (d *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	defer observe("QueryRow", time.Now())
 	return d.db.QueryRow(query, args...)
 }
 
 func (d *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	defer observe("Query", time.Now())
 	return d.db.Query(query, args...)
 }
 
 func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	defer observe("Exec", time.Now())
 	return d.db.Exec(query, args...)
 }
 
@@ -100,6 +146,7 @@ func (d *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
 // CreateNotification creates a new notification in the database
 // DEPRECATED: Use NotificationService.CreateNotification instead
 func (d *DB) CreateNotification(receiverID, actorID int, actionType, parentType string, parentID int, content string) error {
+	defer observe("CreateNotification", time.Now())
 	service := NewNotificationService(d)
 	return service.CreateNotification(receiverID, actorID, actionType, parentType, parentID, content)
 }
@@ -107,6 +154,7 @@ func (d *DB) CreateNotification(receiverID, actorID int, actionType, parentType
 // GetNotificationsByUserID retrieves all notifications for a user
 // DEPRECATED: Use NotificationService.GetNotificationsByUserID instead
 func (d *DB) GetNotificationsByUserID(userID int) ([]Notification, error) {
+	defer observe("GetNotificationsByUserID", time.Now())
 	service := NewNotificationService(d)
 	return service.GetNotificationsByUserID(userID)
 }
@@ -114,6 +162,7 @@ func (d *DB) GetNotificationsByUserID(userID int) ([]Notification, error) {
 // UpdateNotificationStatus updates a notification's status
 // DEPRECATED: Use NotificationService.UpdateNotificationStatus instead
 func (d *DB) UpdateNotificationStatus(notificationID int, status string, updaterID int) error {
+	defer observe("UpdateNotificationStatus", time.Now())
 	service := NewNotificationService(d)
 	return service.UpdateNotificationStatus(notificationID, status, updaterID)
 }
@@ -0,0 +1,40 @@
+package dbTools
+
+import (
+	"path/filepath"
+	"social_network/config"
+	"testing"
+)
+
+// openTestDB returns a migrated database in a temporary directory
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "test.db")
+	cfg.Database.MigrationsDir = filepath.Join("..", "db", "migrations")
+	cfg.Uploads.Dir = t.TempDir()
+
+	d := &DB{}
+	if _, err := d.OpenDB(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { d.CloseDB() })
+	return d
+}
+
+// insertTestUser adds an active user and returns their ID
+func insertTestUser(t *testing.T, d *DB, name string) int {
+	t.Helper()
+	result, err := d.Exec(`
+        INSERT INTO users (user_uuid, email, password, first_name, last_name, date_of_birth, avatar, status, updated_at)
+        VALUES (?, ?, 'x', ?, 'Test', '2000-01-01', '', 'active', CURRENT_TIMESTAMP)
+    `, name+"-uuid", name+"@example.com", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
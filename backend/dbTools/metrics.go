@@ -0,0 +1,28 @@
+package dbTools
+
+import (
+	"social_network/metrics"
+	"time"
+)
+
+var (
+	queryDuration = metrics.NewHistogramVec("db_query_duration_seconds",
+		"Time spent in dbTools methods, by method.",
+		[]float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}, "method")
+	notificationsCreated = metrics.NewCounterVec("notifications_created_total",
+		"Notifications created, by action type.", "action_type")
+	uploadBytes = metrics.NewCounterVec("upload_bytes_total",
+		"Bytes of uploaded files stored, by what the file belongs to.", "kind")
+)
+
+// observe records the duration of a DB method; call it as
+// defer observe("Method", time.Now())
+func observe(method string, start time.Time) {
+	queryDuration.Observe(time.Since(start).Seconds(), method)
+}
+
+// RecordUpload counts the bytes of a file stored in the uploads directory.
+// kind is what the file belongs to: post, comment, group or avatar.
+func RecordUpload(kind string, bytes int64) {
+	uploadBytes.Add(float64(bytes), kind)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"social_network/auth"
+	"social_network/dbTools"
+	"social_network/utils"
+	"strings"
+	"time"
+)
+
+const (
+	maxAccessTokenNameLength = 64
+	maxAccessTokenLifetime   = 365 // days
+)
+
+type CreateAccessTokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"` // 0 means no expiry
+}
+
+func listAccessTokens(w http.ResponseWriter, db *dbTools.DB, userID int) {
+	tokens, err := db.GetAccessTokens(userID)
+	if err != nil {
+		slog.ErrorContext(db.Context(), "Access tokens fetch failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch access tokens")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"tokens": tokens})
+}
+
+func createAccessToken(w http.ResponseWriter, r *http.Request, db *dbTools.DB, userID int) {
+	var req CreateAccessTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	var fields utils.FieldErrors
+	name := utils.Sanitize(strings.TrimSpace(req.Name))
+	fields.Check("name", utils.ValidateText("Token name", name, maxAccessTokenNameLength))
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAccessTokenLifetime {
+		fields.Add("expires_in_days", utils.FieldInvalid, "expires_in_days must be between 0 and 365")
+	}
+
+	scopes := []string{}
+	seen := map[string]bool{}
+	for _, scope := range req.Scopes {
+		if !utils.IsValidScope(scope) {
+			fields.Add("scopes", utils.FieldInvalid, "Unknown scope: "+scope)
+			continue
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(req.Scopes) == 0 {
+		fields.Add("scopes", utils.FieldRequired, "At least one scope is required")
+	}
+	if err := fields.Err(); err != nil {
+		utils.SendError(w, err)
+		return
+	}
+	if seen[utils.ScopeAdmin] {
+		if principal, _ := auth.FromRequest(r); !auth.Can(principal, auth.PermAdminAccess, auth.Resource{}) {
+			utils.SendErrorResponse(w, http.StatusForbidden, "Only admins can create tokens with the admin scope")
+			return
+		}
+	}
+
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Access token generation failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to create access token")
+		return
+	}
+	publicUUID, err := utils.GenerateUUID()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Access token UUID generation failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to create access token")
+		return
+	}
+	token := utils.AccessTokenPrefix + secret
+
+	accessToken := &dbTools.AccessToken{
+		PublicUUID: publicUUID,
+		UserID:     userID,
+		Name:       name,
+		TokenHint:  token[len(token)-4:],
+		Scopes:     scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().UTC().AddDate(0, 0, req.ExpiresInDays)
+		accessToken.ExpiresAt = &expiresAt
+	}
+
+	if err := db.CreateAccessToken(accessToken, utils.HashToken(token)); err != nil {
+		slog.ErrorContext(r.Context(), "Access token store failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to create access token")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	utils.SendSuccessResponse(w, map[string]interface{}{
+		"token":        token,
+		"access_token": accessToken,
+		"message":      "Copy the token now, it will not be shown again",
+	})
+}
+
+func revokeAccessToken(w http.ResponseWriter, db *dbTools.DB, userID int, publicUUID string) {
+	found, err := db.RevokeAccessToken(userID, publicUUID)
+	if err != nil {
+		slog.ErrorContext(db.Context(), "Access token revoke failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to revoke access token")
+		return
+	}
+	if !found {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Access token not found")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Access token revoked"})
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"social_network/dbTools"
+	"social_network/mailer"
+	"social_network/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type ChangeEmailRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewEmail        string `json:"new_email"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+type CloseAccountRequest struct {
+	CurrentPassword string `json:"current_password"`
+}
+
+// Accounts is what the account handlers need besides the database
+type Accounts struct {
+	Mailer mailer.Mailer
+	// AppURL is the frontend that links in emails and OIDC redirects point at
+	AppURL         string
+	PasswordPolicy utils.PasswordPolicy
+	// DeletionGracePeriod is how long a deleted account can still be
+	// restored by logging in before it is erased
+	DeletionGracePeriod time.Duration
+}
+
+func changeEmail(w http.ResponseWriter, r *http.Request, db *dbTools.DB, acc Accounts, userID int) {
+	var req ChangeEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	req.NewEmail = strings.TrimSpace(req.NewEmail)
+	if err := utils.ValidateEmail(req.NewEmail); err != nil {
+		utils.SendError(w, utils.InvalidField("new_email", err))
+		return
+	}
+
+	user, ok := reauthenticate(w, r, db, userID, req.CurrentPassword)
+	if !ok {
+		return
+	}
+	if utils.NormalizeEmail(req.NewEmail) == utils.NormalizeEmail(user.Email) {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "New email is the same as the current one")
+		return
+	}
+
+	err := db.UpdateEmail(userID, req.NewEmail, utils.GetSessionUUID(r))
+	if errors.Is(err, dbTools.ErrEmailTaken) {
+		utils.SendErrorResponse(w, http.StatusConflict, "Email already registered")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Email change failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to change email")
+		return
+	}
+
+	notifyCredentialChange(r.Context(), acc, user, "Your email address was changed",
+		fmt.Sprintf("the email address of your account was changed to %s", req.NewEmail))
+
+	updated := user
+	updated.Email = req.NewEmail
+	updated.Status = "pending_verification"
+	if _, err := sendVerificationEmail(db, acc, updated); err != nil {
+		slog.ErrorContext(r.Context(), "Verification email failed", "user_id", userID, "err", err)
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{
+		"message":                     "Email changed, please confirm the new address",
+		"email_verification_required": true,
+	})
+}
+
+func changePassword(w http.ResponseWriter, r *http.Request, db *dbTools.DB, acc Accounts, userID int) {
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := utils.ValidatePasswordStrength(req.NewPassword, acc.PasswordPolicy); err != nil {
+		utils.SendError(w, utils.InvalidField("new_password", err))
+		return
+	}
+
+	user, ok := reauthenticate(w, r, db, userID, req.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Password hash failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+	if err := db.UpdatePassword(userID, string(hashedPassword), utils.GetSessionUUID(r)); err != nil {
+		slog.ErrorContext(r.Context(), "Password change failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to change password")
+		return
+	}
+
+	notifyCredentialChange(r.Context(), acc, user, "Your password was changed", "the password of your account was changed")
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Password changed, other sessions have been signed out and access tokens revoked"})
+}
+
+func deactivateAccount(w http.ResponseWriter, r *http.Request, db *dbTools.DB, userID int) {
+	var req CloseAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if _, ok := reauthenticate(w, r, db, userID, req.CurrentPassword); !ok {
+		return
+	}
+
+	if err := db.DeactivateUser(userID); err != nil {
+		slog.ErrorContext(r.Context(), "Deactivation failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to deactivate account")
+		return
+	}
+	utils.ClearSessionCookie(w)
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Account deactivated, log in again to reactivate it"})
+}
+
+func deleteAccount(w http.ResponseWriter, r *http.Request, db *dbTools.DB, acc Accounts, userID int) {
+	var req CloseAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	user, ok := reauthenticate(w, r, db, userID, req.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	deletion, err := db.ScheduleAccountDeletion(userID, time.Now().Add(acc.DeletionGracePeriod))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Account deletion request failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to delete account")
+		return
+	}
+	utils.ClearSessionCookie(w)
+	slog.InfoContext(r.Context(), "Account deletion scheduled", "deletion_id", deletion.DeletionID, "user_id", userID, "scheduled_for", deletion.ScheduledFor)
+
+	when := deletion.ScheduledFor.Format("2 January 2006")
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Your account will be deleted",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Your account has been deactivated and will be permanently deleted on %s, "+
+			"together with your posts, comments, messages and uploaded files.\n\n"+
+			"Changed your mind? Log in before then at %s/login and the deletion is cancelled.\n",
+			user.FirstName, when, acc.AppURL),
+	}
+	goBackground(func() {
+		if err := acc.Mailer.Send(msg); err != nil {
+			slog.ErrorContext(r.Context(), "Account deletion mail failed", "user_id", userID, "err", err)
+		}
+	})
+
+	utils.SendSuccessResponse(w, map[string]interface{}{
+		"message":       "Account scheduled for deletion, log in before the date to cancel",
+		"scheduled_for": deletion.ScheduledFor,
+	})
+}
+
+// reauthenticate checks the current password of the logged in user and writes
+// an error response if it is wrong. Wrong passwords count as failed logins so
+// a stolen session cannot be used to guess the password.
+func reauthenticate(w http.ResponseWriter, r *http.Request, db *dbTools.DB, userID int, password string) (dbTools.User, bool) {
+	user, hashedPassword, err := fetchLoginUser(db, "user_id", userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Re-authentication user lookup failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to check password")
+		return user, false
+	}
+
+	if err := utils.CheckLoginAllowed(db.GetDB(), user.Email, utils.ClientIP(r)); err != nil {
+		var blocked *utils.LoginBlockedError
+		if !errors.As(err, &blocked) {
+			slog.ErrorContext(r.Context(), "Login guard failed", "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to check password")
+			return user, false
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(blocked.RetryAfterSeconds()))
+		utils.SendError(w, utils.NewAPIError(http.StatusTooManyRequests, utils.CodeLoginBlocked, blocked.Error()))
+		return user, false
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password)) != nil {
+		utils.RecordLoginAttempt(db.GetDB(), r, user.Email, user.UserID, false, utils.LoginFailureInvalidCredentials)
+		utils.SendError(w, utils.NewAPIError(http.StatusUnauthorized, utils.CodeInvalidCredentials, "Incorrect password"))
+		return user, false
+	}
+	return user, true
+}
+
+// notifyCredentialChange warns the account's (old) email address about a security relevant change
+func notifyCredentialChange(ctx context.Context, acc Accounts, user dbTools.User, subject, change string) {
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"This is a notice that %s. All other devices have been signed out and your access tokens revoked.\n\n"+
+			"If you did not do this, reset your password right away at %s/forgot-password and contact us.\n",
+			user.FirstName, change, acc.AppURL),
+	}
+	goBackground(func() {
+		if err := acc.Mailer.Send(msg); err != nil {
+			slog.ErrorContext(ctx, "Credential change mail failed", "user_id", user.UserID, "err", err)
+		}
+	})
+}
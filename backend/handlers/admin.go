@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"social_network/auth"
+	"social_network/dbTools"
+	"social_network/metrics"
+	"social_network/middleware"
+	"social_network/utils"
+	"strconv"
+)
+
+// AdminLoginAttemptsHandler lists recent login attempts for admins.
+// Query parameters: email, ip, failed=true, limit (max 500).
+func AdminLoginAttemptsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, auth.PermAdminAccess); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	attempts, err := db.GetLoginAttempts(dbTools.LoginAttemptFilter{
+		Email:      query.Get("email"),
+		IPAddress:  query.Get("ip"),
+		FailedOnly: query.Get("failed") == "true",
+		Limit:      limit,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Login attempts fetch failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch login attempts")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"attempts": attempts})
+}
+
+// AdminAccountDeletionsHandler lists account deletion requests and their reports.
+// Query parameters: status, limit (max 500).
+func AdminAccountDeletionsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, auth.PermAdminAccess); !ok {
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	deletions, err := db.GetAccountDeletions(r.URL.Query().Get("status"), limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Account deletions fetch failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch account deletions")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"deletions": deletions})
+}
+
+func setUserRole(w http.ResponseWriter, r *http.Request, db *dbTools.DB, principal *auth.Principal, userUUID string) {
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !auth.ValidRole(req.Role) {
+		var fields utils.FieldErrors
+		fields.Add("role", utils.FieldInvalid, "Role must be user, group_moderator or admin")
+		utils.SendError(w, fields.Err())
+		return
+	}
+
+	user, err := db.FetchUserByUUID(userUUID)
+	if err == sql.ErrNoRows {
+		utils.SendErrorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "User fetch failed", "user_uuid", userUUID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to change role")
+		return
+	}
+	// An admin demoting themselves could leave nobody able to undo it
+	if user.UserID == principal.ID {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "You cannot change your own role")
+		return
+	}
+
+	if err := db.SetUserRole(user.UserID, req.Role, principal.ID); err != nil {
+		slog.ErrorContext(r.Context(), "Role change failed", "user_id", user.UserID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to change role")
+		return
+	}
+	slog.InfoContext(r.Context(), "User role changed", "admin_id", principal.ID, "user_id", user.UserID, "from", user.Role, "to", req.Role)
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"user_uuid": user.UserUUID, "role": req.Role})
+}
+
+func listGroupModerators(w http.ResponseWriter, db *dbTools.DB, groupID int) {
+	moderators, err := db.GetGroupModerators(groupID)
+	if err != nil {
+		slog.ErrorContext(db.Context(), "Group moderators fetch failed", "group_id", groupID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch moderators")
+		return
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{"moderators": moderators})
+}
+
+func assignGroupModerator(w http.ResponseWriter, db *dbTools.DB, principal *auth.Principal, groupID int, userUUID string) {
+	group, err := db.GetGroupByID(groupID)
+	if err != nil {
+		slog.ErrorContext(db.Context(), "Group fetch failed", "group_id", groupID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to assign moderator")
+		return
+	}
+	if group == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Group not found")
+		return
+	}
+
+	user, err := db.FetchUserByUUID(userUUID)
+	if err == sql.ErrNoRows {
+		utils.SendErrorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(db.Context(), "User fetch failed", "user_uuid", userUUID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to assign moderator")
+		return
+	}
+	if user.Role != auth.RoleGroupModerator {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "User must have the group_moderator role")
+		return
+	}
+
+	if err := db.AssignGroupModerator(groupID, user.UserID, principal.ID); err != nil {
+		slog.ErrorContext(db.Context(), "Moderator assignment failed", "group_id", groupID, "user_id", user.UserID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to assign moderator")
+		return
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Moderator assigned"})
+}
+
+func removeGroupModerator(w http.ResponseWriter, db *dbTools.DB, groupID int, userUUID string) {
+	user, err := db.FetchUserByUUID(userUUID)
+	if err == sql.ErrNoRows {
+		utils.SendErrorResponse(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(db.Context(), "User fetch failed", "user_uuid", userUUID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to remove moderator")
+		return
+	}
+
+	found, err := db.RemoveGroupModerator(groupID, user.UserID)
+	if err != nil {
+		slog.ErrorContext(db.Context(), "Moderator removal failed", "group_id", groupID, "user_id", user.UserID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to remove moderator")
+		return
+	}
+	if !found {
+		utils.SendErrorResponse(w, http.StatusNotFound, "User is not a moderator of this group")
+		return
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Moderator removed"})
+}
+
+// MetricsHandler serves the Prometheus metrics to admins. Scrapers
+// authenticate with an access token with the admin scope.
+func MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := requirePermission(w, r, auth.PermAdminAccess); !ok {
+		return
+	}
+	metrics.Handler(w, r)
+}
+
+// requirePermission checks that the principal holds a site-wide permission and writes an error response otherwise
+func requirePermission(w http.ResponseWriter, r *http.Request, perm auth.Permission) (*auth.Principal, bool) {
+	principal, ok := auth.FromRequest(r)
+	if !ok {
+		middleware.WriteUnauthorized(w)
+		return nil, false
+	}
+	if !auth.Can(principal, perm, auth.Resource{}) {
+		utils.SendErrorResponse(w, http.StatusForbidden, "Admin access required")
+		return nil, false
+	}
+	return principal, true
+}
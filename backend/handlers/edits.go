@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"social_network/auth"
+	"social_network/dbTools"
+	"social_network/utils"
+	"strconv"
+	"time"
+)
+
+// EditPostRequest is the body of PUT /api/v1/posts/{uuid}
+type EditPostRequest struct {
+	Content string `json:"content"`
+	// Categories replace the post's categories; when left out the current ones
+	// are kept. Hashtags always follow the new content.
+	Categories *[]string `json:"categories"`
+}
+
+// EditCommentRequest is the body of PUT /api/v1/comments/{id}
+type EditCommentRequest struct {
+	Content string `json:"content"`
+}
+
+// EditPostHandler changes the content of a post. The author, the creator of
+// the post's group and moderators may edit it.
+func EditPostHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	post, ok := postToChange(db, w, r)
+	if !ok {
+		return
+	}
+	var req EditPostRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := utils.ValidateText("Content", req.Content, maxContentLength); err != nil {
+		utils.SendError(w, utils.InvalidField("content", err))
+		return
+	}
+
+	var categories []string
+	if req.Categories != nil {
+		categories = *req.Categories
+	} else {
+		// Keep the categories that did not come from the old content's hashtags
+		current, err := db.GetPostCategories(post.PostID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Post categories lookup failed", "post_id", post.PostID, "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to load post categories")
+			return
+		}
+		oldHashtags := map[string]bool{}
+		for _, tag := range utils.ExtractHashtags(post.Content) {
+			oldHashtags[tag] = true
+		}
+		for _, tag := range current {
+			if !oldHashtags[tag] {
+				categories = append(categories, tag)
+			}
+		}
+	}
+	tags, apiErr := postTags(categories, req.Content)
+	if apiErr != nil {
+		utils.SendError(w, apiErr)
+		return
+	}
+	content := utils.Sanitize(req.Content)
+
+	userID := auth.UserID(r)
+	editedAt := time.Now()
+	found, err := db.EditPost(post.PostID, userID, content, tags, editedAt)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Editing post failed", "post_id", post.PostID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to edit post")
+		return
+	}
+	if !found {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{
+		"post_uuid":  post.PostUUID,
+		"content":    content,
+		"categories": tags,
+		"edited":     true,
+		"edited_at":  editedAt,
+	})
+}
+
+// DeletePostHandler soft-deletes a post with its comments and attachments
+func DeletePostHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	post, ok := postToChange(db, w, r)
+	if !ok {
+		return
+	}
+	found, err := db.DeletePost(post.PostID, auth.UserID(r))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Deleting post failed", "post_id", post.PostID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to delete post")
+		return
+	}
+	if !found {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Post not found")
+		return
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Post deleted"})
+}
+
+// GetPostRevisionsHandler lists the earlier versions of a post to those who may edit it
+func GetPostRevisionsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	post, ok := postToChange(db, w, r)
+	if !ok {
+		return
+	}
+	sendRevisions(db, w, r, "post", post.PostID)
+}
+
+// EditCommentHandler changes the content of a comment. The author, the
+// creator of the post's group and moderators may edit it.
+func EditCommentHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	comment, ok := commentToChange(db, w, r)
+	if !ok {
+		return
+	}
+	var req EditCommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := utils.ValidateText("Content", req.Content, maxContentLength); err != nil {
+		utils.SendError(w, utils.InvalidField("content", err))
+		return
+	}
+	content := utils.Sanitize(req.Content)
+
+	editedAt := time.Now()
+	found, err := db.EditComment(comment.CommentID, auth.UserID(r), content, editedAt)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Editing comment failed", "comment_id", comment.CommentID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to edit comment")
+		return
+	}
+	if !found {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{
+		"comment_id": comment.CommentID,
+		"content":    content,
+		"edited":     true,
+		"edited_at":  editedAt,
+	})
+}
+
+// DeleteCommentHandler soft-deletes a comment and its attachment
+func DeleteCommentHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	comment, ok := commentToChange(db, w, r)
+	if !ok {
+		return
+	}
+	found, err := db.DeleteComment(comment.CommentID, auth.UserID(r))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Deleting comment failed", "comment_id", comment.CommentID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to delete comment")
+		return
+	}
+	if !found {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Comment not found")
+		return
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Comment deleted"})
+}
+
+// GetCommentRevisionsHandler lists the earlier versions of a comment to those who may edit it
+func GetCommentRevisionsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	comment, ok := commentToChange(db, w, r)
+	if !ok {
+		return
+	}
+	sendRevisions(db, w, r, "comment", comment.CommentID)
+}
+
+func sendRevisions(db *dbTools.DB, w http.ResponseWriter, r *http.Request, parentType string, parentID int) {
+	revisions, err := db.GetRevisions(parentType, parentID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Revision lookup failed", "parent_type", parentType, "parent_id", parentID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to load revisions")
+		return
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{"revisions": revisions})
+}
+
+// postToChange loads the post in the path and checks that the user may edit
+// or delete it. It has answered the request when ok is false.
+func postToChange(db *dbTools.DB, w http.ResponseWriter, r *http.Request) (post *dbTools.Post, ok bool) {
+	postUUID := r.PathValue("uuid")
+	post, err := db.GetPostByUUID(r.Context(), postUUID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Post lookup failed", "post_uuid", postUUID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to load post")
+		return nil, false
+	}
+	if post == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Post not found")
+		return nil, false
+	}
+	if !checkContentPermission(db, w, r, post, post.PosterID, "Post not found", "You can only change your own posts") {
+		return nil, false
+	}
+	return post, true
+}
+
+// commentToChange loads the comment in the path and checks that the user may
+// edit or delete it. It has answered the request when ok is false.
+func commentToChange(db *dbTools.DB, w http.ResponseWriter, r *http.Request) (comment *dbTools.Comment, ok bool) {
+	comment, post, ok := commentWithPost(db, w, r)
+	if !ok {
+		return nil, false
+	}
+	if !checkContentPermission(db, w, r, post, comment.CommenterID, "Comment not found", "You can only change your own comments") {
+		return nil, false
+	}
+	return comment, true
+}
+
+// commentWithPost loads the comment in the {id} path value and the post it
+// belongs to. It has answered the request when ok is false.
+func commentWithPost(db *dbTools.DB, w http.ResponseWriter, r *http.Request) (comment *dbTools.Comment, post *dbTools.Post, ok bool) {
+	commentID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid comment ID")
+		return nil, nil, false
+	}
+	comment, err = db.GetCommentByID(r.Context(), commentID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Comment lookup failed", "comment_id", commentID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to load comment")
+		return nil, nil, false
+	}
+	if comment != nil {
+		post, err = db.GetPostByID(r.Context(), comment.PostID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Post lookup failed", "post_id", comment.PostID, "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to load comment")
+			return nil, nil, false
+		}
+	}
+	if post == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Comment not found")
+		return nil, nil, false
+	}
+	return comment, post, true
+}
+
+// checkContentPermission checks PermModerateContent on a post or comment
+// written by ownerID. Users who cannot see the post get notFound; everyone
+// else who is refused gets forbidden.
+func checkContentPermission(db *dbTools.DB, w http.ResponseWriter, r *http.Request, post *dbTools.Post, ownerID int, notFound, forbidden string) bool {
+	principal, _ := auth.FromRequest(r)
+	var res auth.Resource
+	var err error
+	if post.GroupID != nil {
+		res, err = groupResource(db, *post.GroupID, principal)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Content permission check failed", "post_id", post.PostID, "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to check permissions")
+			return false
+		}
+	}
+	res.OwnerID = ownerID
+	if auth.Can(principal, auth.PermModerateContent, res) {
+		return true
+	}
+
+	if postVisible(db, w, r, post, notFound) {
+		utils.SendErrorResponse(w, http.StatusForbidden, forbidden)
+	}
+	return false
+}
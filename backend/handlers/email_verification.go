@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"social_network/auth"
+	"social_network/dbTools"
+	"social_network/mailer"
+	"social_network/utils"
+	"time"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// VerifyEmailHandler confirms an email address using the signed link sent after registration
+func VerifyEmailHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	var req VerifyEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	userID, email, err := utils.ParseEmailVerificationToken(req.Token, time.Now())
+	if errors.Is(err, utils.ErrInvalidVerificationToken) {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Verification link is invalid or has expired")
+		return
+	}
+
+	verified, err := db.MarkEmailVerified(userID, email)
+	if err == nil && !verified {
+		// Opening the link twice is not an error as long as the address is verified
+		verified, err = db.IsEmailVerified(userID, email)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Email verification failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to verify email")
+		return
+	}
+	if !verified {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Verification link is invalid or has expired")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Email verified"})
+}
+
+// ResendVerificationHandler sends a new verification email to the logged in, unverified user
+func ResendVerificationHandler(db *dbTools.DB, acc Accounts, w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserID(r)
+
+	user, _, err := fetchLoginUser(db, "user_id", userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Verification resend user lookup failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+	if user.Status != "pending_verification" {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Email is already verified")
+		return
+	}
+
+	sent, err := sendVerificationEmail(db, acc, user)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Verification resend failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to send verification email")
+		return
+	}
+	if !sent {
+		w.Header().Set("Retry-After", fmt.Sprint(int(utils.EmailVerificationResendInterval.Seconds())))
+		utils.SendErrorResponse(w, http.StatusTooManyRequests, "Verification email was sent recently, please wait before asking again")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Verification email sent"})
+}
+
+// sendVerificationEmail mails a signed verification link to a pending user.
+// It returns false without sending if an email went out too recently.
+func sendVerificationEmail(db *dbTools.DB, acc Accounts, user dbTools.User) (bool, error) {
+	claimed, err := db.ClaimVerificationEmail(user.UserID, utils.EmailVerificationResendInterval)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	token := utils.NewEmailVerificationToken(user.UserID, user.Email, time.Now())
+	link := acc.AppURL + "/verify-email?token=" + url.QueryEscape(token)
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Welcome! Please confirm your email address by opening this link within %d hours:\n\n"+
+			"%s\n\n"+
+			"Until then your account can only browse; posting, commenting and messaging unlock after confirmation.\n",
+			user.FirstName, int(utils.EmailVerificationTTL.Hours()), link),
+	}
+	goBackground(func() {
+		if err := acc.Mailer.Send(msg); err != nil {
+			slog.ErrorContext(db.Context(), "Verification mail failed", "user_id", user.UserID, "err", err)
+		}
+	})
+	return true, nil
+}
@@ -3,19 +3,14 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"social_network/auth"
 	"social_network/dbTools"
-	"social_network/middleware"
 	"social_network/utils"
-	"strings"
-	"time"
 )
 
 func FollowHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	log.Printf("FollowHandler called at %s for URL %s", time.Now().Format(time.RFC3339), r.URL.Path)
-	middleware.SetCORSHeaders(w)
-
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
@@ -26,29 +21,22 @@ func FollowHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract user_uuid from URL
-	path := strings.TrimPrefix(r.URL.Path, "/api/follow/")
-	userUUID := strings.Split(path, "/")[0]
+	userUUID := r.PathValue("uuid")
 	if userUUID == "" {
 		utils.SendErrorResponse(w, http.StatusBadRequest, "Missing user UUID")
 		return
 	}
 
 	// Get current user ID from session
-	currentUserID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		log.Printf("Session check error: %v", err)
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid session")
-		return
-	}
+	currentUserID := auth.UserID(r)
 
 	// Get followed user ID and privacy
 	var followedUserID int
 	var privacy string
 	userQuery := `SELECT user_id, privacy FROM users WHERE user_uuid = ? AND status = 'active'`
-	err = db.GetDB().QueryRow(userQuery, userUUID).Scan(&followedUserID, &privacy)
+	err := db.GetDB().QueryRow(userQuery, userUUID).Scan(&followedUserID, &privacy)
 	if err != nil {
-		log.Printf("User fetch error for user_uuid %s: %v", userUUID, err)
+		slog.DebugContext(r.Context(), "Follow: user lookup failed", "user_uuid", userUUID, "err", err)
 		utils.SendErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
@@ -88,13 +76,13 @@ func FollowHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
             `
 			result, err := db.GetDB().Exec(updateQuery, newStatus, currentUserID, followID)
 			if err != nil {
-				log.Printf("Follow update error for follow_id %d: %v", followID, err)
+				slog.ErrorContext(r.Context(), "Follow update failed", "follow_id", followID, "err", err)
 				utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to update follow status")
 				return
 			}
 			rowsAffected, err := result.RowsAffected()
 			if err != nil || rowsAffected == 0 {
-				log.Printf("No rows affected for follow_id %d: %v", followID, err)
+				slog.ErrorContext(r.Context(), "Follow update affected no rows", "follow_id", followID, "err", err)
 				utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to update follow status")
 				return
 			}
@@ -106,7 +94,7 @@ func FollowHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 				err = notificationHelpers.CreateFollowRequestNotification(currentUserID, followedUserID, followID)
 			}
 			if err != nil {
-				log.Printf("Notification creation error for follow_id %d: %v", followID, err)
+				slog.ErrorContext(r.Context(), "Notification creation failed", "follow_id", followID, "err", err)
 				// Continue despite notification error to avoid blocking
 			}
 		} else if err == sql.ErrNoRows {
@@ -121,13 +109,13 @@ func FollowHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
             `
 			result, err := db.GetDB().Exec(followQuery, currentUserID, followedUserID, status, currentUserID)
 			if err != nil {
-				log.Printf("Follow insert error: %v", err)
+				slog.ErrorContext(r.Context(), "Follow insert failed", "err", err)
 				utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to follow")
 				return
 			}
 			followID64, err := result.LastInsertId()
 			if err != nil {
-				log.Printf("Failed to get follow_id: %v", err)
+				slog.ErrorContext(r.Context(), "Failed to get follow_id", "err", err)
 				utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve follow ID")
 				return
 			}
@@ -139,11 +127,11 @@ func FollowHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 				err = notificationHelpers.CreateFollowRequestNotification(currentUserID, followedUserID, followID)
 			}
 			if err != nil {
-				log.Printf("Notification creation error for follow_id %d: %v", followID, err)
+				slog.ErrorContext(r.Context(), "Notification creation failed", "follow_id", followID, "err", err)
 				// Continue despite notification error
 			}
 		} else {
-			log.Printf("Follow check error: %v", err)
+			slog.ErrorContext(r.Context(), "Follow check failed", "err", err)
 			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to check follow status")
 			return
 		}
@@ -155,13 +143,13 @@ func FollowHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
         `
 		result, err := db.GetDB().Exec(updateQuery, currentUserID, currentUserID, followedUserID)
 		if err != nil {
-			log.Printf("Follow cancel error: %v", err)
+			slog.ErrorContext(r.Context(), "Follow cancel failed", "err", err)
 			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to cancel follow")
 			return
 		}
 		rowsAffected, err := result.RowsAffected()
 		if err != nil || rowsAffected == 0 {
-			log.Printf("No rows affected for follower_user_id %d, followed_user_id %d: %v", currentUserID, followedUserID, err)
+			slog.DebugContext(r.Context(), "Follow: nothing to cancel", "follower_id", currentUserID, "followed_id", followedUserID, "err", err)
 			utils.SendErrorResponse(w, http.StatusBadRequest, "No follow relationship to cancel")
 			return
 		}
@@ -177,9 +165,6 @@ func FollowHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 
 // FollowStatusHandler checks if the current user is following a profile
 func FollowStatusHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	log.Printf("FollowStatusHandler called at %s for URL %s", time.Now().Format(time.RFC3339), r.URL.Path)
-	middleware.SetCORSHeaders(w)
-
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
@@ -190,28 +175,21 @@ func FollowStatusHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Extract user_uuid from URL
-	path := strings.TrimPrefix(r.URL.Path, "/api/follow/status/")
-	userUUID := strings.Split(path, "/")[0]
+	userUUID := r.PathValue("uuid")
 	if userUUID == "" {
 		utils.SendErrorResponse(w, http.StatusBadRequest, "Missing user UUID")
 		return
 	}
 
 	// Get current user ID from session
-	currentUserID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		log.Printf("Session check error: %v", err)
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid session")
-		return
-	}
+	currentUserID := auth.UserID(r)
 
 	// Get followed user ID from user_uuid
 	var followedUserID int
 	userQuery := `SELECT user_id FROM users WHERE user_uuid = ? AND status = 'active'`
-	err = db.GetDB().QueryRow(userQuery, userUUID).Scan(&followedUserID)
+	err := db.GetDB().QueryRow(userQuery, userUUID).Scan(&followedUserID)
 	if err != nil {
-		log.Printf("User fetch error for user_uuid %s: %v", userUUID, err)
+		slog.DebugContext(r.Context(), "Follow status: user lookup failed", "user_uuid", userUUID, "err", err)
 		utils.SendErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
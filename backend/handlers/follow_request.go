@@ -2,42 +2,31 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"social_network/auth"
 	"social_network/dbTools"
-	"social_network/middleware"
 	"social_network/utils"
-	"time"
 )
 
 func FollowRequestHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	log.Printf("FollowRequestHandler called at %s for URL %s, Method: %s", time.Now().Format(time.RFC3339), r.URL.Path, r.Method)
-	middleware.SetCORSHeaders(w)
-
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 
 	if r.Method != "POST" {
-		log.Printf("Invalid method: %s", r.Method)
 		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Only POST is allowed")
 		return
 	}
 
-	currentUserID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		log.Printf("Session check error: %v", err)
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid session")
-		return
-	}
+	currentUserID := auth.UserID(r)
 
 	var requestBody struct {
 		FollowID int    `json:"follow_id"`
 		Action   string `json:"action"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-		log.Printf("Invalid request body: %v", err)
 		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
@@ -49,7 +38,6 @@ func FollowRequestHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request
 	case "decline":
 		newStatus = "declined"
 	default:
-		log.Printf("Invalid action: %s", requestBody.Action)
 		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid action: must be 'accept' or 'decline'")
 		return
 	}
@@ -61,14 +49,14 @@ func FollowRequestHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request
         FROM follows
         WHERE follow_id = ? AND status = 'pending'
     `
-	err = db.GetDB().QueryRow(checkQuery, requestBody.FollowID).Scan(&followedUserID, &followerUserID, &status)
+	err := db.GetDB().QueryRow(checkQuery, requestBody.FollowID).Scan(&followedUserID, &followerUserID, &status)
 	if err != nil {
-		log.Printf("Follow request fetch error: %v", err)
+		slog.ErrorContext(r.Context(), "Follow request fetch failed", "err", err)
 		utils.SendErrorResponse(w, http.StatusNotFound, "Follow request not found or not pending")
 		return
 	}
 	if currentUserID != followedUserID {
-		log.Printf("Unauthorized attempt by user %d to manage follow request %d", currentUserID, requestBody.FollowID)
+		slog.WarnContext(r.Context(), "Follow request managed by another user", "user_id", currentUserID, "follow_id", requestBody.FollowID)
 		utils.SendErrorResponse(w, http.StatusForbidden, "Unauthorized to manage this request")
 		return
 	}
@@ -80,14 +68,14 @@ func FollowRequestHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request
     `
 	result, err := db.GetDB().Exec(updateQuery, newStatus, currentUserID, requestBody.FollowID)
 	if err != nil {
-		log.Printf("Follow update error: %v", err)
+		slog.ErrorContext(r.Context(), "Follow update failed", "err", err)
 		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to update follow request")
 		return
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil || rowsAffected == 0 {
-		log.Printf("No rows affected for follow_id %d: %v", requestBody.FollowID, err)
+		slog.ErrorContext(r.Context(), "Follow update affected no rows", "follow_id", requestBody.FollowID, "err", err)
 		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to update follow request")
 		return
 	}
@@ -98,14 +86,14 @@ func FollowRequestHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request
 	// Mark the original follow request notification as read
 	err = notificationHelpers.UpdateFollowRequestNotificationStatus(requestBody.FollowID, currentUserID)
 	if err != nil {
-		log.Printf("Notification update error for follow_id %d: %v", requestBody.FollowID, err)
+		slog.ErrorContext(r.Context(), "Notification update failed", "follow_id", requestBody.FollowID, "err", err)
 	}
 
 	// Create follow_accepted notification if accepted
 	if newStatus == "accepted" {
 		err = notificationHelpers.CreateFollowAcceptedNotification(followerUserID, currentUserID, requestBody.FollowID)
 		if err != nil {
-			log.Printf("Notification creation error for follow_id %d: %v", requestBody.FollowID, err)
+			slog.ErrorContext(r.Context(), "Notification creation failed", "follow_id", requestBody.FollowID, "err", err)
 		}
 	}
 	utils.SendSuccessResponse(w, map[string]interface{}{
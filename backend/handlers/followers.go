@@ -2,35 +2,27 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"social_network/auth"
 	"social_network/dbTools"
-	"social_network/middleware"
 	"social_network/utils"
-	"strings"
-	"time"
 )
 
 // GetFollowersHandler fetches the list of followers for a user
 func GetFollowersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	log.Printf("GetFollowersHandler called at %s for URL %s", time.Now().Format(time.RFC3339), r.URL.Path)
-	middleware.SetCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 	if r.Method != "GET" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Method not allowed"})
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	// Extract user_uuid from URL path
-	path := strings.TrimPrefix(r.URL.Path, "/api/followers/")
-	userUUID := strings.Split(path, "/")[0]
+	userUUID := r.PathValue("uuid")
 	if userUUID == "" || userUUID == "me" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Missing user UUID"})
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Missing user UUID")
 		return
 	}
 
@@ -44,22 +36,19 @@ func GetFollowersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
     `
 	err := db.QueryRow(query, userUUID).Scan(&userID, &privacy)
 	if err != nil {
-		log.Printf("User fetch error for user_uuid %s: %v", userUUID, err)
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "User not found"})
+		slog.DebugContext(r.Context(), "Followers: user lookup failed", "user_uuid", userUUID, "err", err)
+		utils.SendErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
 
 	// Check authorization
 	isAuthorized := false
-	currentUserID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err == nil {
+	currentUserID := auth.UserID(r)
+	if currentUserID != 0 {
 		if int(currentUserID) == userID {
 			isAuthorized = true
-			log.Println("Authorized: Own profile")
 		} else if privacy == "public" {
 			isAuthorized = true
-			log.Println("Authorized: Public profile")
 		} else {
 			followerQuery := `
                 SELECT COUNT(*)
@@ -69,19 +58,16 @@ func GetFollowersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
 			var followerCount int
 			err = db.QueryRow(followerQuery, currentUserID, userID).Scan(&followerCount)
 			if err != nil {
-				log.Printf("Follower query error: %v", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Follower check error"})
+				slog.ErrorContext(r.Context(), "Followers: follow status query failed", "err", err)
+				utils.SendErrorResponse(w, http.StatusInternalServerError, "Follower check error")
 				return
 			}
 			if followerCount > 0 {
 				isAuthorized = true
-				log.Println("Authorized: Accepted follower")
 			}
 		}
 	} else if privacy == "public" {
 		isAuthorized = true
-		log.Println("Authorized: Public profile, no login")
 	}
 
 	// Prepare response
@@ -95,7 +81,6 @@ func GetFollowersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
 
 	// Fetch followers if authorized
 	if isAuthorized {
-		log.Printf("Querying followers for user_id %d", userID)
 		rows, err := db.Query(`
             SELECT u.user_uuid, COALESCE(u.first_name, '') as first_name, COALESCE(u.last_name, '') as last_name
             FROM follows f
@@ -103,9 +88,8 @@ func GetFollowersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
             WHERE f.followed_user_id = ? AND f.status = 'accepted' AND u.status = 'active'
         `, userID)
 		if err != nil {
-			log.Printf("Followers query error for user_id %d: %v", userID, err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Failed to fetch followers"})
+			slog.ErrorContext(r.Context(), "Followers query failed", "user_id", userID, "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch followers")
 			return
 		}
 		defer rows.Close()
@@ -114,16 +98,15 @@ func GetFollowersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
 		for rows.Next() {
 			var follower dbTools.Follower
 			if err := rows.Scan(&follower.UserUUID, &follower.FirstName, &follower.LastName); err != nil {
-				log.Printf("Follower scan error for user_id %d: %v", userID, err)
+				slog.ErrorContext(r.Context(), "Follower scan failed", "user_id", userID, "err", err)
 				continue
 			}
 			response.Followers = append(response.Followers, follower)
 			followerCount++
-			log.Printf("Added follower: %s %s (%s)", follower.FirstName, follower.LastName, follower.UserUUID)
 		}
-		log.Printf("Found %d followers for user_id %d", followerCount, userID)
+		slog.DebugContext(r.Context(), "Listed followers", "user_id", userID, "count", followerCount)
 		if err = rows.Err(); err != nil {
-			log.Printf("Followers rows error for user_id %d: %v", userID, err)
+			slog.ErrorContext(r.Context(), "Followers rows error", "user_id", userID, "err", err)
 		}
 	}
 
@@ -133,24 +116,18 @@ func GetFollowersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
 
 // GetFollowingHandler fetches the list of users a user is following
 func GetFollowingHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	log.Printf("GetFollowingHandler called at %s for URL %s", time.Now().Format(time.RFC3339), r.URL.Path)
-	middleware.SetCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 	if r.Method != "GET" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Method not allowed"})
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	// Extract user_uuid from URL path
-	path := strings.TrimPrefix(r.URL.Path, "/api/following/")
-	userUUID := strings.Split(path, "/")[0]
+	userUUID := r.PathValue("uuid")
 	if userUUID == "" || userUUID == "me" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Missing user UUID"})
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Missing user UUID")
 		return
 	}
 
@@ -164,22 +141,19 @@ func GetFollowingHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
     `
 	err := db.QueryRow(query, userUUID).Scan(&userID, &privacy)
 	if err != nil {
-		log.Printf("User fetch error for user_uuid %s: %v", userUUID, err)
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "User not found"})
+		slog.DebugContext(r.Context(), "Following: user lookup failed", "user_uuid", userUUID, "err", err)
+		utils.SendErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
 
 	// Check authorization
 	isAuthorized := false
-	currentUserID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err == nil {
+	currentUserID := auth.UserID(r)
+	if currentUserID != 0 {
 		if int(currentUserID) == userID {
 			isAuthorized = true
-			log.Println("Authorized: Own profile")
 		} else if privacy == "public" {
 			isAuthorized = true
-			log.Println("Authorized: Public profile")
 		} else {
 			followerQuery := `
                 SELECT COUNT(*)
@@ -189,19 +163,16 @@ func GetFollowingHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
 			var followerCount int
 			err = db.QueryRow(followerQuery, currentUserID, userID).Scan(&followerCount)
 			if err != nil {
-				log.Printf("Follower query error: %v", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Follower check error"})
+				slog.ErrorContext(r.Context(), "Following: follow status query failed", "err", err)
+				utils.SendErrorResponse(w, http.StatusInternalServerError, "Follower check error")
 				return
 			}
 			if followerCount > 0 {
 				isAuthorized = true
-				log.Println("Authorized: Accepted follower")
 			}
 		}
 	} else if privacy == "public" {
 		isAuthorized = true
-		log.Println("Authorized: Public profile, no login")
 	}
 
 	// Prepare response
@@ -215,7 +186,6 @@ func GetFollowingHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
 
 	// Fetch following if authorized
 	if isAuthorized {
-		log.Printf("Querying following for user_id %d", userID)
 		rows, err := db.Query(`
             SELECT u.user_uuid, COALESCE(u.first_name, '') as first_name, COALESCE(u.last_name, '') as last_name
             FROM follows f
@@ -223,9 +193,8 @@ func GetFollowingHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
             WHERE f.follower_user_id = ? AND f.status = 'accepted' AND u.status = 'active'
         `, userID)
 		if err != nil {
-			log.Printf("Following query error for user_id %d: %v", userID, err)
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Failed to fetch following"})
+			slog.ErrorContext(r.Context(), "Following query failed", "user_id", userID, "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch following")
 			return
 		}
 		defer rows.Close()
@@ -234,16 +203,15 @@ func GetFollowingHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
 		for rows.Next() {
 			var following dbTools.Follower
 			if err := rows.Scan(&following.UserUUID, &following.FirstName, &following.LastName); err != nil {
-				log.Printf("Following scan error for user_id %d: %v", userID, err)
+				slog.ErrorContext(r.Context(), "Following scan failed", "user_id", userID, "err", err)
 				continue
 			}
 			response.Following = append(response.Following, following)
 			followingCount++
-			log.Printf("Added following: %s %s (%s)", following.FirstName, following.LastName, following.UserUUID)
 		}
-		log.Printf("Found %d following for user_id %d", followingCount, userID)
+		slog.DebugContext(r.Context(), "Listed following", "user_id", userID, "count", followingCount)
 		if err = rows.Err(); err != nil {
-			log.Printf("Following rows error for user_id %d: %v", userID, err)
+			slog.ErrorContext(r.Context(), "Following rows error", "user_id", userID, "err", err)
 		}
 	}
 
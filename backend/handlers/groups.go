@@ -3,148 +3,20 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
-	"log"
+	"log/slog"
 	"net/http"
+	"social_network/auth"
 	"social_network/dbTools"
 	"social_network/middleware"
 	"social_network/utils"
 	"strconv"
-	"strings"
 	"time"
 )
 
-// GroupsHandler handles requests related to groups
-func GroupsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	defer func() {
-		if rec := recover(); rec != nil {
-			log.Println("Panic recovered in GroupsHandler:", rec)
-			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		}
-	}()
-
-	middleware.SetCORSHeaders(w)
-
-	path := r.URL.Path
-	segments := strings.Split(strings.Trim(path, "/"), "/")
-
-	// Remove "api" from the path if it exists
-	if len(segments) > 0 && segments[0] == "api" {
-		segments = segments[1:]
-	}
-
-	// Route based on path pattern
-	switch {
-	case matchRoute(segments, "groups", "my-groups"):
-		handleMethodRoute(w, r, map[string]func(){
-			http.MethodGet: func() { getMyGroups(w, r, db) },
-		})
-
-	case matchRoute(segments, "groups"):
-		handleMethodRoute(w, r, map[string]func(){
-			http.MethodPost: func() { createGroup(w, r, db) },
-			http.MethodGet:  func() { getAllGroups(w, r, db) },
-		})
-
-	case matchRoute(segments, "groups", "*"):
-		groupID := parseGroupID(w, segments[1])
-		if groupID == -1 {
-			return
-		}
-		handleMethodRoute(w, r, map[string]func(){
-			http.MethodGet: func() { getGroupByID(w, db, groupID) },
-		})
-
-	case matchRoute(segments, "groups", "*", "invite"):
-		groupID := parseGroupID(w, segments[1])
-		if groupID == -1 {
-			return
-		}
-		handleMethodRoute(w, r, map[string]func(){
-			http.MethodPost: func() { inviteToGroup(w, r, db, groupID) },
-		})
-
-	case matchRoute(segments, "groups", "*", "request-join"):
-		groupID := parseGroupID(w, segments[1])
-		if groupID == -1 {
-			return
-		}
-		handleMethodRoute(w, r, map[string]func(){
-			http.MethodPost: func() { requestToJoinGroup(w, r, db, groupID) },
-		})
-
-	case matchRoute(segments, "groups", "*", "membership", "*"):
-		groupID := parseGroupID(w, segments[1])
-		userID := parseUserID(w, segments[3])
-		if groupID == -1 || userID == -1 {
-			return
-		}
-		handleMethodRoute(w, r, map[string]func(){
-			http.MethodPost: func() { updateMembershipStatus(w, r, db, groupID, userID) },
-		})
-
-	case matchRoute(segments, "groups", "*", "members"):
-		groupID := parseGroupID(w, segments[1])
-		if groupID == -1 {
-			return
-		}
-		handleMethodRoute(w, r, map[string]func(){
-			http.MethodGet: func() { getGroupMembers(w, db, groupID) },
-		})
-
-	case matchRoute(segments, "invitations"):
-		handleMethodRoute(w, r, map[string]func(){
-			http.MethodGet: func() { getInvitations(w, r, db) },
-		})
-
-	case matchRoute(segments, "groups", "*", "requests"):
-		groupID := parseGroupID(w, segments[1])
-		if groupID == -1 {
-			return
-		}
-		handleMethodRoute(w, r, map[string]func(){
-			http.MethodGet: func() { getJoinRequests(w, r, db, groupID) },
-		})
-
-	case matchRoute(segments, "groups", "*", "events"):
-		groupID := parseGroupID(w, segments[1])
-		if groupID == -1 {
-			return
-		}
-		handleMethodRoute(w, r, map[string]func(){
-			http.MethodPost: func() { createGroupEventInGroups(w, r, db, groupID) },
-			http.MethodGet:  func() { getGroupEventsInGroups(w, db, groupID) },
-		})
-
-	default:
-		http.Error(w, "Not found", http.StatusNotFound)
-	}
-}
-
-// Helper functions for cleaner routing
-func matchRoute(segments []string, pattern ...string) bool {
-	if len(segments) != len(pattern) {
-		return false
-	}
-	for i, part := range pattern {
-		if part != "*" && segments[i] != part {
-			return false
-		}
-	}
-	return true
-}
-
-func handleMethodRoute(w http.ResponseWriter, r *http.Request, handlers map[string]func()) {
-	if handler, exists := handlers[r.Method]; exists {
-		handler()
-	} else {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-	}
-}
-
 func parseGroupID(w http.ResponseWriter, idStr string) int {
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid group ID", http.StatusBadRequest)
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid group ID")
 		return -1
 	}
 	return id
@@ -153,21 +25,21 @@ func parseGroupID(w http.ResponseWriter, idStr string) int {
 func parseUserID(w http.ResponseWriter, idStr string) int {
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid user ID")
 		return -1
 	}
 	return id
 }
 
-func createGroup(w http.ResponseWriter, r *http.Request, db *dbTools.DB) {
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid session")
+func createGroup(w http.ResponseWriter, r *http.Request, db *dbTools.DB, maxFormBytes int64) {
+	userID := auth.UserID(r)
+	if userID == 0 {
+		middleware.WriteUnauthorized(w)
 		return
 	}
 
 	// Parse multipart form data for file upload support
-	if err = r.ParseMultipartForm(10 << 20); err != nil { // 10MB max
+	if err := r.ParseMultipartForm(maxFormBytes); err != nil {
 		utils.SendErrorResponse(w, http.StatusBadRequest, "Could not parse form")
 		return
 	}
@@ -175,8 +47,11 @@ func createGroup(w http.ResponseWriter, r *http.Request, db *dbTools.DB) {
 	// Extract and validate form values
 	title := r.FormValue("title")
 	description := r.FormValue("description")
-	if title == "" || description == "" {
-		utils.SendErrorResponse(w, http.StatusBadRequest, "Title and description are required")
+	var fields utils.FieldErrors
+	fields.Check("title", utils.ValidateRequired("Title", title))
+	fields.Check("description", utils.ValidateRequired("Description", description))
+	if err := fields.Err(); err != nil {
+		utils.SendError(w, err)
 		return
 	}
 
@@ -207,7 +82,7 @@ func handleAvatarUpload(r *http.Request, db *dbTools.DB, group *dbTools.Group, u
 		return // No file uploaded, which is fine
 	}
 	if err != nil {
-		log.Printf("Error getting avatar file: %v", err)
+		slog.ErrorContext(r.Context(), "Reading group image failed", "err", err)
 		return
 	}
 	defer file.Close()
@@ -221,14 +96,14 @@ func handleAvatarUpload(r *http.Request, db *dbTools.DB, group *dbTools.Group, u
 	}
 
 	if uploadErr := db.FileUpload(file, fileMeta, r, nil); uploadErr != nil {
-		log.Printf("Failed to upload group avatar: %v", uploadErr)
+		slog.ErrorContext(r.Context(), "Failed to upload group avatar", "err", uploadErr)
 	} else {
 		group.Avatar = "/uploads/" + fileMeta.FilenameNew
 	}
 }
 
 func getAllGroups(w http.ResponseWriter, r *http.Request, db *dbTools.DB) {
-	userID, _ := utils.GetUserIDFromSession(db.GetDB(), r)
+	userID := auth.UserID(r)
 
 	groups, err := db.GetAllGroups(int(userID))
 	if err != nil {
@@ -245,9 +120,9 @@ func getAllGroups(w http.ResponseWriter, r *http.Request, db *dbTools.DB) {
 }
 
 func getMyGroups(w http.ResponseWriter, r *http.Request, db *dbTools.DB) {
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid session")
+	userID := auth.UserID(r)
+	if userID == 0 {
+		middleware.WriteUnauthorized(w)
 		return
 	}
 
@@ -276,9 +151,9 @@ func getGroupByID(w http.ResponseWriter, db *dbTools.DB, groupID int) {
 }
 
 func inviteToGroup(w http.ResponseWriter, r *http.Request, db *dbTools.DB, groupID int) {
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid session")
+	userID := auth.UserID(r)
+	if userID == 0 {
+		middleware.WriteUnauthorized(w)
 		return
 	}
 
@@ -295,7 +170,7 @@ func inviteToGroup(w http.ResponseWriter, r *http.Request, db *dbTools.DB, group
 		return
 	}
 
-	if err = db.InviteToGroup(groupID, int(userID), invite.InviteeID); err != nil {
+	if err := db.InviteToGroup(groupID, int(userID), invite.InviteeID); err != nil {
 		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to send invitation")
 		return
 	}
@@ -307,9 +182,9 @@ func inviteToGroup(w http.ResponseWriter, r *http.Request, db *dbTools.DB, group
 }
 
 func requestToJoinGroup(w http.ResponseWriter, r *http.Request, db *dbTools.DB, groupID int) {
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid session")
+	userID := auth.UserID(r)
+	if userID == 0 {
+		middleware.WriteUnauthorized(w)
 		return
 	}
 
@@ -336,9 +211,9 @@ func requestToJoinGroup(w http.ResponseWriter, r *http.Request, db *dbTools.DB,
 }
 
 func updateMembershipStatus(w http.ResponseWriter, r *http.Request, db *dbTools.DB, groupID, targetUserID int) {
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid session")
+	userID := auth.UserID(r)
+	if userID == 0 {
+		middleware.WriteUnauthorized(w)
 		return
 	}
 
@@ -356,7 +231,7 @@ func updateMembershipStatus(w http.ResponseWriter, r *http.Request, db *dbTools.
 	}
 
 	// Check permissions and update accordingly
-	if err = handleMembershipUpdate(db, groupID, int(userID), targetUserID, request.Status); err != nil {
+	if err := handleMembershipUpdate(r, db, groupID, targetUserID, request.Status); err != nil {
 		utils.SendErrorResponse(w, http.StatusForbidden, "Forbidden")
 		return
 	}
@@ -375,9 +250,9 @@ func getGroupMembers(w http.ResponseWriter, db *dbTools.DB, groupID int) {
 }
 
 func getInvitations(w http.ResponseWriter, r *http.Request, db *dbTools.DB) {
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid session")
+	userID := auth.UserID(r)
+	if userID == 0 {
+		middleware.WriteUnauthorized(w)
 		return
 	}
 
@@ -391,14 +266,13 @@ func getInvitations(w http.ResponseWriter, r *http.Request, db *dbTools.DB) {
 }
 
 func getJoinRequests(w http.ResponseWriter, r *http.Request, db *dbTools.DB, groupID int) {
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid session")
+	userID := auth.UserID(r)
+	if userID == 0 {
+		middleware.WriteUnauthorized(w)
 		return
 	}
 
-	// Check creator permission
-	if !checkGroupCreator(w, db, groupID, int(userID), "Only group creator can view requests") {
+	if !checkGroupPermission(w, r, db, groupID, auth.PermManageGroup, "Only the group creator or its moderators can view requests") {
 		return
 	}
 
@@ -412,9 +286,9 @@ func getJoinRequests(w http.ResponseWriter, r *http.Request, db *dbTools.DB, gro
 }
 
 func createGroupEventInGroups(w http.ResponseWriter, r *http.Request, db *dbTools.DB, groupID int) {
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid session")
+	userID := auth.UserID(r)
+	if userID == 0 {
+		middleware.WriteUnauthorized(w)
 		return
 	}
 
@@ -472,30 +346,51 @@ func checkGroupMembership(w http.ResponseWriter, db *dbTools.DB, groupID, userID
 	return true
 }
 
-func checkGroupCreator(w http.ResponseWriter, db *dbTools.DB, groupID, userID int, errorMsg string) bool {
-	isCreator, err := db.IsGroupCreator(groupID, userID)
+func checkGroupPermission(w http.ResponseWriter, r *http.Request, db *dbTools.DB, groupID int, perm auth.Permission, errorMsg string) bool {
+	principal, _ := auth.FromRequest(r)
+	res, err := groupResource(db, groupID, principal)
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Group permission check failed", "group_id", groupID, "err", err)
 		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to check permissions")
 		return false
 	}
-	if !isCreator {
+	if !auth.Can(principal, perm, res) {
 		utils.SendErrorResponse(w, http.StatusForbidden, errorMsg)
 		return false
 	}
 	return true
 }
 
-func handleMembershipUpdate(db *dbTools.DB, groupID, userID, targetUserID int, status string) error {
-	isCreator, err := db.IsGroupCreator(groupID, userID)
-	if err != nil {
-		return err
+// groupResource describes a group for permission checks by the principal
+func groupResource(db *dbTools.DB, groupID int, principal *auth.Principal) (auth.Resource, error) {
+	var res auth.Resource
+	group, err := db.GetGroupByID(groupID)
+	if err != nil || group == nil {
+		return res, err
 	}
-
-	if isCreator || userID == targetUserID {
-		return db.UpdateMembershipStatus(groupID, targetUserID, status)
+	res.GroupCreatorID = group.CreatorID
+	if principal != nil && principal.Role == auth.RoleGroupModerator {
+		res.GroupModerator, err = db.IsGroupModerator(groupID, principal.ID)
 	}
+	return res, err
+}
 
-	return errors.New("Forbidden")
+func handleMembershipUpdate(r *http.Request, db *dbTools.DB, groupID, targetUserID int, status string) error {
+	principal, _ := auth.FromRequest(r)
+	if principal == nil {
+		return errors.New("Forbidden")
+	}
+	// Members answer their own invitations; group managers decide on join requests
+	if principal.ID != targetUserID {
+		res, err := groupResource(db, groupID, principal)
+		if err != nil {
+			return err
+		}
+		if !auth.Can(principal, auth.PermManageGroup, res) {
+			return errors.New("Forbidden")
+		}
+	}
+	return db.UpdateMembershipStatus(groupID, targetUserID, status)
 }
 
 // Notification helper functions
@@ -503,7 +398,7 @@ func createGroupInviteNotification(db *dbTools.DB, inviterID, inviteeID, groupID
 	if group, err := db.GetGroupByID(groupID); err == nil {
 		notificationHelpers := dbTools.NewNotificationHelpers(db)
 		if err := notificationHelpers.CreateGroupInvitationNotification(inviterID, inviteeID, groupID, group.Title); err != nil {
-			log.Printf("Failed to create notification for group invitation: %v", err)
+			slog.ErrorContext(db.Context(), "Failed to create notification for group invitation", "err", err)
 		}
 	}
 }
@@ -511,7 +406,7 @@ func createGroupInviteNotification(db *dbTools.DB, inviterID, inviteeID, groupID
 func createGroupJoinRequestNotification(db *dbTools.DB, requesterID, creatorID, groupID int, groupTitle string) {
 	notificationHelpers := dbTools.NewNotificationHelpers(db)
 	if err := notificationHelpers.CreateGroupJoinRequestNotification(requesterID, creatorID, groupID, groupTitle); err != nil {
-		log.Printf("Failed to create notification: %v", err)
+		slog.ErrorContext(db.Context(), "Failed to create notification", "err", err)
 	}
 }
 
@@ -519,7 +414,7 @@ func createGroupEventNotification(db *dbTools.DB, creatorID, groupID, eventID in
 	if group, err := db.GetGroupByID(groupID); err == nil {
 		notificationHelpers := dbTools.NewNotificationHelpers(db)
 		if err := notificationHelpers.CreateGroupEventNotification(creatorID, groupID, eventID, group.Title, eventTitle); err != nil {
-			log.Printf("Failed to create event notifications: %v", err)
+			slog.ErrorContext(db.Context(), "Failed to create event notifications", "err", err)
 		}
 	}
 }
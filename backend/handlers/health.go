@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"social_network/dbTools"
+	"time"
+)
+
+// readinessTimeout bounds the database ping, so a hung database fails the
+// check instead of the orchestrator's probe timing out
+const readinessTimeout = 2 * time.Second
+
+// healthCheck is the result of one readiness check. Error is kept short
+// because the endpoint is public; the details are logged.
+type healthCheck struct {
+	Status   string                   `json:"status"` // "ok" or "fail"
+	Error    string                   `json:"error,omitempty"`
+	Versions *dbTools.MigrationStatus `json:"versions,omitempty"`
+}
+
+// HealthzHandler reports that the process is up and serving requests. It
+// checks nothing else, so a slow database never gets the server restarted.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok"})
+}
+
+// ReadyzHandler reports whether the server can handle traffic: the database
+// answers, its schema is at the newest migration and uploads can be stored.
+// It answers 503 when any check fails.
+func ReadyzHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	checks := map[string]healthCheck{
+		"database":   checkDatabase(r.Context(), db),
+		"migrations": checkMigrations(r.Context(), db),
+		"uploads":    checkUploads(r.Context(), db),
+	}
+
+	status, code := "ready", http.StatusOK
+	for _, check := range checks {
+		if check.Status != "ok" {
+			status, code = "not_ready", http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "checks": checks})
+}
+
+func checkDatabase(ctx context.Context, db *dbTools.DB) healthCheck {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	if err := db.Ping(ctx); err != nil {
+		slog.WarnContext(ctx, "Readiness: database ping failed", "err", err)
+		return healthCheck{Status: "fail", Error: "database unreachable"}
+	}
+	return healthCheck{Status: "ok"}
+}
+
+func checkMigrations(ctx context.Context, db *dbTools.DB) healthCheck {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	versions, err := db.MigrationStatus(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "Readiness: reading migration status failed", "err", err)
+		return healthCheck{Status: "fail", Error: "migration status unavailable"}
+	}
+	if versions.Dirty {
+		return healthCheck{Status: "fail", Error: "last migration failed", Versions: &versions}
+	}
+	if !versions.UpToDate() {
+		return healthCheck{Status: "fail", Error: "schema is not at the expected version", Versions: &versions}
+	}
+	return healthCheck{Status: "ok", Versions: &versions}
+}
+
+func checkUploads(ctx context.Context, db *dbTools.DB) healthCheck {
+	if err := db.CheckUploadsWritable(); err != nil {
+		slog.WarnContext(ctx, "Readiness: uploads directory not writable", "dir", db.UploadsDir(), "err", err)
+		return healthCheck{Status: "fail", Error: "uploads directory not writable"}
+	}
+	return healthCheck{Status: "ok"}
+}
@@ -2,15 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"social_network/utils"
 	"strconv"
 	"strings"
 	"time"
 
+	"social_network/auth"
 	"social_network/dbTools"
-	"social_network/utils"
 )
 
 type messageResponse struct {
@@ -30,40 +30,30 @@ type messageResponse struct {
 }
 
 func MessageHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
-	}
+	userID := auth.UserID(r)
 
-	parts := strings.Split(r.URL.Path, "/")
-	if len(parts) < 3 {
-		http.Error(w, "invalid path", http.StatusBadRequest)
-		return
-	}
-	chatSpecifications := parts[len(parts)-1]
+	chatSpecifications := r.PathValue("chat")
 
 	specificationParts := strings.SplitN(chatSpecifications, "_", 2)
 	if len(specificationParts) != 2 {
-		fmt.Println("error 00")
-		http.Error(w, "invalid chat specification:", http.StatusBadRequest)
+		utils.SendErrorResponse(w, http.StatusBadRequest, "invalid chat specification:")
 		return
 	}
 	chatType, otherUUID := specificationParts[0], specificationParts[1]
 	var otherUser *dbTools.User
 	var otherID int
+	var err error
 	if chatType == "private" {
 		otherUser, err = db.FetchUserByUUID(otherUUID)
 		if err != nil {
-			fmt.Println("error 01")
-			http.Error(w, "invalid ID/UUID:", http.StatusBadRequest)
+			utils.SendErrorResponse(w, http.StatusBadRequest, "invalid ID/UUID:")
 			return
 		}
 		otherID = otherUser.UserID
 	} else {
 		otherID, err = strconv.Atoi(otherUUID)
 		if err != nil {
-			log.Println("atoi err:", err)
+			slog.WarnContext(r.Context(), "Messages: invalid group ID", "chat", chatSpecifications, "err", err)
 		}
 	}
 
@@ -74,11 +64,12 @@ func MessageHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 	case "group":
 		rawMsgs, err = db.GetMessagesForGroup(otherID)
 	default:
-		http.Error(w, "unknown chat type", http.StatusBadRequest)
+		utils.SendErrorResponse(w, http.StatusBadRequest, "unknown chat type")
 		return
 	}
 	if err != nil {
-		http.Error(w, "could not load messages", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Messages: loading chat failed", "chat", chatSpecifications, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "could not load messages")
 		return
 	}
 
@@ -90,14 +81,14 @@ func MessageHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		// Always fetch the sender information
 		msgSenderUser, err = db.FetchUserByID(msg.SenderID)
 		if err != nil {
-			log.Println("Error fetching sender when fetching messages:", err)
+			slog.ErrorContext(r.Context(), "Messages: fetching sender failed", "err", err)
 			continue
 		}
 
 		if chatType == "private" {
 			msgOtherUser, err = db.FetchUserByID(msg.ReceiverID)
 			if err != nil {
-				log.Println("Error fetching receiver when fetching messages:", err)
+				slog.ErrorContext(r.Context(), "Messages: fetching receiver failed", "err", err)
 				continue
 			}
 		}
@@ -128,9 +119,8 @@ func MessageHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	fmt.Println("resp to messages:", resp)
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		fmt.Println("JSON encode error:", err)
+		slog.ErrorContext(r.Context(), "Messages: JSON encode failed", "err", err)
 	}
 }
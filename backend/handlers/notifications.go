@@ -1,52 +1,18 @@
 package handlers
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"social_network/dbTools"
-	"social_network/middleware"
 	"social_network/utils"
 	"strconv"
-	"strings"
-	"time"
 )
 
-// NotificationHandler manages user notifications with cleaner, service-based architecture
-func NotificationHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	log.Printf("NotificationHandler called at %s for URL %s", time.Now().Format(time.RFC3339), r.URL.Path)
-	middleware.SetCORSHeaders(w)
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
-	}
-
-	currentUserID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		log.Printf("Session check error: %v", err)
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid session")
-		return
-	}
-
-	// Initialize notification service
-	notificationService := dbTools.NewNotificationService(db)
-
-	switch r.Method {
-	case "GET":
-		handleGetNotifications(w, notificationService, currentUserID)
-	case "POST":
-		handleMarkAsRead(w, r, notificationService, currentUserID)
-	case "DELETE":
-		handleClearNotifications(w, notificationService, currentUserID)
-	default:
-		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
-	}
-}
-
 // handleGetNotifications retrieves all notifications for the current user
 func handleGetNotifications(w http.ResponseWriter, service *dbTools.NotificationService, userID int) {
 	notifications, err := service.GetNotificationsByUserID(userID)
 	if err != nil {
-		log.Printf("Notifications query error: %v", err)
+		slog.ErrorContext(service.Context(), "Notifications query failed", "user_id", userID, "err", err)
 		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch notifications")
 		return
 	}
@@ -77,8 +43,7 @@ func handleGetNotifications(w http.ResponseWriter, service *dbTools.Notification
 
 // handleMarkAsRead marks a specific notification as read
 func handleMarkAsRead(w http.ResponseWriter, r *http.Request, service *dbTools.NotificationService, userID int) {
-	path := strings.TrimPrefix(r.URL.Path, "/api/notifications/")
-	notificationIDStr := strings.TrimSpace(path)
+	notificationIDStr := r.PathValue("id")
 	if notificationIDStr == "" {
 		utils.SendErrorResponse(w, http.StatusBadRequest, "Missing notification ID")
 		return
@@ -92,7 +57,7 @@ func handleMarkAsRead(w http.ResponseWriter, r *http.Request, service *dbTools.N
 
 	err = service.MarkAsRead(notificationID, userID)
 	if err != nil {
-		log.Printf("Mark as read error: %v", err)
+		slog.ErrorContext(r.Context(), "Mark as read failed", "err", err)
 		if err.Error() == "notification not found or already read" {
 			utils.SendErrorResponse(w, http.StatusNotFound, err.Error())
 		} else {
@@ -110,7 +75,7 @@ func handleMarkAsRead(w http.ResponseWriter, r *http.Request, service *dbTools.N
 func handleClearNotifications(w http.ResponseWriter, service *dbTools.NotificationService, userID int) {
 	err := service.ClearReadNotifications(userID)
 	if err != nil {
-		log.Printf("Clear notifications error: %v", err)
+		slog.ErrorContext(service.Context(), "Clear notifications failed", "user_id", userID, "err", err)
 		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to clear notifications")
 		return
 	}
@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"path/filepath"
+	"social_network/auth"
+	"social_network/dbTools"
+	"social_network/middleware"
+	"social_network/oidc"
+	"social_network/utils"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// oidcStateTTL is how long the user has to finish signing in at the provider
+	oidcStateTTL = 10 * time.Minute
+	// oidcStateCookieName binds the callback to the browser that started the flow
+	oidcStateCookieName = "oidc_state"
+	maxRemoteAvatarSize = 5 << 20
+)
+
+func listOIDCProviders(w http.ResponseWriter, providers *oidc.Registry) {
+	list := []map[string]string{}
+	for _, p := range providers.Providers() {
+		list = append(list, map[string]string{
+			"name":         p.Config.Name,
+			"display_name": p.Config.DisplayName,
+			"login_url":    "/api/v1/auth/oidc/" + p.Config.Name + "/start",
+		})
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{"providers": list})
+}
+
+// startOIDCFlow stores the flow state server-side and redirects to the provider.
+// linkUserID is the current user when linking, 0 when signing in.
+func startOIDCFlow(w http.ResponseWriter, r *http.Request, db *dbTools.DB, provider *oidc.Provider, linkUserID int) {
+	state, err := oidc.NewState()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "OIDC state generation failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+	nonce, err := oidc.NewState()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "OIDC nonce generation failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+	verifier, err := oidc.NewCodeVerifier()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "OIDC verifier generation failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(r.Context(), state, nonce, verifier)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "OIDC provider unavailable", "provider", provider.Config.Name, "err", err)
+		utils.SendErrorResponse(w, http.StatusBadGateway, "Login provider is unavailable")
+		return
+	}
+
+	err = db.CreateOIDCLoginState(utils.HashToken(state), dbTools.OIDCLoginState{
+		Provider:     provider.Config.Name,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		RedirectPath: safeRedirectPath(r.URL.Query().Get("redirect")),
+	}, time.Now().Add(oidcStateTTL))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "OIDC state store failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to start login")
+		return
+	}
+
+	// Lax, not Strict: the callback is a cross-site redirect from the provider.
+	// The path covers both the versioned and the unversioned callback.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    state,
+		Path:     "/api/",
+		Expires:  time.Now().Add(oidcStateTTL),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oidcCallback finishes the authorization code flow. Errors are reported by
+// redirecting to the frontend login page with an error code, since the
+// browser arrives here through a redirect.
+func oidcCallback(w http.ResponseWriter, r *http.Request, db *dbTools.DB, provider *oidc.Provider, acc Accounts) {
+	query := r.URL.Query()
+	http.SetCookie(w, &http.Cookie{Name: oidcStateCookieName, Path: "/api/", MaxAge: -1, HttpOnly: true})
+
+	if query.Get("error") != "" {
+		slog.WarnContext(r.Context(), "OIDC provider returned an error", "provider", provider.Config.Name, "error", query.Get("error"), "description", query.Get("error_description"))
+		redirectToApp(w, r, acc.AppURL, "/login", url.Values{"error": {"oidc_denied"}})
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := r.Cookie(oidcStateCookieName)
+	if state == "" || err != nil || cookie.Value != state {
+		redirectToApp(w, r, acc.AppURL, "/login", url.Values{"error": {"oidc_state"}})
+		return
+	}
+	flow, err := db.ConsumeOIDCLoginState(utils.HashToken(state), provider.Config.Name)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			slog.ErrorContext(r.Context(), "OIDC state lookup failed", "err", err)
+		}
+		redirectToApp(w, r, acc.AppURL, "/login", url.Values{"error": {"oidc_state"}})
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), query.Get("code"), flow.CodeVerifier, flow.Nonce)
+	if err != nil {
+		slog.WarnContext(r.Context(), "OIDC code exchange failed", "provider", provider.Config.Name, "err", err)
+		redirectToApp(w, r, acc.AppURL, "/login", url.Values{"error": {"oidc_failed"}})
+		return
+	}
+
+	if flow.LinkUserID > 0 {
+		linkOIDCIdentity(w, r, db, acc.AppURL, provider, flow, claims)
+		return
+	}
+
+	userID, err := resolveOIDCUser(db, acc, provider, claims)
+	if err != nil {
+		var loginErr oidcLoginError
+		if errors.As(err, &loginErr) {
+			redirectToApp(w, r, acc.AppURL, "/login", url.Values{"error": {string(loginErr)}})
+			return
+		}
+		slog.ErrorContext(r.Context(), "OIDC user resolution failed", "provider", provider.Config.Name, "err", err)
+		redirectToApp(w, r, acc.AppURL, "/login", url.Values{"error": {"oidc_failed"}})
+		return
+	}
+	if err := db.TouchIdentity(provider.Config.Name, claims.Subject); err != nil {
+		slog.ErrorContext(r.Context(), "OIDC identity touch failed", "err", err)
+	}
+
+	finishOIDCLogin(w, r, db, acc.AppURL, userID, flow.RedirectPath)
+}
+
+// oidcLoginError is a login failure that is shown to the user as an error code
+type oidcLoginError string
+
+func (e oidcLoginError) Error() string { return string(e) }
+
+// resolveOIDCUser finds or creates the account for an external identity:
+// an already linked account, then a verified account with the same
+// provider-verified email (which gets linked), and finally a new account
+// built from the claims.
+func resolveOIDCUser(db *dbTools.DB, acc Accounts, provider *oidc.Provider, claims *oidc.Claims) (int, error) {
+	name := provider.Config.Name
+
+	userID, err := db.GetUserIDByIdentity(name, claims.Subject)
+	if err == nil {
+		return userID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	if claims.Email == "" || utils.ValidateEmail(claims.Email) != nil {
+		return 0, oidcLoginError("oidc_no_email")
+	}
+
+	existingID, status, err := db.GetUserIDByEmail(claims.Email)
+	switch {
+	case err == nil && !bool(claims.EmailVerified):
+		// Without a verified email anyone could claim the address at the provider
+		return 0, oidcLoginError("account_exists")
+	case err == nil && status == "pending_verification":
+		// Whoever registered the unverified account may not own the address, and
+		// linking would hand the provider's user an account with their password
+		return 0, oidcLoginError("account_exists")
+	case err == nil:
+		if err := db.LinkIdentity(existingID, name, claims.Subject, claims.Email, false); err != nil {
+			if errors.Is(err, dbTools.ErrIdentityLinked) {
+				return 0, oidcLoginError("account_exists")
+			}
+			return 0, err
+		}
+		slog.InfoContext(db.Context(), "Linked identity to existing user by verified email", "provider", name, "user_id", existingID)
+		return existingID, nil
+	case err != sql.ErrNoRows:
+		return 0, err
+	}
+
+	return createOIDCUser(db, acc, provider, claims)
+}
+
+// createOIDCUser creates an account from the ID token claims
+func createOIDCUser(db *dbTools.DB, acc Accounts, provider *oidc.Provider, claims *oidc.Claims) (int, error) {
+	userUUID, err := utils.GenerateUUID()
+	if err != nil {
+		return 0, err
+	}
+	// The account has no usable password until the user sets one through the reset flow
+	secret, err := utils.GenerateToken(32)
+	if err != nil {
+		return 0, err
+	}
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return 0, err
+	}
+
+	firstName, lastName := claims.GivenName, claims.FamilyName
+	if firstName == "" {
+		firstName, lastName, _ = strings.Cut(strings.TrimSpace(claims.Name), " ")
+	}
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(claims.Email, "@")
+	}
+	// date_of_birth is required; the zero date marks it as unknown when the provider does not share it
+	dob, _ := time.Parse("2006-01-02", claims.Birthdate)
+
+	user := dbTools.User{
+		UserUUID:    userUUID,
+		Email:       claims.Email,
+		FirstName:   utils.Sanitize(firstName),
+		LastName:    utils.Sanitize(lastName),
+		DateOfBirth: dob,
+		Avatar:      downloadAvatar(db.Context(), claims.Picture, db.UploadsDir()),
+	}
+	userID, err := db.CreateOIDCUser(&user, string(passwordHash), provider.Config.Name, claims.Subject, bool(claims.EmailVerified))
+	if err != nil {
+		return 0, err
+	}
+	user.UserID = userID
+	slog.InfoContext(db.Context(), "Created user from OIDC login", "user_id", userID, "provider", provider.Config.Name)
+
+	if user.Status == "pending_verification" {
+		if _, err := sendVerificationEmail(db, acc, user); err != nil {
+			slog.ErrorContext(db.Context(), "Verification email failed", "user_id", userID, "err", err)
+		}
+	}
+	return userID, nil
+}
+
+// finishOIDCLogin creates the session, or hands over to the 2FA step, and returns to the frontend
+func finishOIDCLogin(w http.ResponseWriter, r *http.Request, db *dbTools.DB, appURL string, userID int, redirectPath string) {
+	user, _, err := fetchLoginUser(db, "user_id", userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "OIDC login user lookup failed", "user_id", userID, "err", err)
+		redirectToApp(w, r, appURL, "/login", url.Values{"error": {"oidc_failed"}})
+		return
+	}
+
+	// The provider only replaces the password; a second factor is still required
+	twoFactor, err := db.IsTOTPEnabled(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "2FA status lookup failed", "user_id", userID, "err", err)
+		redirectToApp(w, r, appURL, "/login", url.Values{"error": {"oidc_failed"}})
+		return
+	}
+	if twoFactor {
+		token, err := newPending2FALogin(db, userID)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Pending 2FA login failed", "user_id", userID, "err", err)
+			redirectToApp(w, r, appURL, "/login", url.Values{"error": {"oidc_failed"}})
+			return
+		}
+		setPending2FACookie(w, token)
+		redirectToApp(w, r, appURL, "/login", url.Values{"two_factor": {"required"}, "redirect": {redirectPath}})
+		return
+	}
+
+	_, err = utils.CreateSession(db.GetDB(), db.Sessions(), w, r, int64(userID))
+	if errors.Is(err, utils.ErrAccountLocked) {
+		utils.RecordLoginAttempt(db.GetDB(), r, user.Email, userID, false, utils.LoginFailureLocked)
+		redirectToApp(w, r, appURL, "/login", url.Values{"error": {"account_locked"}})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Session creation failed", "user_id", userID, "err", err)
+		redirectToApp(w, r, appURL, "/login", url.Values{"error": {"oidc_failed"}})
+		return
+	}
+	utils.RecordLoginAttempt(db.GetDB(), r, user.Email, userID, true, "")
+
+	reactivated, err := reactivateOnLogin(db, &user)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Reactivation failed", "user_id", userID, "err", err)
+		redirectToApp(w, r, appURL, "/login", url.Values{"error": {"oidc_failed"}})
+		return
+	}
+	var params url.Values
+	if reactivated {
+		params = url.Values{"reactivated": {"true"}}
+	}
+	redirectToApp(w, r, appURL, redirectPath, params)
+}
+
+// linkOIDCIdentity attaches the identity to the account that started the link flow
+func linkOIDCIdentity(w http.ResponseWriter, r *http.Request, db *dbTools.DB, appURL string, provider *oidc.Provider, flow *dbTools.OIDCLoginState, claims *oidc.Claims) {
+	// The session cookie is SameSite=Strict, so the provider's cross-site
+	// redirect arrives without it. The user who started linking is known from
+	// the server-side state, which the Lax state cookie ties to this browser;
+	// the account only has to be still open.
+	userID := flow.LinkUserID
+	status, err := db.GetUserStatus(userID)
+	if err != nil && err != sql.ErrNoRows {
+		slog.ErrorContext(r.Context(), "OIDC link user lookup failed", "user_id", userID, "err", err)
+		redirectToApp(w, r, appURL, flow.RedirectPath, url.Values{"error": {"oidc_failed"}})
+		return
+	}
+	if status != "active" && status != "pending_verification" {
+		redirectToApp(w, r, appURL, "/login", url.Values{"error": {"oidc_state"}})
+		return
+	}
+
+	err = db.LinkIdentity(userID, provider.Config.Name, claims.Subject, claims.Email, bool(claims.EmailVerified))
+	if errors.Is(err, dbTools.ErrIdentityLinked) {
+		redirectToApp(w, r, appURL, flow.RedirectPath, url.Values{"error": {"identity_linked"}})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "OIDC link failed", "user_id", userID, "err", err)
+		redirectToApp(w, r, appURL, flow.RedirectPath, url.Values{"error": {"oidc_failed"}})
+		return
+	}
+
+	redirectToApp(w, r, appURL, flow.RedirectPath, url.Values{"linked": {provider.Config.Name}})
+}
+
+func listIdentities(w http.ResponseWriter, r *http.Request, db *dbTools.DB) {
+	userID := auth.UserID(r)
+	if userID == 0 {
+		middleware.WriteUnauthorized(w)
+		return
+	}
+
+	identities, err := db.GetIdentities(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Identities fetch failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch linked accounts")
+		return
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{"identities": identities})
+}
+
+func unlinkIdentity(w http.ResponseWriter, r *http.Request, db *dbTools.DB, identityID int) {
+	userID := auth.UserID(r)
+	if userID == 0 {
+		middleware.WriteUnauthorized(w)
+		return
+	}
+
+	found, err := db.UnlinkIdentity(userID, identityID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Identity unlink failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to unlink account")
+		return
+	}
+	if !found {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Linked account not found")
+		return
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Account unlinked"})
+}
+
+// safeRedirectPath only allows local paths, so the login flow cannot be used as an open redirect
+func safeRedirectPath(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/"
+	}
+	return path
+}
+
+// redirectToApp sends the browser to a page of the frontend at appURL
+func redirectToApp(w http.ResponseWriter, r *http.Request, appURL, path string, params url.Values) {
+	target := appURL + safeRedirectPath(path)
+	if len(params) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + params.Encode()
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// avatarClient fetches profile pictures. It only connects to public
+// addresses, whatever the URL or a redirect resolves to, so a provider's
+// claims cannot reach the server's own network.
+var avatarClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip, err := netip.ParseAddr(host)
+				if err != nil || !publicAddr(ip) {
+					return fmt.Errorf("avatar host %s is not public", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if req.URL.Scheme != "https" || len(via) >= 3 {
+			return errors.New("avatar redirect refused")
+		}
+		return nil
+	},
+}
+
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback() && !ip.IsLinkLocalUnicast()
+}
+
+// downloadAvatar stores the provider's profile picture in uploadDir.
+// It falls back to the default avatar if the picture cannot be fetched, is
+// not served over https from a public address, or is not a small image.
+func downloadAvatar(ctx context.Context, pictureURL, uploadDir string) string {
+	const defaultAvatar = "/uploads/default_avatar.jpg"
+	u, err := url.Parse(pictureURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return defaultAvatar
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return defaultAvatar
+	}
+	resp, err := avatarClient.Do(req)
+	if err != nil {
+		slog.WarnContext(ctx, "Avatar download failed", "err", err)
+		return defaultAvatar
+	}
+	defer resp.Body.Close()
+
+	extensions := map[string]string{"image/jpeg": ".jpg", "image/png": ".png", "image/gif": ".gif"}
+	contentType := strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	ext, ok := extensions[contentType]
+	if resp.StatusCode != http.StatusOK || !ok || resp.ContentLength > maxRemoteAvatarSize {
+		return defaultAvatar
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteAvatarSize+1))
+	if err != nil || len(data) > maxRemoteAvatarSize {
+		return defaultAvatar
+	}
+	// The bytes must be the image the header claims
+	if http.DetectContentType(data) != contentType {
+		return defaultAvatar
+	}
+
+	avatarUUID, err := utils.GenerateUUID()
+	if err != nil {
+		return defaultAvatar
+	}
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		slog.ErrorContext(ctx, "Failed to create upload directory", "dir", uploadDir, "err", err)
+		return defaultAvatar
+	}
+	filename := avatarUUID + ext
+	if err := os.WriteFile(filepath.Join(uploadDir, filename), data, 0644); err != nil {
+		slog.ErrorContext(ctx, "Avatar save failed", "err", err)
+		return defaultAvatar
+	}
+	dbTools.RecordUpload("avatar", int64(len(data)))
+	return fmt.Sprintf("/uploads/%s", filename)
+}
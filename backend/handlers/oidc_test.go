@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"social_network/config"
+	"social_network/dbTools"
+	"social_network/oidc"
+	"social_network/oidc/oidctest"
+	"testing"
+)
+
+// openTestDB returns a migrated database in a temporary directory
+func openTestDB(t *testing.T) *dbTools.DB {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Database.Path = filepath.Join(t.TempDir(), "test.db")
+	cfg.Database.MigrationsDir = filepath.Join("..", "db", "migrations")
+	cfg.Uploads.Dir = t.TempDir()
+
+	db := &dbTools.DB{}
+	if _, err := db.OpenDB(cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.CloseDB() })
+	return db
+}
+
+// insertTestUser adds an active user and returns their ID
+func insertTestUser(t *testing.T, db *dbTools.DB, name string) int {
+	t.Helper()
+	result, err := db.Exec(`
+        INSERT INTO users (user_uuid, email, password, first_name, last_name, date_of_birth, avatar, status, updated_at)
+        VALUES (?, ?, 'x', ?, 'Test', '2000-01-01', '', 'active', CURRENT_TIMESTAMP)
+    `, name+"-uuid", name+"@example.com", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
+
+// newTestProvider starts a mock identity provider and returns a provider configured for it
+func newTestProvider(t *testing.T) *oidc.Provider {
+	t.Helper()
+	idp, err := oidctest.NewServer("", "social-network", "dev-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(idp.Handler())
+	t.Cleanup(srv.Close)
+	idp.Issuer = srv.URL
+
+	return oidc.NewProvider(oidc.ProviderConfig{
+		Name:         "mock",
+		Issuer:       srv.URL,
+		ClientID:     "social-network",
+		ClientSecret: "dev-secret",
+		RedirectURL:  "http://backend.test/api/v1/auth/oidc/mock/callback",
+		Scopes:       []string{"openid", "email", "profile"},
+	})
+}
+
+// signInAtProvider follows the redirect of startOIDCFlow to the mock provider,
+// signs in there and returns the query the provider sends the browser back with
+func signInAtProvider(t *testing.T, authURL, subject string) url.Values {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := u.Query()
+	form.Set("sub", subject)
+	form.Set("email", subject+"@provider.example")
+	form.Set("email_verified", "true")
+	u.RawQuery = ""
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.PostForm(u.String(), form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := resp.Location()
+	if err != nil {
+		t.Fatalf("no redirect back from the provider (status %d): %v", resp.StatusCode, err)
+	}
+	return callback.Query()
+}
+
+// TestLinkCallbackWithoutSessionCookie runs a link flow the way browsers do:
+// the provider's cross-site redirect to the callback carries the Lax state
+// cookie but not the SameSite=Strict session cookie
+func TestLinkCallbackWithoutSessionCookie(t *testing.T) {
+	tests := []struct {
+		name string
+		// closeAccount deactivates the account while the user is at the provider
+		closeAccount bool
+		wantLocation string
+		wantLinked   int
+	}{
+		{name: "open account", wantLocation: "http://app.test/settings?linked=mock", wantLinked: 1},
+		{name: "account deactivated meanwhile", closeAccount: true, wantLocation: "http://app.test/login?error=oidc_state"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			provider := newTestProvider(t)
+			acc := Accounts{AppURL: "http://app.test"}
+			userID := insertTestUser(t, db, "alice")
+
+			// The logged-in user starts linking
+			w := httptest.NewRecorder()
+			startOIDCFlow(w, httptest.NewRequest("GET", "/api/v1/auth/oidc/mock/link?redirect=/settings", nil), db, provider, userID)
+			if w.Code != http.StatusFound {
+				t.Fatalf("start: status %d, want 302", w.Code)
+			}
+			var stateCookie *http.Cookie
+			for _, c := range w.Result().Cookies() {
+				if c.Name == oidcStateCookieName {
+					stateCookie = c
+				}
+			}
+			if stateCookie == nil {
+				t.Fatal("start did not set the state cookie")
+			}
+
+			back := signInAtProvider(t, w.Header().Get("Location"), "alice-at-provider")
+			if tt.closeAccount {
+				if _, err := db.Exec(`UPDATE users SET status = 'inactive' WHERE user_id = ?`, userID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r := httptest.NewRequest("GET", "/api/v1/auth/oidc/mock/callback?"+back.Encode(), nil)
+			r.AddCookie(&http.Cookie{Name: stateCookie.Name, Value: stateCookie.Value})
+			w = httptest.NewRecorder()
+			oidcCallback(w, r, db, provider, acc)
+
+			if got := w.Header().Get("Location"); got != tt.wantLocation {
+				t.Errorf("callback redirected to %q, want %q", got, tt.wantLocation)
+			}
+			identities, err := db.GetIdentities(userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(identities) != tt.wantLinked {
+				t.Errorf("%d identities linked, want %d", len(identities), tt.wantLinked)
+			}
+		})
+	}
+}
+
+// TestLinkCallbackNeedsStateCookie checks the state row alone is not enough:
+// a callback URL replayed in another browser links nothing
+func TestLinkCallbackNeedsStateCookie(t *testing.T) {
+	db := openTestDB(t)
+	provider := newTestProvider(t)
+	userID := insertTestUser(t, db, "alice")
+
+	w := httptest.NewRecorder()
+	startOIDCFlow(w, httptest.NewRequest("GET", "/api/v1/auth/oidc/mock/link", nil), db, provider, userID)
+	back := signInAtProvider(t, w.Header().Get("Location"), "mallory-at-provider")
+
+	w = httptest.NewRecorder()
+	oidcCallback(w, httptest.NewRequest("GET", "/api/v1/auth/oidc/mock/callback?"+back.Encode(), nil), db, provider, Accounts{AppURL: "http://app.test"})
+
+	if got, want := w.Header().Get("Location"), "http://app.test/login?error=oidc_state"; got != want {
+		t.Errorf("callback redirected to %q, want %q", got, want)
+	}
+	identities, err := db.GetIdentities(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(identities) != 0 {
+		t.Errorf("%d identities linked without the state cookie, want 0", len(identities))
+	}
+}
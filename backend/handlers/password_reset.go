@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"social_network/dbTools"
+	"social_network/mailer"
+	"social_network/utils"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	passwordResetTTL = time.Hour
+	// maxPasswordResetsPerHour limits how many reset emails one account can receive
+	maxPasswordResetsPerHour = 3
+)
+
+// forgotPasswordMessage is returned whether or not the email belongs to an
+// account, so the endpoint cannot be used to discover registered addresses
+const forgotPasswordMessage = "If an account exists for that email, a reset link has been sent"
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// ForgotPasswordHandler emails a single-use password reset link
+func ForgotPasswordHandler(db *dbTools.DB, acc Accounts, w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := utils.ValidateEmail(req.Email); err != nil {
+		utils.SendError(w, utils.InvalidField("email", err))
+		return
+	}
+
+	user, _, err := fetchLoginUser(db, "email", req.Email)
+	if err == sql.ErrNoRows {
+		utils.SendSuccessResponse(w, map[string]interface{}{"message": forgotPasswordMessage})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Password reset user lookup failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to request password reset")
+		return
+	}
+
+	recent, err := db.CountRecentPasswordResets(user.UserID, time.Now().Add(-time.Hour))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Password reset count failed", "user_id", user.UserID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to request password reset")
+		return
+	}
+	if recent >= maxPasswordResetsPerHour {
+		slog.WarnContext(r.Context(), "Password reset limit reached", "user_id", user.UserID)
+		utils.SendSuccessResponse(w, map[string]interface{}{"message": forgotPasswordMessage})
+		return
+	}
+
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Password reset token failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to request password reset")
+		return
+	}
+	if err := db.CreatePasswordResetToken(user.UserID, utils.HashToken(token), utils.ClientIP(r), time.Now().Add(passwordResetTTL)); err != nil {
+		slog.ErrorContext(r.Context(), "Password reset token store failed", "user_id", user.UserID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to request password reset")
+		return
+	}
+
+	// Send in the background so response time does not reveal whether the account exists
+	msg := passwordResetMessage(acc.AppURL, user, token)
+	goBackground(func() {
+		if err := acc.Mailer.Send(msg); err != nil {
+			slog.ErrorContext(r.Context(), "Password reset mail failed", "user_id", user.UserID, "err", err)
+		}
+	})
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": forgotPasswordMessage})
+}
+
+// ResetPasswordHandler sets a new password using a token from ForgotPasswordHandler.
+// Every session and access token of the user is revoked, so they have to log in
+// again everywhere and create new tokens for their scripts.
+func ResetPasswordHandler(db *dbTools.DB, acc Accounts, w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.Token == "" {
+		var fields utils.FieldErrors
+		fields.Add("token", utils.FieldRequired, "Reset token is required")
+		utils.SendError(w, fields.Err())
+		return
+	}
+	if err := utils.ValidatePasswordStrength(req.Password, acc.PasswordPolicy); err != nil {
+		utils.SendError(w, utils.InvalidField("password", err))
+		return
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Password hash failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	userID, err := db.ResetPassword(utils.HashToken(req.Token), string(hashedPassword))
+	if errors.Is(err, dbTools.ErrInvalidResetToken) {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Reset link is invalid or has expired")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Password reset failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to reset password")
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Password reset, all sessions and access tokens revoked", "user_id", userID)
+	utils.ClearSessionCookie(w)
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Password has been reset and access tokens revoked, please log in"})
+}
+
+func passwordResetMessage(appURL string, user dbTools.User, token string) mailer.Message {
+	link := appURL + "/reset-password?token=" + url.QueryEscape(token)
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\n"+
+			"Someone asked to reset the password for your account. If it was you, open this link within %d minutes:\n\n"+
+			"%s\n\n"+
+			"If you did not ask for this, you can ignore this email; your password will not change.\n",
+			user.FirstName, int(passwordResetTTL.Minutes()), link),
+	}
+}
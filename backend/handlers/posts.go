@@ -3,16 +3,18 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"social_network/auth"
 	"social_network/dbTools"
-	"social_network/middleware"
 	"social_network/utils"
 	"strconv"
-	"strings"
 	"time"
 )
 
+// maxContentLength is the longest post or comment, in bytes, before sanitizing
+const maxContentLength = 1100
+
 var (
 	postID int
 )
@@ -22,134 +24,177 @@ var (
 - Validate active session before doing anything
 */
 
-// GetFeedPostsHandler handles getting user feed/posts
-func GetFeedPostsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) error {
+// GetFeedPostsHandler handles getting user feed/posts. Each post comes with
+// its commentPreview latest comments.
+func GetFeedPostsHandler(db *dbTools.DB, commentPreview int, w http.ResponseWriter, r *http.Request) error {
 	// log.Print("GetFeedPostsHandler called")
-	middleware.SetCORSHeaders(w)
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return fmt.Errorf("method not allowed")
 	}
 
 	// Get the current user ID from the session
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return err
+	userID := auth.UserID(r)
+
+	// ?tag= narrows the feed to one category or hashtag
+	tag := r.URL.Query().Get("tag")
+	if tag != "" {
+		normalized, err := utils.NormalizeTag(tag)
+		if err != nil {
+			utils.SendError(w, utils.InvalidField("tag", err))
+			return err
+		}
+		tag = normalized
+	}
+
+	page, apiErr := pageParams(r)
+	if apiErr != nil {
+		utils.SendError(w, apiErr)
+		return apiErr
 	}
 
 	// Get all public posts and all posts from the current user
-	posts, err := db.GetFeedPosts(userID)
-	log.Print("GetFeedPosts: ", userID, posts)
+	posts, next, err := db.GetFeedPosts(userID, tag, dbTools.PostPage{Page: page, CommentPreview: commentPreview})
 	if err != nil {
-		http.Error(w, "Failed to retrieve posts", http.StatusInternalServerError)
-		// log.Print("GetFeedPostsHandler: Error retrieving posts:", err)
+		slog.ErrorContext(r.Context(), "Feed retrieval failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve posts")
 		return err
 	}
 
-	// log.Print("GetFeedPosts: ", userID, posts)
-
-	// Return the posts as JSON
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(posts)
+	sendPostPage(w, posts, next)
 	return nil
 }
 
+// pageParams reads the limit and cursor query parameters of post and comment listings
+func pageParams(r *http.Request) (dbTools.Page, *utils.APIError) {
+	var page dbTools.Page
+	var fields utils.FieldErrors
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > dbTools.MaxPageSize {
+			fields.Add("limit", utils.FieldInvalid, fmt.Sprintf("Limit must be a number from 1 to %d", dbTools.MaxPageSize))
+		}
+		page.Limit = n
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		after, err := dbTools.DecodeCursor(cursor)
+		if err != nil {
+			fields.Add("cursor", utils.FieldInvalid, "Invalid cursor")
+		}
+		page.After = after
+	}
+	return page, fields.Err()
+}
+
+// sendPostPage answers with a page of posts. next_cursor is null on the last page.
+func sendPostPage(w http.ResponseWriter, posts []dbTools.PostResponse, next string) {
+	if posts == nil {
+		posts = []dbTools.PostResponse{}
+	}
+	var nextCursor interface{}
+	if next != "" {
+		nextCursor = next
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{
+		"posts":       posts,
+		"next_cursor": nextCursor,
+	})
+}
+
+// tagDirectorySize is how many tags the tag directory lists
+const tagDirectorySize = 100
+
+// GetTagsHandler lists the categories and hashtags of the posts the user can
+// see, with how many posts have each
+func GetTagsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	userID := auth.UserID(r)
+	tags, err := db.GetTagDirectory(userID, tagDirectorySize)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Tag directory failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve tags")
+		return
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{"tags": tags})
+}
+
 // GetProfilePostsHandler handles getting my/user posts
-func GetProfilePostsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) error {
+func GetProfilePostsHandler(db *dbTools.DB, commentPreview int, w http.ResponseWriter, r *http.Request) error {
 	// log.Print("GetProfilePostsHandler called")
-	middleware.SetCORSHeaders(w)
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return fmt.Errorf("method not allowed")
 	}
 
 	// Get the current user ID from the session
-	currentUserID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return err
-	}
+	currentUserID := auth.UserID(r)
+
+	targetUserUUID := r.PathValue("uuid")
 
-	// Extract user UUID from URL path: /api/getprofileposts/{user_uuid}
-	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	var targetUserUUID string
-	if len(pathParts) >= 3 {
-		targetUserUUID = pathParts[2]
+	page, apiErr := pageParams(r)
+	if apiErr != nil {
+		utils.SendError(w, apiErr)
+		return apiErr
 	}
 
-	// Get all the targetUser's viewable posts
-	posts, err := db.GetProfilePosts(currentUserID, targetUserUUID)
+	// Get the targetUser's viewable posts
+	posts, next, err := db.GetProfilePosts(currentUserID, targetUserUUID, dbTools.PostPage{Page: page, CommentPreview: commentPreview})
 	if err != nil {
-		http.Error(w, "Failed to retrieve posts", http.StatusInternalServerError)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve posts")
 		// log.Print("GetProfilePostsHandler: Error retrieving posts:", err)
 		return err
 	}
 
 	// log.Print("GetProfilePosts: ", targetUserUUID, posts)
 
-	// Return the posts as JSON
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(posts)
+	sendPostPage(w, posts, next)
 	return nil
 }
 
 // GetGroupPostsHandler handles getting group feed/posts
-func GetGroupPostsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) error {
+func GetGroupPostsHandler(db *dbTools.DB, commentPreview int, w http.ResponseWriter, r *http.Request) error {
 	//log.Print("GetGroupPostsHandler called")
-	middleware.SetCORSHeaders(w)
 	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return fmt.Errorf("method not allowed")
 	}
 
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return err
-	}
+	userID := auth.UserID(r)
 
-	// Parse groupId from URL: /api/getgroupposts/{groupId}
-	// Example: /api/getgroupposts/123
-	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	// pathParts: ["api", "getgroupposts", "{groupId}"]
-	if len(pathParts) < 3 {
-		http.Error(w, "Missing groupId", http.StatusBadRequest)
-		//log.Print("GetGroupPostsHandler: Missing groupId in URL path", r.URL.Path)
-		return fmt.Errorf("missing groupId")
-	}
-	groupIdStr := pathParts[2]
-	groupId, err := strconv.Atoi(groupIdStr)
+	groupId, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
-		http.Error(w, "Invalid groupId", http.StatusBadRequest)
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid groupId")
 		//log.Print("GetGroupPostsHandler: Invalid groupId:", err)
 		return err
 	}
 
+	page, apiErr := pageParams(r)
+	if apiErr != nil {
+		utils.SendError(w, apiErr)
+		return apiErr
+	}
+
 	// Get posts for this group
-	posts, err := db.GetGroupPosts(userID, groupId)
+	posts, next, err := db.GetGroupPosts(userID, groupId, dbTools.PostPage{Page: page, CommentPreview: commentPreview})
 	if err != nil {
-		http.Error(w, "Failed to retrieve group posts", http.StatusInternalServerError)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve group posts")
 		//log.Print("GetGroupPostsHandler: Error retrieving posts:", err)
 		return err
 	}
 
 	//log.Print("GetGroupPosts: ", groupId, posts)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(posts)
+	sendPostPage(w, posts, next)
 	return nil
 }
 
 // CreatePostHandler handles creating new posts
-func CreatePostHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) error {
-	middleware.SetCORSHeaders(w)
+func CreatePostHandler(db *dbTools.DB, maxFormBytes int64, w http.ResponseWriter, r *http.Request) error {
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return fmt.Errorf("method not allowed")
 	}
-	err := r.ParseMultipartForm(10 << 20) // 10MB max
+	err := r.ParseMultipartForm(maxFormBytes)
 	if err != nil {
-		http.Error(w, "Could not parse form", http.StatusBadRequest)
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Could not parse form")
 		return err
 	}
 	//logs
@@ -170,7 +215,7 @@ func CreatePostHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) e
 	if groupIDStr != "" {
 		groupID, err := strconv.Atoi(groupIDStr)
 		if err != nil {
-			http.Error(w, "Invalid group_id", http.StatusBadRequest)
+			utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid group_id")
 			return err
 		}
 		groupIDPtr = &groupID
@@ -181,34 +226,45 @@ func CreatePostHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) e
 	var selectedFollowersUUIDs []string
 	if (privacy == "semi-private" || privacy == "private") && selectedFollowersStr != "" {
 		if err := json.Unmarshal([]byte(selectedFollowersStr), &selectedFollowersUUIDs); err != nil {
-			http.Error(w, "Invalid selectedFollowers format", http.StatusBadRequest)
+			utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid selectedFollowers format")
 			return err
 		}
 	}
 
 	// Validate content
-	if len(content) == 0 {
-		http.Error(w, "Content cannot be empty", http.StatusBadRequest)
+	if err := utils.ValidateText("Content", content, maxContentLength); err != nil {
+		utils.SendError(w, utils.InvalidField("content", err))
 		return err
 	}
-	if len(content) > 1100 {
-		http.Error(w, "Content too long", http.StatusBadRequest)
-		return err
+	var categories []string
+	if categoriesStr := r.FormValue("categories"); categoriesStr != "" {
+		if err := json.Unmarshal([]byte(categoriesStr), &categories); err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid categories format")
+			return err
+		}
+	}
+	tags, apiErr := postTags(categories, content)
+	if apiErr != nil {
+		utils.SendError(w, apiErr)
+		return apiErr
 	}
 	content = utils.Sanitize(content)
 
 	// Validate privacy
 	validPrivacy := map[string]bool{"public": true, "semi-private": true, "private": true}
 	if !validPrivacy[privacy] {
-		http.Error(w, "Invalid privacy setting", http.StatusBadRequest)
+		var fields utils.FieldErrors
+		fields.Add("privacy", utils.FieldInvalid, "Invalid privacy setting")
+		utils.SendError(w, fields.Err())
 		return err
 	}
 
 	// Get current user ID from session
-	currentUserID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return err
+	currentUserID := auth.UserID(r)
+
+	// Only members can post in a group
+	if groupIDPtr != nil && !checkGroupMembership(w, db, *groupIDPtr, currentUserID, "Only group members can post in this group") {
+		return fmt.Errorf("user %d is not a member of group %d", currentUserID, *groupIDPtr)
 	}
 
 	post := dbTools.Post{
@@ -218,9 +274,19 @@ func CreatePostHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) e
 		Privacy:   privacy,
 		CreatedAt: timeNow,
 	}
-	postID, err = db.InsertPostToDB(&post)
+
+	// A broken file part is refused before anything is stored
+	file, handler, err := r.FormFile("file")
+	if err == nil {
+		defer file.Close()
+	} else if err != http.ErrMissingFile {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Failed to get file from form")
+		return err
+	}
+
+	postID, err = db.InsertPostToDB(&post, tags)
 	if err != nil {
-		http.Error(w, "Failed InsertPostToDB", http.StatusInternalServerError)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed InsertPostToDB")
 		// log.Print("CreatePostHandler: Error inserting post:", err)
 		return err
 	}
@@ -228,15 +294,13 @@ func CreatePostHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) e
 	// Store selected followers for semi-private and private posts
 	if (privacy == "semi-private" || privacy == "private") && len(selectedFollowersUUIDs) > 0 {
 		if err := db.InsertSelectedFollowers(postID, selectedFollowersUUIDs); err != nil {
-			http.Error(w, "Failed to save selected followers", http.StatusInternalServerError)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to save selected followers")
 			// log.Print("CreatePostHandler: Error inserting selected followers:", err)
 			return err
 		}
 	}
 
-	file, handler, err := r.FormFile("file")
-	if err == nil {
-		defer file.Close()
+	if file != nil {
 		fileMeta := &dbTools.File{
 			UploaderID:   currentUserID,
 			FilenameOrig: handler.Filename,
@@ -246,24 +310,21 @@ func CreatePostHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) e
 		}
 		uploadErr := db.FileUpload(file, fileMeta, r, w)
 		if uploadErr != nil {
-			http.Error(w, "Failed to upload file", http.StatusInternalServerError)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to upload file")
 			return uploadErr
 		}
-	} else if err != http.ErrMissingFile {
-		http.Error(w, "Failed to get file from form", http.StatusBadRequest)
-		return err
 	}
 
 	// Create notifications for group posts
 	if groupIDPtr != nil {
 		group, err := db.GetGroupByID(*groupIDPtr)
 		if err != nil {
-			log.Printf("Failed to get group details for post notification: %v", err)
+			slog.ErrorContext(r.Context(), "Failed to get group details for post notification", "err", err)
 		} else {
 			notificationHelpers := dbTools.NewNotificationHelpers(db)
 			err = notificationHelpers.CreateGroupPostNotification(int(currentUserID), *groupIDPtr, postID, group.Title)
 			if err != nil {
-				log.Printf("Failed to create post notifications: %v", err)
+				slog.ErrorContext(r.Context(), "Failed to create post notifications", "err", err)
 				// Don't fail the request if notification creation fails
 			}
 		}
@@ -275,75 +336,80 @@ func CreatePostHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) e
 	return nil
 }
 
+// postTags combines the categories picked for a post with the #hashtags in
+// its content. Content must not be sanitized yet.
+func postTags(categories []string, content string) ([]string, *utils.APIError) {
+	var fields utils.FieldErrors
+	normalized := make([]string, 0, len(categories))
+	for _, category := range categories {
+		tag, err := utils.NormalizeTag(category)
+		if err != nil {
+			fields.Check("categories", err)
+			continue
+		}
+		normalized = append(normalized, tag)
+	}
+	tags := utils.MergeTags(normalized, utils.ExtractHashtags(content))
+	if len(tags) > utils.MaxPostTags {
+		fields.Add("categories", utils.FieldTooLong, fmt.Sprintf("A post can have at most %d categories and hashtags", utils.MaxPostTags))
+	}
+	if err := fields.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
 // CreateCommentHandler handles creating new comments
-func CreateCommentHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) error {
-	middleware.SetCORSHeaders(w)
+func CreateCommentHandler(db *dbTools.DB, maxFormBytes int64, w http.ResponseWriter, r *http.Request) error {
 	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return fmt.Errorf("method not allowed")
 	}
-	err := r.ParseMultipartForm(10 << 20) // 10MB max
+	err := r.ParseMultipartForm(maxFormBytes)
 	if err != nil {
-		http.Error(w, "Could not parse form", http.StatusBadRequest)
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Could not parse form")
 		return err
 	}
 
 	timeNow := time.Now()
 	content := r.FormValue("content")
-	postUUID := r.FormValue("post_uuid")
+	// The post comes from the path, or from the form on the legacy route
+	postUUID := r.PathValue("uuid")
 	if postUUID == "" {
-		http.Error(w, "Missing post UUID", http.StatusBadRequest)
-		return fmt.Errorf("missing post UUID")
+		postUUID = r.FormValue("post_uuid")
 	}
-
-	// Parse group_id if present
-	var groupIDPtr *int
-	groupIDStr := r.FormValue("group_id")
-	if groupIDStr != "" {
-		groupID, err := strconv.Atoi(groupIDStr)
-		if err != nil {
-			http.Error(w, "Invalid group_id", http.StatusBadRequest)
-			return err
-		}
-		groupIDPtr = &groupID
+	if postUUID == "" {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Missing post UUID")
+		return fmt.Errorf("missing post UUID")
 	}
 
 	// Validate content
-	if len(content) == 0 {
-		http.Error(w, "Content cannot be empty", http.StatusBadRequest)
-		return err
-	}
-	if len(content) > 1100 {
-		http.Error(w, "Content too long", http.StatusBadRequest)
+	if err := utils.ValidateText("Content", content, maxContentLength); err != nil {
+		utils.SendError(w, utils.InvalidField("content", err))
 		return err
 	}
 	content = utils.Sanitize(content)
 
-	// Fetch the post by UUID to get its ID
-	post, err := db.GetPostByUUID(r.Context(), postUUID)
-	if err != nil {
-		http.Error(w, "Post not found", http.StatusBadRequest)
-		return err
-	}
-
 	// Get current user ID from session
-	currentUserID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return err
+	currentUserID := auth.UserID(r)
+
+	// Signed-in users may comment on every post they can see
+	post, ok := visiblePost(db, w, r, postUUID)
+	if !ok {
+		return fmt.Errorf("post %s not visible to user %d", postUUID, currentUserID)
 	}
 
 	comment := dbTools.Comment{
 		CommenterID: currentUserID,
 		PostID:      post.PostID,
-		GroupID:     groupIDPtr, // Set groupID if present, otherwise nil
+		GroupID:     post.GroupID, // The post's group, whatever the form says
 		Content:     content,
 		PostPrivacy: post.Privacy,
 		CreatedAt:   timeNow,
 	}
 	commentID, err := db.InsertCommentToDB(&comment)
 	if err != nil {
-		http.Error(w, "Failed InsertCommentToDB", http.StatusInternalServerError)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed InsertCommentToDB")
 		return err
 	}
 
@@ -359,11 +425,11 @@ func CreateCommentHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request
 		}
 		uploadErr := db.FileUpload(file, fileMeta, r, w)
 		if uploadErr != nil {
-			http.Error(w, "Failed to upload file", http.StatusInternalServerError)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to upload file")
 			return uploadErr
 		}
 	} else if err != http.ErrMissingFile {
-		http.Error(w, "Failed to get file from form", http.StatusBadRequest)
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Failed to get file from form")
 		return err
 	}
 
@@ -371,3 +437,67 @@ func CreateCommentHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(comment)
 	return nil
 }
+
+// GetCommentsHandler pages through the comments of a post, newest first
+func GetCommentsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	post, ok := visiblePost(db, w, r, r.PathValue("uuid"))
+	if !ok {
+		return
+	}
+	userID := auth.UserID(r)
+
+	page, apiErr := pageParams(r)
+	if apiErr != nil {
+		utils.SendError(w, apiErr)
+		return
+	}
+	comments, next, err := db.GetComments(post.PostID, userID, page)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Comment retrieval failed", "post_id", post.PostID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve comments")
+		return
+	}
+	var nextCursor interface{}
+	if next != "" {
+		nextCursor = next
+	}
+	utils.SendSuccessResponse(w, map[string]interface{}{
+		"comments":    comments,
+		"next_cursor": nextCursor,
+	})
+}
+
+// visiblePost loads the post with the given UUID if the user can see it. It
+// has answered the request when ok is false.
+func visiblePost(db *dbTools.DB, w http.ResponseWriter, r *http.Request, postUUID string) (post *dbTools.Post, ok bool) {
+	post, err := db.GetPostByUUID(r.Context(), postUUID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Post lookup failed", "post_uuid", postUUID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to load post")
+		return nil, false
+	}
+	if post == nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Post not found")
+		return nil, false
+	}
+	if !postVisible(db, w, r, post, "Post not found") {
+		return nil, false
+	}
+	return post, true
+}
+
+// postVisible checks that the user can see the post. Posts the user cannot
+// see are reported as missing with notFound, so their existence does not leak.
+func postVisible(db *dbTools.DB, w http.ResponseWriter, r *http.Request, post *dbTools.Post, notFound string) bool {
+	visible, err := db.CanViewPost(r.Context(), post, auth.UserID(r))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Post visibility check failed", "post_id", post.PostID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to check post visibility")
+		return false
+	}
+	if !visible {
+		utils.SendErrorResponse(w, http.StatusNotFound, notFound)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"social_network/dbTools"
+	"testing"
+)
+
+func TestTamperedCursorIsRejected(t *testing.T) {
+	valid := dbTools.Cursor{CreatedAt: "2026-01-01 12:00:00+00:00", ID: 7}.Encode()
+	for name, cursor := range map[string]string{
+		"truncated":    valid[:len(valid)-4],
+		"changed byte": "A" + valid[1:],
+		"not base64":   "not a cursor!",
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/posts?cursor="+url.QueryEscape(cursor), nil)
+			w := httptest.NewRecorder()
+			// The cursor is checked before the database is used
+			GetFeedPostsHandler(nil, 3, w, r)
+
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status %d, want 400", w.Code)
+			}
+			var body struct {
+				Code   string `json:"code"`
+				Fields []struct {
+					Field string `json:"field"`
+				} `json:"fields"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Code != "validation_failed" || len(body.Fields) != 1 || body.Fields[0].Field != "cursor" {
+				t.Errorf("body %+v, want a validation_failed error for the cursor field", body)
+			}
+		})
+	}
+}
@@ -4,13 +4,11 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"social_network/auth"
 	"social_network/dbTools"
-	"social_network/middleware"
 	"social_network/utils"
-	"strings"
-	"time"
 )
 
 // PrivacyRequest represents a privacy update request
@@ -20,43 +18,39 @@ type PrivacyRequest struct {
 
 // ProfileHandler fetches user profile data
 func ProfileHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	log.Printf("ProfileHandler called at %s for URL %s", time.Now().Format(time.RFC3339), r.URL.Path)
-	middleware.SetCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 	if r.Method != "GET" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Method not allowed"})
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	// Extract user_uuid from URL path
-	path := strings.TrimPrefix(r.URL.Path, "/api/profile/")
-	userUUID := strings.Split(path, "/")[0]
+	userUUID := r.PathValue("uuid")
 	if userUUID == "" || userUUID == "me" || userUUID == "privacy" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Missing user UUID"})
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Missing user UUID")
 		return
 	}
 
+	// Accounts waiting for email verification are only visible to themselves
+	currentUserID := auth.UserID(r)
+
 	// Fetch limited profile data
 	var profile dbTools.User
 	query := `
         SELECT user_id, user_uuid, COALESCE(first_name, '') as first_name, COALESCE(last_name, '') as last_name,
                COALESCE(nickname, '') as nickname, COALESCE(avatar, '') as avatar, privacy
         FROM users
-        WHERE user_uuid = ? AND status = 'active'
+        WHERE user_uuid = ? AND (status = 'active' OR (status = 'pending_verification' AND user_id = ?))
     `
-	err := db.QueryRow(query, userUUID).Scan(
+	err := db.QueryRow(query, userUUID, currentUserID).Scan(
 		&profile.UserID, &profile.UserUUID, &profile.FirstName, &profile.LastName,
 		&profile.Nickname, &profile.Avatar, &profile.Privacy,
 	)
 	if err != nil {
-		log.Printf("Profile fetch error for user_uuid %s: %v", userUUID, err)
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "User not found"})
+		slog.DebugContext(r.Context(), "Profile: user lookup failed", "user_uuid", userUUID, "err", err)
+		utils.SendErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
 
@@ -73,14 +67,11 @@ func ProfileHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 
 	// Check authorization
 	isAuthorized := false
-	currentUserID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err == nil {
+	if currentUserID != 0 {
 		if int(currentUserID) == profile.UserID {
 			isAuthorized = true
-			log.Println("Authorized: Own profile")
 		} else if profile.Privacy == "public" {
 			isAuthorized = true
-			log.Println("Authorized: Public profile")
 		} else {
 			followerQuery := `
                 SELECT COUNT(*)
@@ -90,19 +81,16 @@ func ProfileHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 			var followerCount int
 			err = db.QueryRow(followerQuery, currentUserID, profile.UserID).Scan(&followerCount)
 			if err != nil {
-				log.Printf("Follower query error: %v", err)
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Follower check error"})
+				slog.ErrorContext(r.Context(), "Follower query failed", "err", err)
+				utils.SendErrorResponse(w, http.StatusInternalServerError, "Follower check error")
 				return
 			}
 			if followerCount > 0 {
 				isAuthorized = true
-				log.Println("Authorized: Accepted follower")
 			}
 		}
 	} else if profile.Privacy == "public" {
 		isAuthorized = true
-		log.Println("Authorized: Public profile, no login")
 	}
 
 	// Fetch full profile if authorized
@@ -112,18 +100,17 @@ func ProfileHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
                    COALESCE(nickname, '') as nickname, COALESCE(about_me, '') as about_me,
                    COALESCE(avatar, '') as avatar, privacy, role, created_at, updated_at
             FROM users
-            WHERE user_uuid = ? AND status = 'active'
+            WHERE user_uuid = ? AND (status = 'active' OR (status = 'pending_verification' AND user_id = ?))
         `
 		var dob sql.NullTime
-		err = db.QueryRow(query, userUUID).Scan(
+		err = db.QueryRow(query, userUUID, currentUserID).Scan(
 			&profile.UserID, &profile.UserUUID, &profile.Email, &profile.FirstName,
 			&profile.LastName, &dob, &profile.Nickname, &profile.AboutMe,
 			&profile.Avatar, &profile.Privacy, &profile.Role, &profile.CreatedAt, &profile.UpdatedAt,
 		)
 		if err != nil {
-			log.Printf("Full profile fetch error for user_uuid %s: %v", userUUID, err)
-			w.WriteHeader(http.StatusNotFound)
-			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "User not found"})
+			slog.ErrorContext(r.Context(), "Full profile fetch failed", "user_uuid", userUUID, "err", err)
+			utils.SendErrorResponse(w, http.StatusNotFound, "User not found")
 			return
 		}
 		if dob.Valid {
@@ -138,43 +125,33 @@ func ProfileHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 
 // PrivacyHandler updates user privacy setting
 func PrivacyHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	middleware.SetCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 	if r.Method != "POST" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Method not allowed"})
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	// Verify session and get user ID
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		log.Printf("No session cookie: %v", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "No active session"})
-		return
-	}
+	userID := auth.UserID(r)
 
 	// Parse request body
 	var req PrivacyRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Invalid request body: %v", err)
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Invalid input"})
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid input")
 		return
 	}
 
 	if req.Privacy != "public" && req.Privacy != "private" {
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Invalid privacy setting"})
+		var fields utils.FieldErrors
+		fields.Add("privacy", utils.FieldInvalid, "Invalid privacy setting")
+		utils.SendError(w, fields.Err())
 		return
 	}
 
 	// Update privacy and auto-accept follows in a transaction
-	err = db.WithTransaction(func(tx *sql.Tx) error {
+	err := db.WithTransaction(func(tx *sql.Tx) error {
 		query := `UPDATE users SET privacy = ?, updated_at = datetime('now') WHERE user_id = ? AND status = 'active'`
 		_, err := tx.Exec(query, req.Privacy, int(userID))
 		if err != nil {
@@ -214,17 +191,13 @@ func PrivacyHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 
 					// Use notification helpers outside transaction for now
 					// Note: This creates a temporary inconsistency but avoids transaction complexity
-					go func(fID, followerID, userID int) {
-						db := &dbTools.DB{}
-						if db, err := db.OpenDB(); err == nil {
-							defer db.CloseDB()
-							notificationHelpers := dbTools.NewNotificationHelpers(db)
-							err = notificationHelpers.CreateFollowAcceptedNotification(followerID, userID, fID)
-							if err != nil {
-								log.Printf("Notification creation error: %v", err)
-							}
+					goBackground(func() {
+						notificationHelpers := dbTools.NewNotificationHelpers(db)
+						err := notificationHelpers.CreateFollowAcceptedNotification(followerUserID, int(userID), followID)
+						if err != nil {
+							slog.ErrorContext(r.Context(), "Notification creation failed", "err", err)
 						}
-					}(followID, followerUserID, int(userID))
+					})
 				}
 				if err = rows.Err(); err != nil {
 					return fmt.Errorf("accepted follows rows error: %v", err)
@@ -234,9 +207,8 @@ func PrivacyHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		return nil
 	})
 	if err != nil {
-		log.Printf("Transaction error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Failed to update privacy"})
+		slog.ErrorContext(r.Context(), "Transaction failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to update privacy")
 		return
 	}
 
@@ -253,26 +225,16 @@ func PrivacyHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 
 // ProfileMeHandler fetches the current user's profile
 func ProfileMeHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	log.Println("ProfileMeHandler called")
-	middleware.SetCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 	if r.Method != "GET" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Method not allowed"})
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	currentUserID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		log.Printf("No session cookie: %v", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "No active session"})
-		return
-	}
-	log.Printf("Session validated for user_id %d", currentUserID)
+	currentUserID := auth.UserID(r)
 
 	var profile dbTools.User
 	query := `
@@ -280,18 +242,17 @@ func ProfileMeHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
                COALESCE(nickname, '') as nickname, COALESCE(about_me, '') as about_me,
                COALESCE(avatar, '') as avatar, privacy, role, created_at, updated_at
         FROM users
-        WHERE user_id = ? AND status = 'active'
+        WHERE user_id = ? AND status IN ('active', 'pending_verification')
     `
 	var dob sql.NullTime
-	err = db.QueryRow(query, int(currentUserID)).Scan(
+	err := db.QueryRow(query, int(currentUserID)).Scan(
 		&profile.UserID, &profile.UserUUID, &profile.Email, &profile.FirstName,
 		&profile.LastName, &dob, &profile.Nickname, &profile.AboutMe,
 		&profile.Avatar, &profile.Privacy, &profile.Role, &profile.CreatedAt, &profile.UpdatedAt,
 	)
 	if err != nil {
-		log.Printf("Profile fetch error for user_id %d: %v", currentUserID, err)
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "User not found"})
+		slog.ErrorContext(r.Context(), "Profile fetch failed", "user_id", currentUserID, "err", err)
+		utils.SendErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
 	if dob.Valid {
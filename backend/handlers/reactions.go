@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"social_network/auth"
+	"social_network/dbTools"
+	"social_network/utils"
+)
+
+// ReactionRequest is the body of the reaction endpoints
+type ReactionRequest struct {
+	Reaction string `json:"reaction"` // like or dislike
+}
+
+// ReactToPostHandler toggles the user's like or dislike on a post
+func ReactToPostHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	post, ok := visiblePost(db, w, r, r.PathValue("uuid"))
+	if !ok {
+		return
+	}
+	react(db, w, r, "post", post.PostID, post.PosterID)
+}
+
+// ReactToCommentHandler toggles the user's like or dislike on a comment. The
+// comment's post decides who may react.
+func ReactToCommentHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	comment, post, ok := commentWithPost(db, w, r)
+	if !ok || !postVisible(db, w, r, post, "Comment not found") {
+		return
+	}
+	react(db, w, r, "comment", comment.CommentID, comment.CommenterID)
+}
+
+// react applies the reaction in the request body to a post or one of its comments
+// and answers with the new counts. Signed-in users may react to every post they
+// can see and to its comments.
+func react(db *dbTools.DB, w http.ResponseWriter, r *http.Request, parentType string, parentID, authorID int) {
+	var req ReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !dbTools.ValidReaction(req.Reaction) {
+		var fields utils.FieldErrors
+		fields.Add("reaction", utils.FieldInvalid, "Reaction must be like or dislike")
+		utils.SendError(w, fields.Err())
+		return
+	}
+
+	userID := auth.UserID(r)
+	reaction, err := db.ToggleReaction(userID, parentType, parentID, req.Reaction)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Saving reaction failed", "user_id", userID, "parent_type", parentType, "parent_id", parentID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to save reaction")
+		return
+	}
+
+	if reaction == dbTools.ReactionLike {
+		notificationHelpers := dbTools.NewNotificationHelpers(db)
+		if err := notificationHelpers.CreateLikeNotification(userID, authorID, parentType, parentID); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to create like notification", "err", err)
+			// The reaction is saved either way
+		}
+	}
+
+	reactions, err := db.GetReactions(parentType, []int{parentID}, userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Reaction counts failed", "parent_type", parentType, "parent_id", parentID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to load reactions")
+		return
+	}
+	counts := reactions[parentID]
+	utils.SendSuccessResponse(w, map[string]interface{}{
+		"likes":       counts.Likes,
+		"dislikes":    counts.Dislikes,
+		"my_reaction": counts.MyReaction,
+	})
+}
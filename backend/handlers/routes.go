@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"net/http"
+	"social_network/auth"
+	"social_network/config"
+	"social_network/dbTools"
+	"social_network/mailer"
+	"social_network/middleware"
+	"social_network/oidc"
+	"social_network/router"
+	"social_network/utils"
+	"strconv"
+	"strings"
+)
+
+// APIPrefix is where the current version of the API is served
+const APIPrefix = "/api/v1"
+
+// Routes is the route table of the server. Legacy paths are the unversioned
+// routes the frontend used before /api/v1; they answer the same way but are
+// marked as deprecated.
+func Routes(db *dbTools.DB, cfg *config.Config, m mailer.Mailer, providers *oidc.Registry) []router.Route {
+	v1 := func(path string) string { return APIPrefix + path }
+	maxFormBytes := cfg.Uploads.MaxFormBytes
+	// Post listings come with the latest comments of each post
+	withPreview := func(h func(*dbTools.DB, int, http.ResponseWriter, *http.Request) error) func(*dbTools.DB, http.ResponseWriter, *http.Request) error {
+		return func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) error {
+			return h(db, cfg.Posts.CommentPreview, w, r)
+		}
+	}
+	trusted := middleware.NewOrigins(cfg.TrustedOrigins())
+	// The password policy name was checked by config.Validate
+	passwordPolicy, _ := utils.PasswordPolicyByName(cfg.Accounts.PasswordPolicy)
+	acc := Accounts{
+		Mailer:              m,
+		AppURL:              strings.TrimSuffix(cfg.Server.AppURL, "/"),
+		PasswordPolicy:      passwordPolicy,
+		DeletionGracePeriod: cfg.Accounts.DeletionGracePeriod.Std(),
+	}
+
+	return []router.Route{
+		{Method: "GET", Path: "/{$}", Auth: router.Public, Handler: HomeHandler},
+		// Uploaded files; post and comment attachments follow the post's visibility
+		{Method: "GET", Path: "/uploads/{file...}", Auth: router.Optional, Handler: withDB(db, UploadsHandler)},
+		// Prometheus metrics, for admins
+		{Method: "GET", Path: "/metrics", Auth: router.Required, Handler: MetricsHandler},
+		// Liveness and readiness probes
+		{Method: "GET", Path: "/healthz", Auth: router.Public, Handler: HealthzHandler},
+		{Method: "GET", Path: "/readyz", Auth: router.Public, Handler: withDB(db, ReadyzHandler)},
+
+		// Sign in, registration and account recovery
+		{Method: "POST", Path: v1("/login"), Auth: router.Public, Handler: withDB(db, LoginHandler), Legacy: []string{"/api/login"}},
+		{Method: "POST", Path: v1("/login/2fa"), Auth: router.Public, Handler: withDB(db, LoginTwoFactorHandler), Legacy: []string{"/api/login/2fa"}},
+		{Method: "POST", Path: v1("/register"), Auth: router.Public, Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+			RegisterHandler(db, acc, maxFormBytes, w, r)
+		}), Legacy: []string{"/api/register"}},
+		{Method: "POST", Path: v1("/logout"), Auth: router.Public, Handler: withDB(db, LogoutHandler), Legacy: []string{"/api/logout"}},
+		{Method: "GET", Path: v1("/session-check"), Auth: router.Optional, Handler: withDB(db, SessionCheckHandler), Legacy: []string{"/api/session-check"}},
+		{Method: "POST", Path: v1("/password/forgot"), Auth: router.Public, Handler: withAccounts(db, acc, ForgotPasswordHandler), Legacy: []string{"/api/password/forgot"}},
+		{Method: "POST", Path: v1("/password/reset"), Auth: router.Public, Handler: withAccounts(db, acc, ResetPasswordHandler), Legacy: []string{"/api/password/reset"}},
+		{Method: "POST", Path: v1("/email/verify"), Auth: router.Public, Handler: withDB(db, VerifyEmailHandler), Legacy: []string{"/api/email/verify"}},
+		{Method: "POST", Path: v1("/email/resend"), Auth: router.Required, Handler: withAccounts(db, acc, ResendVerificationHandler), Legacy: []string{"/api/email/resend"}},
+
+		// Sessions, personal access tokens, credentials and two-factor authentication
+		{Method: "GET", Path: v1("/sessions"), Auth: router.Required, Legacy: []string{"/api/sessions"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) { listSessions(w, r, db, auth.UserID(r)) })},
+		{Method: "DELETE", Path: v1("/sessions/others"), Auth: router.Required, Legacy: []string{"/api/sessions/others"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				revokeOtherSessions(w, r, db, auth.UserID(r))
+			})},
+		{Method: "PUT", Path: v1("/sessions/{uuid}"), Auth: router.Required, Legacy: []string{"/api/sessions/{uuid}"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				renameSession(w, r, db, auth.UserID(r), r.PathValue("uuid"))
+			})},
+		{Method: "DELETE", Path: v1("/sessions/{uuid}"), Auth: router.Required, Legacy: []string{"/api/sessions/{uuid}"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				revokeSession(w, r, db, auth.UserID(r), r.PathValue("uuid"))
+			})},
+		{Method: "GET", Path: v1("/tokens"), Auth: router.Required, Legacy: []string{"/api/tokens"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) { listAccessTokens(w, db, auth.UserID(r)) })},
+		{Method: "POST", Path: v1("/tokens"), Auth: router.Required, Legacy: []string{"/api/tokens"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				createAccessToken(w, r, db, auth.UserID(r))
+			})},
+		{Method: "DELETE", Path: v1("/tokens/{uuid}"), Auth: router.Required, Legacy: []string{"/api/tokens/{uuid}"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				revokeAccessToken(w, db, auth.UserID(r), r.PathValue("uuid"))
+			})},
+		{Method: "PUT", Path: v1("/account/email"), Auth: router.Required, Legacy: []string{"/api/account/email"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				changeEmail(w, r, db, acc, auth.UserID(r))
+			})},
+		{Method: "PUT", Path: v1("/account/password"), Auth: router.Required, Legacy: []string{"/api/account/password"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				changePassword(w, r, db, acc, auth.UserID(r))
+			})},
+		{Method: "POST", Path: v1("/account/deactivate"), Auth: router.Required, Legacy: []string{"/api/account/deactivate"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				deactivateAccount(w, r, db, auth.UserID(r))
+			})},
+		{Method: "POST", Path: v1("/account/delete"), Auth: router.Required, Legacy: []string{"/api/account/delete"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				deleteAccount(w, r, db, acc, auth.UserID(r))
+			})},
+		{Method: "GET", Path: v1("/2fa/status"), Auth: router.Required, Legacy: []string{"/api/2fa/status"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) { twoFactorStatus(w, db, auth.UserID(r)) })},
+		{Method: "POST", Path: v1("/2fa/enroll"), Auth: router.Required, Legacy: []string{"/api/2fa/enroll"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) { enrollTwoFactor(w, db, auth.UserID(r)) })},
+		{Method: "POST", Path: v1("/2fa/verify"), Auth: router.Required, Legacy: []string{"/api/2fa/verify"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				verifyTwoFactor(w, r, db, auth.UserID(r))
+			})},
+		{Method: "POST", Path: v1("/2fa/disable"), Auth: router.Required, Legacy: []string{"/api/2fa/disable"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				disableTwoFactor(w, r, db, auth.UserID(r))
+			})},
+		{Method: "POST", Path: v1("/2fa/recovery-codes"), Auth: router.Required, Legacy: []string{"/api/2fa/recovery-codes"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				regenerateRecoveryCodes(w, r, db, auth.UserID(r))
+			})},
+
+		// OpenID Connect. start and link accept ?redirect=/path, the frontend page to return to.
+		// Providers redirect to the unversioned callback, which is what they are registered with.
+		{Method: "GET", Path: v1("/auth/providers"), Auth: router.Public, Legacy: []string{"/api/auth/providers"},
+			Handler: func(w http.ResponseWriter, r *http.Request) { listOIDCProviders(w, providers) }},
+		{Method: "GET", Path: v1("/auth/oidc/{provider}/start"), Auth: router.Optional, Legacy: []string{"/api/auth/oidc/{provider}/start"},
+			Handler: withProvider(db, providers, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, p *oidc.Provider) {
+				startOIDCFlow(w, r, db, p, 0)
+			})},
+		{Method: "GET", Path: v1("/auth/oidc/{provider}/link"), Auth: router.Required, Legacy: []string{"/api/auth/oidc/{provider}/link"},
+			Handler: withProvider(db, providers, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, p *oidc.Provider) {
+				startOIDCFlow(w, r, db, p, auth.UserID(r))
+			})},
+		{Method: "GET", Path: v1("/auth/oidc/{provider}/callback"), Auth: router.Optional, Legacy: []string{"/api/auth/oidc/{provider}/callback"},
+			Handler: withProvider(db, providers, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, p *oidc.Provider) {
+				oidcCallback(w, r, db, p, acc)
+			})},
+		{Method: "GET", Path: v1("/auth/identities"), Auth: router.Required, Legacy: []string{"/api/auth/identities"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) { listIdentities(w, r, db) })},
+		{Method: "DELETE", Path: v1("/auth/identities/{id}"), Auth: router.Required, Legacy: []string{"/api/auth/identities/{id}"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				identityID, err := strconv.Atoi(r.PathValue("id"))
+				if err != nil {
+					utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid identity ID")
+					return
+				}
+				unlinkIdentity(w, r, db, identityID)
+			})},
+
+		// Profiles and users
+		{Method: "GET", Path: v1("/profile/me"), Auth: router.Required, Handler: withDB(db, ProfileMeHandler), Legacy: []string{"/api/profile/me"}},
+		{Method: "POST", Path: v1("/profile/privacy"), Auth: router.Required, Handler: withDB(db, PrivacyHandler), Legacy: []string{"/api/profile/privacy"}},
+		{Method: "GET", Path: v1("/profile/{uuid}"), Auth: router.Optional, Handler: withDB(db, ProfileHandler), Legacy: []string{"/api/profile/{uuid}"}},
+		{Method: "GET", Path: v1("/users"), Auth: router.Optional, Handler: withDB(db, UsersHandler), Legacy: []string{"/api/users"}},
+		{Method: "POST", Path: v1("/users/batch"), Auth: router.Required, Handler: withDB(db, BatchUsersHandler), Legacy: []string{"/api/users/batch"}},
+		{Method: "GET", Path: v1("/users/{id}"), Auth: router.Required, Handler: withDB(db, UserByIDHandler), Legacy: []string{"/api/users/{id}"}},
+		{Method: "GET", Path: v1("/users/{uuid}/posts"), Auth: router.Required, Handler: withDBErr(db, withPreview(GetProfilePostsHandler)), Legacy: []string{"/api/getprofileposts/{uuid}"}},
+
+		// Follows
+		{Method: "GET", Path: v1("/users/{uuid}/followers"), Auth: router.Optional, Handler: withDB(db, GetFollowersHandler), Legacy: []string{"/api/followers/{uuid}"}},
+		{Method: "GET", Path: v1("/users/{uuid}/following"), Auth: router.Optional, Handler: withDB(db, GetFollowingHandler), Legacy: []string{"/api/following/{uuid}"}},
+		{Method: "GET", Path: v1("/users/{uuid}/follow"), Auth: router.Required, Handler: withDB(db, FollowStatusHandler), Legacy: []string{"/api/follow/status/{uuid}"}},
+		{Method: "POST", Path: v1("/users/{uuid}/follow"), Auth: router.Required, Handler: withDB(db, FollowHandler), Legacy: []string{"/api/follow/{uuid}"}},
+		{Method: "DELETE", Path: v1("/users/{uuid}/follow"), Auth: router.Required, Handler: withDB(db, FollowHandler), Legacy: []string{"/api/follow/{uuid}"}},
+		{Method: "POST", Path: v1("/follow-requests"), Auth: router.Required, Handler: withDB(db, FollowRequestHandler), Legacy: []string{"/api/follow_requests"}},
+
+		// Posts and comments
+		{Method: "GET", Path: v1("/feed"), Auth: router.Required, Handler: withDBErr(db, withPreview(GetFeedPostsHandler)), Legacy: []string{"/api/getfeedposts"}},
+		{Method: "GET", Path: v1("/posts"), Auth: router.Required, Handler: withDBErr(db, withPreview(GetFeedPostsHandler)), Legacy: []string{"/api/posts"}},
+		{Method: "GET", Path: v1("/tags"), Auth: router.Required, Handler: withDB(db, GetTagsHandler), Legacy: []string{"/api/tags"}},
+		{Method: "POST", Path: v1("/posts"), Auth: router.Required, Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+			_ = CreatePostHandler(db, maxFormBytes, w, r)
+		}), Legacy: []string{"/api/createposts"}},
+		{Method: "POST", Path: v1("/posts/{uuid}/comments"), Auth: router.Required, Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+			_ = CreateCommentHandler(db, maxFormBytes, w, r)
+		}), Legacy: []string{"/api/createcomment"}},
+		{Method: "GET", Path: v1("/posts/{uuid}/comments"), Auth: router.Required, Handler: withDB(db, GetCommentsHandler), Legacy: []string{"/api/posts/{uuid}/comments"}},
+		{Method: "PUT", Path: v1("/posts/{uuid}"), Auth: router.Required, Handler: withDB(db, EditPostHandler)},
+		{Method: "DELETE", Path: v1("/posts/{uuid}"), Auth: router.Required, Handler: withDB(db, DeletePostHandler)},
+		{Method: "GET", Path: v1("/posts/{uuid}/revisions"), Auth: router.Required, Handler: withDB(db, GetPostRevisionsHandler)},
+		{Method: "POST", Path: v1("/posts/{uuid}/reactions"), Auth: router.Required, Handler: withDB(db, ReactToPostHandler)},
+		{Method: "PUT", Path: v1("/comments/{id}"), Auth: router.Required, Handler: withDB(db, EditCommentHandler)},
+		{Method: "DELETE", Path: v1("/comments/{id}"), Auth: router.Required, Handler: withDB(db, DeleteCommentHandler)},
+		{Method: "GET", Path: v1("/comments/{id}/revisions"), Auth: router.Required, Handler: withDB(db, GetCommentRevisionsHandler)},
+		{Method: "POST", Path: v1("/comments/{id}/reactions"), Auth: router.Required, Handler: withDB(db, ReactToCommentHandler)},
+
+		// Groups
+		{Method: "GET", Path: v1("/groups"), Auth: router.Optional, Legacy: []string{"/api/groups"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) { getAllGroups(w, r, db) })},
+		{Method: "POST", Path: v1("/groups"), Auth: router.Required, Legacy: []string{"/api/groups"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) { createGroup(w, r, db, maxFormBytes) })},
+		{Method: "GET", Path: v1("/groups/my-groups"), Auth: router.Required, Legacy: []string{"/api/groups/my-groups"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) { getMyGroups(w, r, db) })},
+		{Method: "GET", Path: v1("/groups/{id}"), Auth: router.Optional, Legacy: []string{"/api/groups/{id}"},
+			Handler: withGroupID(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, id int) { getGroupByID(w, db, id) })},
+		{Method: "POST", Path: v1("/groups/{id}/invite"), Auth: router.Required, Legacy: []string{"/api/groups/{id}/invite"},
+			Handler: withGroupID(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, id int) { inviteToGroup(w, r, db, id) })},
+		{Method: "POST", Path: v1("/groups/{id}/request-join"), Auth: router.Required, Legacy: []string{"/api/groups/{id}/request-join"},
+			Handler: withGroupID(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, id int) { requestToJoinGroup(w, r, db, id) })},
+		{Method: "POST", Path: v1("/groups/{id}/membership/{userID}"), Auth: router.Required, Legacy: []string{"/api/groups/{id}/membership/{userID}"},
+			Handler: withGroupID(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, id int) {
+				userID := parseUserID(w, r.PathValue("userID"))
+				if userID == -1 {
+					return
+				}
+				updateMembershipStatus(w, r, db, id, userID)
+			})},
+		{Method: "GET", Path: v1("/groups/{id}/members"), Auth: router.Optional, Legacy: []string{"/api/groups/{id}/members"},
+			Handler: withGroupID(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, id int) { getGroupMembers(w, db, id) })},
+		{Method: "GET", Path: v1("/groups/{id}/requests"), Auth: router.Required, Legacy: []string{"/api/groups/{id}/requests"},
+			Handler: withGroupID(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, id int) { getJoinRequests(w, r, db, id) })},
+		{Method: "GET", Path: v1("/groups/{id}/events"), Auth: router.Optional, Legacy: []string{"/api/groups/{id}/events"},
+			Handler: withGroupID(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, id int) {
+				getGroupEventsInGroups(w, db, id)
+			})},
+		{Method: "POST", Path: v1("/groups/{id}/events"), Auth: router.Required, Legacy: []string{"/api/groups/{id}/events"},
+			Handler: withGroupID(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, id int) {
+				createGroupEventInGroups(w, r, db, id)
+			})},
+		{Method: "GET", Path: v1("/groups/{id}/posts"), Auth: router.Required, Handler: withDBErr(db, withPreview(GetGroupPostsHandler)), Legacy: []string{"/api/getgroupposts/{id}"}},
+		{Method: "GET", Path: v1("/invitations"), Auth: router.Required,
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) { getInvitations(w, r, db) })},
+
+		// Events
+		{Method: "GET", Path: v1("/events"), Auth: router.Optional, Legacy: []string{"/api/events"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) { getAllUserEvents(w, r, db) })},
+		{Method: "GET", Path: v1("/events/{id}"), Auth: router.Optional, Legacy: []string{"/api/events/{id}"},
+			Handler: withEventID(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, id int) { getEventByIDGeneral(w, db, id) })},
+		{Method: "POST", Path: v1("/events/{id}/rsvp"), Auth: router.Required, Legacy: []string{"/api/events/{id}/rsvp"},
+			Handler: withEventID(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, id int) {
+				respondToEventGeneral(w, r, db, id)
+			})},
+		{Method: "GET", Path: v1("/events/{id}/rsvps"), Auth: router.Optional, Legacy: []string{"/api/events/{id}/rsvps"},
+			Handler: withEventID(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, id int) { getEventRSVPsGeneral(w, db, id) })},
+
+		// Notifications
+		{Method: "GET", Path: v1("/notifications"), Auth: router.Required, Legacy: []string{"/api/notifications"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				handleGetNotifications(w, dbTools.NewNotificationService(db), auth.UserID(r))
+			})},
+		{Method: "DELETE", Path: v1("/notifications"), Auth: router.Required, Legacy: []string{"/api/notifications"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				handleClearNotifications(w, dbTools.NewNotificationService(db), auth.UserID(r))
+			})},
+		{Method: "POST", Path: v1("/notifications/{id}"), Auth: router.Required, Legacy: []string{"/api/notifications/{id}"},
+			Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+				handleMarkAsRead(w, r, dbTools.NewNotificationService(db), auth.UserID(r))
+			})},
+
+		// Chat
+		{Method: "GET", Path: v1("/messages/{chat}"), Auth: router.Required, Handler: withDB(db, MessageHandler), Legacy: []string{"/api/messages/{chat}"}},
+		{Method: "GET", Path: v1("/ws"), Auth: router.Required, Handler: withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) { WebSocketsHandler(db, trusted, w, r) }), Legacy: []string{"/api/ws"}},
+
+		// Administration
+		{Method: "GET", Path: v1("/admin/login-attempts"), Auth: router.Required, Handler: withDB(db, AdminLoginAttemptsHandler), Legacy: []string{"/api/admin/login-attempts"}},
+		{Method: "GET", Path: v1("/admin/account-deletions"), Auth: router.Required, Handler: withDB(db, AdminAccountDeletionsHandler), Legacy: []string{"/api/admin/account-deletions"}},
+		{Method: "PUT", Path: v1("/admin/users/{uuid}/role"), Auth: router.Required, Legacy: []string{"/api/admin/users/{uuid}/role"},
+			Handler: withPermission(db, auth.PermManageRoles, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, p *auth.Principal) {
+				setUserRole(w, r, db, p, r.PathValue("uuid"))
+			})},
+		{Method: "GET", Path: v1("/admin/groups/{id}/moderators"), Auth: router.Required, Legacy: []string{"/api/admin/groups/{id}/moderators"},
+			Handler: withPermission(db, auth.PermManageRoles, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, p *auth.Principal) {
+				if id := parseGroupID(w, r.PathValue("id")); id != -1 {
+					listGroupModerators(w, db, id)
+				}
+			})},
+		{Method: "PUT", Path: v1("/admin/groups/{id}/moderators/{uuid}"), Auth: router.Required, Legacy: []string{"/api/admin/groups/{id}/moderators/{uuid}"},
+			Handler: withPermission(db, auth.PermManageRoles, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, p *auth.Principal) {
+				if id := parseGroupID(w, r.PathValue("id")); id != -1 {
+					assignGroupModerator(w, db, p, id, r.PathValue("uuid"))
+				}
+			})},
+		{Method: "DELETE", Path: v1("/admin/groups/{id}/moderators/{uuid}"), Auth: router.Required, Legacy: []string{"/api/admin/groups/{id}/moderators/{uuid}"},
+			Handler: withPermission(db, auth.PermManageRoles, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request, p *auth.Principal) {
+				if id := parseGroupID(w, r.PathValue("id")); id != -1 {
+					removeGroupModerator(w, db, id, r.PathValue("uuid"))
+				}
+			})},
+	}
+}
+
+// withDB adapts the package's handlers, which take the database first. The
+// handler gets the database bound to the request, so what dbTools logs
+// carries the request ID.
+func withDB(db *dbTools.DB, h func(*dbTools.DB, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) { h(db.WithContext(r.Context()), w, r) }
+}
+
+// withAccounts adapts the account handlers, which send mail and apply the account settings
+func withAccounts(db *dbTools.DB, acc Accounts, h func(*dbTools.DB, Accounts, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) { h(db.WithContext(r.Context()), acc, w, r) }
+}
+
+// withDBErr adapts the post handlers. They write their own error responses;
+// the returned error is only informational.
+func withDBErr(db *dbTools.DB, h func(*dbTools.DB, http.ResponseWriter, *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) { _ = h(db.WithContext(r.Context()), w, r) }
+}
+
+// withGroupID parses the {id} path value as a group ID
+func withGroupID(db *dbTools.DB, h func(*dbTools.DB, http.ResponseWriter, *http.Request, int)) http.HandlerFunc {
+	return withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+		groupID := parseGroupID(w, r.PathValue("id"))
+		if groupID == -1 {
+			return
+		}
+		h(db, w, r, groupID)
+	})
+}
+
+// withEventID parses the {id} path value as an event ID
+func withEventID(db *dbTools.DB, h func(*dbTools.DB, http.ResponseWriter, *http.Request, int)) http.HandlerFunc {
+	return withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+		eventID, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid event ID")
+			return
+		}
+		h(db, w, r, eventID)
+	})
+}
+
+// withProvider looks up the {provider} path value among the configured login providers
+func withProvider(db *dbTools.DB, providers *oidc.Registry, h func(*dbTools.DB, http.ResponseWriter, *http.Request, *oidc.Provider)) http.HandlerFunc {
+	return withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+		provider := providers.Get(r.PathValue("provider"))
+		if provider == nil {
+			utils.SendErrorResponse(w, http.StatusNotFound, "Unknown login provider")
+			return
+		}
+		h(db, w, r, provider)
+	})
+}
+
+// withPermission lets the request through only if the principal holds a site-wide permission
+func withPermission(db *dbTools.DB, perm auth.Permission, h func(*dbTools.DB, http.ResponseWriter, *http.Request, *auth.Principal)) http.HandlerFunc {
+	return withDB(db, func(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+		principal, ok := requirePermission(w, r, perm)
+		if !ok {
+			return
+		}
+		h(db, w, r, principal)
+	})
+}
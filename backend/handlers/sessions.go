@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"social_network/dbTools"
+	"social_network/utils"
+	"strings"
+)
+
+const maxSessionNameLength = 64
+
+func listSessions(w http.ResponseWriter, r *http.Request, db *dbTools.DB, userID int) {
+	sessions, err := db.GetActiveSessions(userID, utils.GetSessionUUID(r))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Sessions fetch failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch sessions")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"sessions": sessions})
+}
+
+func renameSession(w http.ResponseWriter, r *http.Request, db *dbTools.DB, userID int, publicUUID string) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	name := utils.Sanitize(strings.TrimSpace(req.Name))
+	if len(name) > maxSessionNameLength {
+		var fields utils.FieldErrors
+		fields.Add("name", utils.FieldTooLong, "Session name too long")
+		utils.SendError(w, fields.Err())
+		return
+	}
+
+	found, err := db.RenameSession(userID, publicUUID, name)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Session rename failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to rename session")
+		return
+	}
+	if !found {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Session renamed"})
+}
+
+func revokeSession(w http.ResponseWriter, r *http.Request, db *dbTools.DB, userID int, publicUUID string) {
+	sessionUUID, err := db.RevokeSession(userID, publicUUID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Session revoke failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to revoke session")
+		return
+	}
+	if sessionUUID == "" {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Session not found")
+		return
+	}
+
+	// Revoking the current session is a logout
+	if sessionUUID == utils.GetSessionUUID(r) {
+		utils.ClearSessionCookie(w)
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Session revoked"})
+}
+
+func revokeOtherSessions(w http.ResponseWriter, r *http.Request, db *dbTools.DB, userID int) {
+	revoked, err := db.RevokeOtherSessions(userID, utils.GetSessionUUID(r))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Revoke other sessions failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to revoke sessions")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{
+		"message": "Other sessions revoked",
+		"revoked": revoked,
+	})
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"sync"
+)
+
+// background tracks work handlers start after responding, such as sending mail
+// and creating notifications, so shutdown can wait for it
+var background sync.WaitGroup
+
+// goBackground runs f in a goroutine that Drain waits for
+func goBackground(f func()) {
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		f()
+	}()
+}
+
+// Drain is called once the HTTP server has stopped accepting requests. It asks
+// every websocket client to disconnect, then waits for the connections to
+// finish the message they are handling and for background work to complete.
+// If ctx expires first, the remaining connections are closed and ctx.Err() is returned.
+func Drain(ctx context.Context) error {
+	closeWebSockets()
+
+	done := make(chan struct{})
+	go func() {
+		wsHandlers.Wait()
+		background.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		dropWebSockets()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"social_network/dbTools"
+	"social_network/utils"
+	"strconv"
+	"time"
+)
+
+const (
+	// pending2FALoginTTL is how long a user has to enter their code after the password step
+	pending2FALoginTTL = 5 * time.Minute
+	// maxPending2FAAttempts wrong codes burn the pending login token
+	maxPending2FAAttempts = 5
+	recoveryCodeCount     = 10
+	// pending2FACookieName carries the pending token of a social login, which
+	// reaches the frontend through a redirect rather than a response body
+	pending2FACookieName = "pending_2fa"
+)
+
+type TwoFactorLoginRequest struct {
+	PendingToken string `json:"pending_token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type twoFactorCodeRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// startTwoFactorLogin issues the pending 2FA token returned by LoginHandler
+// to users that have two-factor authentication enabled
+func startTwoFactorLogin(db *dbTools.DB, w http.ResponseWriter, userID int) {
+	token, err := newPending2FALogin(db, userID)
+	if err != nil {
+		slog.ErrorContext(db.Context(), "Pending 2FA login failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Login failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":             false,
+		"two_factor_required": true,
+		"pending_token":       token,
+		"expires_in":          int(pending2FALoginTTL.Seconds()),
+		"message":             "Two-factor authentication required",
+	})
+}
+
+// newPending2FALogin creates a pending 2FA login for the user and returns its token
+func newPending2FALogin(db *dbTools.DB, userID int) (string, error) {
+	token, err := utils.GenerateToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := db.CreatePending2FALogin(userID, utils.HashToken(token), time.Now().Add(pending2FALoginTTL)); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// setPending2FACookie hands the pending token to the browser without putting
+// it in a URL, where it would end up in history, logs and Referer headers
+func setPending2FACookie(w http.ResponseWriter, token string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     pending2FACookieName,
+		Value:    token,
+		Path:     "/api/",
+		MaxAge:   int(pending2FALoginTTL.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// LoginTwoFactorHandler completes a login started by LoginHandler or a social
+// login for a user with 2FA enabled. It expects the pending token, in the body
+// or the pending_2fa cookie, and either a TOTP code or a recovery code.
+func LoginTwoFactorHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	var req TwoFactorLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if cookie, err := r.Cookie(pending2FACookieName); err == nil && req.PendingToken == "" {
+		req.PendingToken = cookie.Value
+	}
+	if req.PendingToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Pending token and code are required")
+		return
+	}
+
+	tokenHash := utils.HashToken(req.PendingToken)
+	userID, _, err := db.GetPending2FALogin(tokenHash)
+	if err == sql.ErrNoRows {
+		utils.SendErrorResponse(w, http.StatusUnauthorized, "Login expired, please sign in again")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Pending 2FA lookup failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Login failed")
+		return
+	}
+
+	user, _, err := fetchLoginUser(db, "user_id", userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "2FA user lookup failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusUnauthorized, "Login expired, please sign in again")
+		return
+	}
+
+	// Wrong codes count as failed logins, so the usual throttling applies here too
+	if err := utils.CheckLoginAllowed(db.GetDB(), user.Email, utils.ClientIP(r)); err != nil {
+		var blocked *utils.LoginBlockedError
+		if !errors.As(err, &blocked) {
+			slog.ErrorContext(r.Context(), "Login guard failed", "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Login failed")
+			return
+		}
+		utils.RecordLoginAttempt(db.GetDB(), r, user.Email, user.UserID, false, blocked.Reason())
+		w.Header().Set("Retry-After", strconv.Itoa(blocked.RetryAfterSeconds()))
+		utils.SendError(w, utils.NewAPIError(http.StatusTooManyRequests, utils.CodeLoginBlocked, blocked.Error()))
+		return
+	}
+
+	ok, err := checkSecondFactor(db, userID, req.Code, req.RecoveryCode)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "2FA check failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Login failed")
+		return
+	}
+	if !ok {
+		if err := db.RecordPending2FAFailure(tokenHash, maxPending2FAAttempts); err != nil {
+			slog.ErrorContext(r.Context(), "Failed to record pending 2FA failure", "err", err)
+		}
+		utils.RecordLoginAttempt(db.GetDB(), r, user.Email, user.UserID, false, utils.LoginFailureInvalidCredentials)
+		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid authentication code")
+		return
+	}
+
+	// The token is single use; losing this race means another request already logged in with it
+	consumed, err := db.ConsumePending2FALogin(tokenHash)
+	http.SetCookie(w, &http.Cookie{Name: pending2FACookieName, Path: "/api/", MaxAge: -1, HttpOnly: true})
+	if err != nil || !consumed {
+		utils.SendErrorResponse(w, http.StatusUnauthorized, "Login expired, please sign in again")
+		return
+	}
+
+	completeLogin(db, w, r, user)
+}
+
+func twoFactorStatus(w http.ResponseWriter, db *dbTools.DB, userID int) {
+	totp, err := db.GetTOTP(userID)
+	if err != nil {
+		slog.ErrorContext(db.Context(), "2FA status failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch 2FA status")
+		return
+	}
+
+	enabled := totp != nil && totp.Status == "enabled"
+	remaining := 0
+	if enabled {
+		if remaining, err = db.CountUnusedRecoveryCodes(userID); err != nil {
+			slog.ErrorContext(db.Context(), "Recovery code count failed", "user_id", userID, "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch 2FA status")
+			return
+		}
+	}
+
+	response := map[string]interface{}{
+		"enabled":                  enabled,
+		"pending":                  totp != nil && totp.Status == "pending",
+		"recovery_codes_remaining": remaining,
+	}
+	if enabled {
+		response["enabled_at"] = totp.EnabledAt
+	}
+	utils.SendSuccessResponse(w, response)
+}
+
+func enrollTwoFactor(w http.ResponseWriter, db *dbTools.DB, userID int) {
+	user, _, err := fetchLoginUser(db, "user_id", userID)
+	if err != nil {
+		slog.ErrorContext(db.Context(), "2FA enroll user lookup failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to start enrollment")
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		slog.ErrorContext(db.Context(), "2FA secret generation failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to start enrollment")
+		return
+	}
+
+	err = db.SavePendingTOTP(userID, secret)
+	if errors.Is(err, dbTools.ErrTOTPAlreadyEnabled) {
+		utils.SendErrorResponse(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(db.Context(), "2FA enroll failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to start enrollment")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{
+		"secret":      secret,
+		"otpauth_uri": utils.TOTPURI(user.Email, secret),
+		"message":     "Scan the code with your authenticator app, then confirm with a code",
+	})
+}
+
+func verifyTwoFactor(w http.ResponseWriter, r *http.Request, db *dbTools.DB, userID int) {
+	var req twoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	totp, err := db.GetTOTP(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "2FA verify failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to verify code")
+		return
+	}
+	if totp == nil || totp.Status != "pending" {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "No enrollment in progress")
+		return
+	}
+
+	step, ok := utils.ValidateTOTP(totp.Secret, req.Code, time.Now())
+	if !ok {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid authentication code")
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Recovery code generation failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to verify code")
+		return
+	}
+	if err := db.EnableTOTP(userID, step, hashes); err != nil {
+		slog.ErrorContext(r.Context(), "2FA enable failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to enable two-factor authentication")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{
+		"recovery_codes": codes,
+		"message":        "Two-factor authentication enabled. Store these recovery codes somewhere safe, they are only shown once",
+	})
+}
+
+func disableTwoFactor(w http.ResponseWriter, r *http.Request, db *dbTools.DB, userID int) {
+	if !confirmTwoFactorChange(w, r, db, userID) {
+		return
+	}
+
+	if err := db.DisableTOTP(userID); err != nil {
+		slog.ErrorContext(r.Context(), "2FA disable failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to disable two-factor authentication")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{"message": "Two-factor authentication disabled"})
+}
+
+func regenerateRecoveryCodes(w http.ResponseWriter, r *http.Request, db *dbTools.DB, userID int) {
+	if !confirmTwoFactorChange(w, r, db, userID) {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Recovery code generation failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+	if err := db.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		slog.ErrorContext(r.Context(), "Recovery code replace failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to generate recovery codes")
+		return
+	}
+
+	utils.SendSuccessResponse(w, map[string]interface{}{
+		"recovery_codes": codes,
+		"message":        "New recovery codes generated, the old ones no longer work",
+	})
+}
+
+// confirmTwoFactorChange checks the password and a second factor before 2FA
+// settings are weakened, and writes an error response if either is wrong
+func confirmTwoFactorChange(w http.ResponseWriter, r *http.Request, db *dbTools.DB, userID int) bool {
+	var req twoFactorCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+
+	enabled, err := db.IsTOTPEnabled(userID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "2FA status failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to check two-factor authentication")
+		return false
+	}
+	if !enabled {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return false
+	}
+
+	// Wrong passwords and codes count as failed logins, so a stolen session
+	// cannot be used to guess them past the login throttling and lockout
+	user, ok := reauthenticate(w, r, db, userID, req.Password)
+	if !ok {
+		return false
+	}
+
+	ok, err = checkSecondFactor(db, userID, req.Code, req.RecoveryCode)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "2FA check failed", "user_id", userID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to check authentication code")
+		return false
+	}
+	if !ok {
+		utils.RecordLoginAttempt(db.GetDB(), r, user.Email, user.UserID, false, utils.LoginFailureInvalidCredentials)
+		utils.SendErrorResponse(w, http.StatusUnauthorized, "Invalid authentication code")
+		return false
+	}
+	return true
+}
+
+// checkSecondFactor validates a TOTP code, or a recovery code if no TOTP code
+// is given. Used codes are recorded so they cannot be replayed.
+func checkSecondFactor(db *dbTools.DB, userID int, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		totp, err := db.GetTOTP(userID)
+		if err != nil || totp == nil || totp.Status != "enabled" {
+			return false, err
+		}
+		step, ok := utils.ValidateTOTP(totp.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return db.MarkTOTPStepUsed(userID, step)
+	}
+
+	if recoveryCode != "" {
+		return db.UseRecoveryCode(userID, utils.HashToken(utils.NormalizeRecoveryCode(recoveryCode)))
+	}
+	return false, nil
+}
+
+// newRecoveryCodes returns fresh recovery codes and the hashes to store for them
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = utils.HashToken(utils.NormalizeRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
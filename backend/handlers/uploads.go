@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"social_network/auth"
+	"social_network/dbTools"
+	"social_network/utils"
+)
+
+// UploadsHandler serves uploaded files. Files attached to posts and comments are
+// only served to users who can see the post; everything else is public.
+func UploadsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
+	filename := r.PathValue("file")
+	post, attached, err := db.GetUploadPost(r.Context(), filename)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Upload lookup failed", "file", filename, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+
+	if attached {
+		visible := false
+		if post != nil {
+			visible, err = db.CanViewPost(r.Context(), post, auth.UserID(r))
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Upload visibility check failed", "file", filename, "err", err)
+				utils.SendErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+				return
+			}
+		}
+		if !visible {
+			utils.SendErrorResponse(w, http.StatusNotFound, "Not found")
+			return
+		}
+		// Shared caches must not hand the file to someone else
+		w.Header().Set("Cache-Control", "private")
+	}
+
+	// Only regular files are served; directory listings would name hidden attachments
+	dir := http.Dir(db.UploadsDir())
+	f, err := dir.Open("/" + filename)
+	if err != nil {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		utils.SendErrorResponse(w, http.StatusNotFound, "Not found")
+		return
+	}
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
@@ -2,13 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
 	"social_network/dbTools"
-	"social_network/middleware"
 	"social_network/utils"
 	"strconv"
-	"strings"
 )
 
 // BatchUserRequest represents a request for multiple users
@@ -18,7 +16,6 @@ type BatchUserRequest struct {
 
 // UserByIDHandler fetches a user by user_id
 func UserByIDHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	middleware.SetCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
@@ -28,9 +25,7 @@ func UserByIDHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract user_id from URL path
-	path := strings.TrimPrefix(r.URL.Path, "/api/users/")
-	userIDStr := strings.Split(path, "/")[0]
+	userIDStr := r.PathValue("id")
 	if userIDStr == "" || userIDStr == "batch" {
 		utils.SendErrorResponse(w, http.StatusBadRequest, "Missing user ID")
 		return
@@ -42,17 +37,10 @@ func UserByIDHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify session for access control
-	_, err = utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Authentication required")
-		return
-	}
-
 	// Fetch user data
 	user, err := db.FetchUserByID(userID)
 	if err != nil {
-		log.Printf("User fetch error for user_id %d: %v", userID, err)
+		slog.ErrorContext(r.Context(), "User fetch failed", "user_id", userID, "err", err)
 		utils.SendErrorResponse(w, http.StatusNotFound, "User not found")
 		return
 	}
@@ -63,7 +51,6 @@ func UserByIDHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 
 // BatchUsersHandler fetches multiple users by their IDs
 func BatchUsersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	middleware.SetCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
@@ -73,17 +60,9 @@ func BatchUsersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Verify session for access control
-	_, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		utils.SendErrorResponse(w, http.StatusUnauthorized, "Authentication required")
-		return
-	}
-
 	// Parse request body
 	var req BatchUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		log.Printf("Invalid request body: %v", err)
 		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid request body")
 		return
 	}
@@ -101,7 +80,7 @@ func BatchUsersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 	// Fetch users
 	users, err := db.FetchUsersByIDs(req.UserIDs)
 	if err != nil {
-		log.Printf("Batch user fetch error: %v", err)
+		slog.ErrorContext(r.Context(), "Batch user fetch failed", "err", err)
 		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch users")
 		return
 	}
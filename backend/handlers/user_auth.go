@@ -1,16 +1,19 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"social_network/auth"
 	"social_network/dbTools"
 	"social_network/middleware"
 	"social_network/utils"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,8 +26,10 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	Success bool         `json:"success"`
-	User    dbTools.User `json:"user,omitempty"`
+	Success                   bool         `json:"success"`
+	User                      dbTools.User `json:"user,omitempty"`
+	EmailVerificationRequired bool         `json:"email_verification_required,omitempty"`
+	AccountReactivated        bool         `json:"account_reactivated,omitempty"`
 }
 
 type SessionResponse struct {
@@ -32,96 +37,152 @@ type SessionResponse struct {
 	User    *dbTools.User `json:"user,omitempty"`
 }
 
+// errInvalidCredentials answers both an unknown email and a wrong password, so
+// the response does not tell which accounts exist
+var errInvalidCredentials = utils.NewAPIError(http.StatusUnauthorized, utils.CodeInvalidCredentials, "Invalid email or password")
+
 // LoginHandler handles user login
 func LoginHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	middleware.SetCORSHeaders(w)
+	var loginReq LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&loginReq); err != nil {
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Invalid input")
+		return
+	}
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
+	var fields utils.FieldErrors
+	fields.Check("email", utils.ValidateEmail(loginReq.Email))
+	fields.Check("password", utils.ValidatePassword(loginReq.Password))
+	if err := fields.Err(); err != nil {
+		utils.SendError(w, err)
 		return
 	}
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+
+	// Reject throttled or locked-out attempts before touching bcrypt
+	if err := utils.CheckLoginAllowed(db.GetDB(), loginReq.Email, utils.ClientIP(r)); err != nil {
+		var blocked *utils.LoginBlockedError
+		if !errors.As(err, &blocked) {
+			slog.ErrorContext(r.Context(), "Login guard failed", "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Login failed")
+			return
+		}
+		utils.RecordLoginAttempt(db.GetDB(), r, loginReq.Email, 0, false, blocked.Reason())
+		w.Header().Set("Retry-After", strconv.Itoa(blocked.RetryAfterSeconds()))
+		utils.SendError(w, utils.NewAPIError(http.StatusTooManyRequests, utils.CodeLoginBlocked, blocked.Error()))
 		return
 	}
 
-	var loginReq LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&loginReq); err != nil {
-		http.Error(w, "Invalid input", http.StatusBadRequest)
+	// Find user by email
+	user, hashedPassword, err := fetchLoginUser(db, "email", loginReq.Email)
+	if err != nil {
+		// Unknown addresses are expected; the address itself is never logged
+		slog.InfoContext(r.Context(), "Login failed: no matching user", "err", err)
+		utils.RecordLoginAttempt(db.GetDB(), r, loginReq.Email, 0, false, utils.LoginFailureInvalidCredentials)
+		utils.SendError(w, errInvalidCredentials)
 		return
 	}
 
-	if err := utils.ValidateEmail(loginReq.Email); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// Verify password
+	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(loginReq.Password)); err != nil {
+		slog.InfoContext(r.Context(), "Login failed: wrong password", "user_id", user.UserID)
+		utils.RecordLoginAttempt(db.GetDB(), r, loginReq.Email, user.UserID, false, utils.LoginFailureInvalidCredentials)
+		utils.SendError(w, errInvalidCredentials)
 		return
 	}
 
-	if err := utils.ValidatePassword(loginReq.Password); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	// With 2FA enabled the password only earns a short-lived pending token;
+	// the session is created by LoginTwoFactorHandler once the code checks out.
+	twoFactor, err := db.IsTOTPEnabled(user.UserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "2FA status failed", "user_id", user.UserID, "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Login failed")
 		return
 	}
-	// Find user by email
+	if twoFactor {
+		startTwoFactorLogin(db, w, user.UserID)
+		return
+	}
+
+	completeLogin(db, w, r, user)
+}
+
+// fetchLoginUser loads a user that may sign in, and their password hash, by email or user_id.
+// Accounts waiting for email verification can sign in but stay read-only.
+// Deactivated accounts can sign in, which reactivates them; erased ones cannot.
+func fetchLoginUser(db *dbTools.DB, column string, value interface{}) (dbTools.User, string, error) {
 	var user dbTools.User
 	var hashedPassword string
 	query := `SELECT user_id, user_uuid, email, password, first_name, last_name, date_of_birth,
 	          COALESCE(nickname, '') as nickname, COALESCE(about_me, '') as about_me,
-	          COALESCE(avatar, '') as avatar, privacy, role, created_at, updated_at
-	          FROM users WHERE email = ? AND status = 'active'`
+	          COALESCE(avatar, '') as avatar, privacy, role, status, created_at, updated_at
+	          FROM users WHERE ` + column + ` = ? AND status IN ('active', 'pending_verification', 'inactive') AND deleted_at IS NULL`
 
-	err := db.QueryRow(query, loginReq.Email).Scan(
+	err := db.QueryRow(query, value).Scan(
 		&user.UserID, &user.UserUUID, &user.Email, &hashedPassword,
 		&user.FirstName, &user.LastName, &user.DateOfBirth, &user.Nickname,
-		&user.AboutMe, &user.Avatar, &user.Privacy, &user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.AboutMe, &user.Avatar, &user.Privacy, &user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt,
 	)
+	return user, hashedPassword, err
+}
 
-	if err != nil {
-		fmt.Printf("User lookup error: %v\n", err)
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+// completeLogin creates the session for an authenticated user and writes the login response
+func completeLogin(db *dbTools.DB, w http.ResponseWriter, r *http.Request, user dbTools.User) {
+	_, err := utils.CreateSession(db.GetDB(), db.Sessions(), w, r, int64(user.UserID))
+	if errors.Is(err, utils.ErrAccountLocked) {
+		utils.RecordLoginAttempt(db.GetDB(), r, user.Email, user.UserID, false, utils.LoginFailureLocked)
+		utils.SendError(w, utils.NewAPIError(http.StatusTooManyRequests, utils.CodeLoginBlocked, "Too many failed login attempts, try again later"))
 		return
 	}
-
-	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(loginReq.Password)); err != nil {
-		fmt.Printf("Password verification failed: %v\n", err)
-		http.Error(w, "Invalid email or password", http.StatusUnauthorized)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Session creation failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Session creation failed")
 		return
 	}
+	utils.RecordLoginAttempt(db.GetDB(), r, user.Email, user.UserID, true, "")
 
-	// Create session
-	_, err = utils.CreateSession(db.GetDB(), w, int64(user.UserID))
+	reactivated, err := reactivateOnLogin(db, &user)
 	if err != nil {
-		fmt.Printf("Session creation error: %v\n", err)
-		http.Error(w, "Session creation failed", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Reactivation failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Login failed")
 		return
 	}
 
 	// Return success response
 	response := LoginResponse{
-		Success: true,
-		User:    user,
+		Success:                   true,
+		User:                      user,
+		EmailVerificationRequired: user.Status == "pending_verification",
+		AccountReactivated:        reactivated,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-// RegisterHandler handles user registration
-func RegisterHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	middleware.SetCORSHeaders(w)
-
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(http.StatusOK)
-		return
+// reactivateOnLogin reactivates a deactivated account, cancelling a scheduled
+// deletion, and refreshes the user's status
+func reactivateOnLogin(db *dbTools.DB, user *dbTools.User) (bool, error) {
+	if user.Status != "inactive" {
+		return false, nil
 	}
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+	reactivated, err := db.ReactivateUser(user.UserID)
+	if err != nil {
+		return false, err
 	}
+	status, err := db.GetUserStatus(user.UserID)
+	if err != nil {
+		return false, err
+	}
+	user.Status = status
+	return reactivated, nil
+}
 
+// RegisterHandler handles user registration.
+// New accounts start in pending_verification and receive a verification email.
+func RegisterHandler(db *dbTools.DB, acc Accounts, maxFormBytes int64, w http.ResponseWriter, r *http.Request) {
 	// Parse multipart form data
-	err := r.ParseMultipartForm(10 << 20) // 10MB max
+	err := r.ParseMultipartForm(maxFormBytes)
 	if err != nil {
-		http.Error(w, "Failed to parse form data", http.StatusBadRequest)
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Failed to parse form data")
 		return
 	}
 
@@ -145,53 +206,44 @@ func RegisterHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		AboutMe:   strings.TrimSpace(r.FormValue("aboutMe")),
 	}
 
-	// Validate required fields
-	if registerReq.Email == "" || registerReq.Password == "" || registerReq.FirstName == "" ||
-		registerReq.LastName == "" || registerReq.DOB == "" {
-		http.Error(w, "Missing required fields", http.StatusBadRequest)
-		return
-	}
-
-	// Validate email and password
-	if err := utils.ValidateEmail(registerReq.Email); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-	if err := utils.ValidatePassword(registerReq.Password); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
-
-	// Validate DOB format (YYYY-MM-DD)
-	if _, err := time.Parse("2006-01-02", registerReq.DOB); err != nil {
-		http.Error(w, "Invalid date of birth format (use YYYY-MM-DD)", http.StatusBadRequest)
+	// Validate every field at once, so the form can mark all the problems
+	var fields utils.FieldErrors
+	fields.Check("email", utils.ValidateEmail(registerReq.Email))
+	fields.Check("password", utils.ValidatePasswordStrength(registerReq.Password, acc.PasswordPolicy))
+	fields.Check("firstName", utils.ValidateRequired("First name", registerReq.FirstName))
+	fields.Check("lastName", utils.ValidateRequired("Last name", registerReq.LastName))
+	fields.Check("dob", utils.ValidateDate("Date of birth", registerReq.DOB))
+	if err := fields.Err(); err != nil {
+		utils.SendError(w, err)
 		return
 	}
 
-	// Check if email already exists
+	// Check if email already exists, in any account state
 	var count int
-	query := `SELECT COUNT(*) FROM users WHERE email = ? AND status = 'active'`
+	query := `SELECT COUNT(*) FROM users WHERE email = ?`
 	err = db.QueryRow(query, registerReq.Email).Scan(&count)
 	if err != nil {
-		fmt.Printf("Email check error: %v\n", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Email check failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 	if count > 0 {
-		http.Error(w, "Email already registered", http.StatusBadRequest)
-		return
+		fields.Add("email", utils.FieldTaken, "Email already registered")
 	}
 
 	// Check if nick name already exists
 	query = `SELECT COUNT(*) FROM users WHERE nickname = ? AND status = 'active'`
 	err = db.QueryRow(query, registerReq.Nickname).Scan(&count)
 	if err != nil {
-		fmt.Printf("Nickname check error: %v\n", err)
-		http.Error(w, "Database error", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Nickname check failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 	if count > 0 {
-		http.Error(w, "Nickname already registered", http.StatusBadRequest)
+		fields.Add("nickname", utils.FieldTaken, "Nickname already registered")
+	}
+	if err := fields.Err(); err != nil {
+		utils.SendError(w, err)
 		return
 	}
 
@@ -199,38 +251,41 @@ func RegisterHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 	file, handler, err := r.FormFile("avatar")
 	if err == nil {
 		// Create upload directory if it doesn't exist
-		uploadDir := "public/uploads"
+		uploadDir := db.UploadsDir()
 		if err := os.MkdirAll(uploadDir, 0755); err != nil {
-			fmt.Printf("Failed to create upload directory: %v\n", err)
-			http.Error(w, "Failed to save avatar", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Failed to create upload directory", "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to save avatar")
 			return
 		}
 
 		defer file.Close()
 		ext := strings.ToLower(filepath.Ext(handler.Filename))
 		if ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".gif" {
-			http.Error(w, "Invalid file type. Only JPEG, PNG, and GIF are allowed", http.StatusBadRequest)
+			fields.Add("avatar", utils.FieldFormat, "Invalid file type. Only JPEG, PNG, and GIF are allowed")
+			utils.SendError(w, fields.Err())
 			return
 		}
 		avatarUUID, err := utils.GenerateUUID()
 		if err != nil {
-			fmt.Printf("Avatar upload error: %v\n", err)
-			http.Error(w, "Failed to process avatar", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Avatar upload failed", "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to process avatar")
 			return
 		}
 		filename := avatarUUID + ext
 		dst, err := os.Create(filepath.Join(uploadDir, filename))
 		if err != nil {
-			fmt.Printf("Avatar save error: %v\n", err)
-			http.Error(w, "Failed to save avatar", http.StatusInternalServerError)
+			slog.ErrorContext(r.Context(), "Avatar save failed", "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to save avatar")
 			return
 		}
 		defer dst.Close()
-		if _, err := io.Copy(dst, file); err != nil {
-			fmt.Printf("Avatar copy error: %v\n", err)
-			http.Error(w, "Failed to save avatar", http.StatusInternalServerError)
+		written, err := io.Copy(dst, file)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Avatar copy failed", "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to save avatar")
 			return
 		}
+		dbTools.RecordUpload("avatar", written)
 		registerReq.Avatar = "/uploads/" + filename
 	} else {
 		// No avatar uploaded, assign default avatar
@@ -240,16 +295,16 @@ func RegisterHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(registerReq.Password), bcrypt.DefaultCost)
 	if err != nil {
-		fmt.Printf("Password hashing error: %v\n", err)
-		http.Error(w, "Failed to process password", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Password hashing failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to process password")
 		return
 	}
 
 	// Generate user UUID
 	userUUID, err := utils.GenerateUUID()
 	if err != nil {
-		fmt.Printf("User UUID generation error: %v\n", err)
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "User UUID generation failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to create user")
 		return
 	}
 
@@ -259,7 +314,7 @@ func RegisterHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		INSERT INTO users (
 			user_uuid, email, password, first_name, last_name, date_of_birth,
 			nickname, about_me, avatar, privacy, role, status, created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 'private', 'user', 'active', ?, ?)`
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 'private', 'user', 'pending_verification', ?, ?)`
 	result, err := db.Exec(
 		query,
 		userUUID, registerReq.Email, string(hashedPassword),
@@ -268,24 +323,24 @@ func RegisterHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		currentTime, currentTime,
 	)
 	if err != nil {
-		fmt.Printf("User insertion error: %v\n", err)
-		http.Error(w, "Failed to register user", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "User insertion failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to register user")
 		return
 	}
 
 	// Get the inserted user ID
 	userID, err := result.LastInsertId()
 	if err != nil {
-		fmt.Printf("Failed to get user ID: %v\n", err)
-		http.Error(w, "Failed to register user", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Failed to get user ID", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to register user")
 		return
 	}
 
 	// Create session
-	_, err = utils.CreateSession(db.GetDB(), w, int64(userID))
+	_, err = utils.CreateSession(db.GetDB(), db.Sessions(), w, r, int64(userID))
 	if err != nil {
-		fmt.Printf("Session creation error: %v\n", err)
-		http.Error(w, "Session creation failed", http.StatusInternalServerError)
+		slog.ErrorContext(r.Context(), "Session creation failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Session creation failed")
 		return
 	}
 
@@ -304,14 +359,21 @@ func RegisterHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		Avatar:      registerReq.Avatar,
 		Privacy:     "private",
 		Role:        "user",
+		Status:      "pending_verification",
 		CreatedAt:   currentTime.Format(time.RFC3339),
 		UpdatedAt:   currentTime.Format(time.RFC3339),
 	}
 
+	if _, err := sendVerificationEmail(db, acc, user); err != nil {
+		// The account exists either way; the user can ask for another email
+		slog.ErrorContext(r.Context(), "Verification email failed", "err", err)
+	}
+
 	// Return success response
 	response := LoginResponse{
-		Success: true,
-		User:    user,
+		Success:                   true,
+		User:                      user,
+		EmailVerificationRequired: true,
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
@@ -320,15 +382,9 @@ func RegisterHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 
 // LogoutHandler handles user logout
 func LogoutHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	middleware.SetCORSHeaders(w)
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	err := utils.ClearSession(db.GetDB(), w, r)
 	if err != nil {
-		http.Error(w, "Failed to logout", http.StatusInternalServerError)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to logout")
 		return
 	}
 
@@ -337,15 +393,8 @@ func LogoutHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 
 // SessionCheckHandler checks if user is logged in
 func SessionCheckHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	middleware.SetCORSHeaders(w)
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Use utility function to get user ID from session
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
+	userID := auth.UserID(r)
+	if userID == 0 {
 		w.Header().Set("Content-Type", "application/json")
 		// Always return 200 OK for session check
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -360,18 +409,18 @@ func SessionCheckHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
 	query := `
 		SELECT user_id, user_uuid, email, first_name, last_name, date_of_birth,
 		       COALESCE(nickname, '') as nickname, COALESCE(about_me, '') as about_me,
-		       COALESCE(avatar, '') as avatar, privacy, role, created_at, updated_at
+		       COALESCE(avatar, '') as avatar, privacy, role, status, created_at, updated_at
 		FROM users
-		WHERE user_id = ? AND status = 'active'
+		WHERE user_id = ? AND status IN ('active', 'pending_verification')
 	`
 
-	err = db.QueryRow(query, userID).Scan(
+	err := db.QueryRow(query, userID).Scan(
 		&user.UserID, &user.UserUUID, &user.Email, &user.FirstName, &user.LastName,
 		&user.DateOfBirth, &user.Nickname, &user.AboutMe, &user.Avatar, &user.Privacy,
-		&user.Role, &user.CreatedAt, &user.UpdatedAt,
+		&user.Role, &user.Status, &user.CreatedAt, &user.UpdatedAt,
 	)
 	if err != nil {
-		fmt.Printf("User fetch error: %v\n", err)
+		slog.ErrorContext(r.Context(), "User fetch failed", "err", err)
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
@@ -388,12 +437,12 @@ func SessionCheckHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
-// CurrentUserIDHandler returns the user ID of the currently authenticated user based on the session cookie
-func CurrentUserIDHandler(db *sql.DB) http.HandlerFunc {
+// CurrentUserIDHandler returns the user ID of the currently authenticated user
+func CurrentUserIDHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userID, err := utils.GetUserIDFromSession(db, r)
-		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to get user ID: %v", err), http.StatusUnauthorized)
+		userID := auth.UserID(r)
+		if userID == 0 {
+			middleware.WriteUnauthorized(w)
 			return
 		}
 		response := map[string]interface{}{
@@ -2,35 +2,26 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
+	"log/slog"
 	"net/http"
+	"social_network/auth"
 	"social_network/dbTools"
-	"social_network/middleware"
 	"social_network/utils"
-	"time"
 )
 
 // UsersHandler fetches all active users except the logged-in user
 func UsersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	log.Printf("UsersHandler called at %s for URL %s", time.Now().Format(time.RFC3339), r.URL.Path)
-	middleware.SetCORSHeaders(w)
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
 	if r.Method != "GET" {
-		w.WriteHeader(http.StatusMethodNotAllowed)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Method not allowed"})
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	// Get the logged-in user's ID from session
-	currentUserID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		log.Printf("No session found: %v", err)
-		// Allow unauthenticated users to see the list, or return unauthorized if required
-		currentUserID = 0 // Use 0 to indicate no logged-in user
-	}
+	// Unauthenticated users see the list too; 0 means no logged-in user
+	currentUserID := auth.UserID(r)
 
 	// Query for all active users except the logged-in user
 	query := `
@@ -42,9 +33,8 @@ func UsersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 	`
 	rows, err := db.Query(query, currentUserID)
 	if err != nil {
-		log.Printf("Users fetch error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Failed to fetch users"})
+		slog.ErrorContext(r.Context(), "Users fetch failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch users")
 		return
 	}
 	defer rows.Close()
@@ -57,15 +47,14 @@ func UsersHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 			&user.LastName, &user.Nickname, &user.Avatar,
 		)
 		if err != nil {
-			log.Printf("Row scan error: %v", err)
+			slog.ErrorContext(r.Context(), "Row scan failed", "err", err)
 			continue
 		}
 		users = append(users, user)
 	}
 	if err = rows.Err(); err != nil {
-		log.Printf("Rows iteration error: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "message": "Failed to fetch users"})
+		slog.ErrorContext(r.Context(), "Rows iteration failed", "err", err)
+		utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to fetch users")
 		return
 	}
 
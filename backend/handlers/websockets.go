@@ -3,9 +3,12 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"social_network/auth"
 	"social_network/dbTools"
+	"social_network/metrics"
+	"social_network/middleware"
 	"social_network/utils"
 	"strconv"
 	"strings"
@@ -45,28 +48,67 @@ var (
 	clients      = make(map[*websocket.Conn]int) // Connection -> UserID
 	groupsMutex  sync.RWMutex
 	allGroups    = make(map[string]map[*websocket.Conn]bool) // Each GroupID has a map of all connections. If a key is true, that means that that connection/client is part of the group
+
+	// wsHandlers counts running WebSocketsHandler calls; hijacked connections are not tracked by http.Server.Shutdown
+	wsHandlers sync.WaitGroup
+	// wsClosing is set by Drain; connections opened after that are turned away. Guarded by clientsMutex.
+	wsClosing bool
+)
+
+var (
+	_ = metrics.NewGaugeFunc("websocket_connections", "Open websocket connections.", func() float64 {
+		clientsMutex.RLock()
+		defer clientsMutex.RUnlock()
+		return float64(len(clients))
+	})
+	_ = metrics.NewGaugeFunc("websocket_group_rooms", "Group chats with at least one connected member.", func() float64 {
+		groupsMutex.RLock()
+		defer groupsMutex.RUnlock()
+		rooms := 0
+		for _, room := range allGroups {
+			if len(room) > 0 {
+				rooms++
+			}
+		}
+		return float64(rooms)
+	})
+	wsMessagesRelayed = metrics.NewCounterVec("websocket_messages_relayed_total",
+		"Chat messages written to websocket connections, by chat type. rate() gives messages per second.", "chat_type")
 )
 
-func WebSocketsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
-	upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+// wsCloseTimeout bounds how long sending a close frame may block
+const wsCloseTimeout = time.Second
+
+func WebSocketsHandler(db *dbTools.DB, trusted middleware.Origins, w http.ResponseWriter, r *http.Request) {
+	wsHandlers.Add(1)
+	defer wsHandlers.Done()
+
+	// The handshake carries the session cookie, so only the frontend may open it
+	upgrader := websocket.Upgrader{CheckOrigin: trusted.SameOriginRequest}
 	conn, err := upgrader.Upgrade(w, r, nil)
 	var groupIdString string
 
 	if err != nil {
-		http.Error(w, "Could not open websocket", http.StatusBadRequest)
+		utils.SendErrorResponse(w, http.StatusBadRequest, "Could not open websocket")
 		return
 	}
 	defer cleanUp(conn)
 
-	userID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-	if err != nil {
-		log.Printf("Could not get user ID from session cookie: %v\n", err)
+	// The session keeps the request ID of the handshake, so everything it logs can be traced back to it
+	ctx := r.Context()
+	principal, ok := auth.FromRequest(r)
+	if !ok {
+		slog.WarnContext(ctx, "Websocket opened without an authenticated user")
 		return
 	}
+	userID := principal.ID
+	logger := slog.With("user_id", userID)
+	// Unverified accounts may receive messages but not send them
+	readOnly := principal.Status == "pending_verification"
+
 	listOfAllGroups, err := db.GetAllGroups(userID)
-	fmt.Println("listOfAllGroups:", listOfAllGroups)
 	if err != nil {
-		log.Println("err getting all groups:", err)
+		logger.ErrorContext(ctx, "WS: getting groups failed", "err", err)
 	}
 
 	// Protect group membership map writes
@@ -74,13 +116,13 @@ func WebSocketsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 	for _, group := range listOfAllGroups {
 		groupID_G, ok := group["group_id"].(int)
 		if !ok {
-			log.Println("group_id is not an int")
+			logger.ErrorContext(ctx, "WS: group_id is not an int", "group_id", group["group_id"])
 			groupsMutex.Unlock()
 			return
 		}
 		listOfAllGroupMemberIDs, err := db.GetGroupMembers(groupID_G)
 		if err != nil {
-			log.Println("getting group members failed:", err)
+			logger.ErrorContext(ctx, "WS: getting group members failed", "group_id", groupID_G, "err", err)
 			continue
 		}
 		for _, grMember := range listOfAllGroupMemberIDs {
@@ -96,8 +138,15 @@ func WebSocketsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 	groupsMutex.Unlock()
 
 	clientsMutex.Lock()
+	if wsClosing {
+		clientsMutex.Unlock()
+		sendGoingAway(conn)
+		return
+	}
 	clients[conn] = userID
 	clientsMutex.Unlock()
+	logger.InfoContext(ctx, "Websocket connected", "read_only", readOnly)
+	defer logger.InfoContext(ctx, "Websocket disconnected")
 
 	// Check what groups the client is part of, and enable real time messages.
 
@@ -107,13 +156,17 @@ func WebSocketsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 			break
 		}
 
+		if readOnly {
+			logger.DebugContext(ctx, "WS: dropped message from unverified user")
+			continue
+		}
+
 		var incomingMsg inMessage
 		if err := json.Unmarshal(rawMsg, &incomingMsg); err != nil {
-			log.Println("Error unmarshalling:", err)
+			logger.WarnContext(ctx, "WS: invalid message", "err", err)
 			continue
 		}
 
-		fmt.Println("incomingMsg:", incomingMsg)
 		var recieverName string
 		var recieverAvatar string
 		var receiverID, groupID int
@@ -121,8 +174,7 @@ func WebSocketsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 			otherUserUUID := strings.TrimPrefix(incomingMsg.ChatID, "private_")
 			reciever, err := db.FetchUserByUUID(otherUserUUID)
 			if err != nil {
-				log.Println("Error fetching user by UUID:", err)
-				log.Println("Got incorrect UUID:", otherUserUUID)
+				logger.WarnContext(ctx, "WS: unknown private chat recipient", "user_uuid", otherUserUUID, "err", err)
 				continue
 			}
 			receiverID = reciever.UserID
@@ -131,30 +183,23 @@ func WebSocketsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		} else {
 			groupIdString = strings.TrimPrefix(incomingMsg.ChatID, "group_")
 			groupID, err = strconv.Atoi(groupIdString)
-			fmt.Println("groupID:", groupID)
 			if err != nil {
-				log.Println("Error converting to int:", err)
+				logger.WarnContext(ctx, "WS: invalid group chat ID", "chat_id", incomingMsg.ChatID, "err", err)
 				continue
 			}
 		}
 
-		senderID, err := utils.GetUserIDFromSession(db.GetDB(), r)
-		if err != nil {
-			log.Println("Error getting SenderID:", err)
-			continue
-		}
 		chatMsg := dbTools.ChatMessage{
-			SenderID:   senderID,
+			SenderID:   userID,
 			ReceiverID: receiverID,
 			GroupID:    groupID,
 			Content:    incomingMsg.Content,
 			Status:     "active",
 			UpdatedAt:  &incomingMsg.Timestamp,
 		}
-		fmt.Println("groupId:", chatMsg.GroupID)
 		chatID, err := db.AddMessageToDB(&chatMsg)
 		if err != nil {
-			log.Println("Error inserting message into DB:", err)
+			logger.ErrorContext(ctx, "WS: saving message failed", "err", err)
 			continue
 		}
 
@@ -189,18 +234,12 @@ func WebSocketsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 		}
 
 		// fetch the sending user's UUID
-		senderUser, err := db.FetchUserByID(senderID)
+		senderUser, err := db.FetchUserByID(userID)
 		if err != nil {
-			log.Println("WS: failed to fetch sender UUID:", err)
+			logger.ErrorContext(ctx, "WS: fetching sender failed", "err", err)
 			continue
 		}
 
-		fmt.Println("About to send msg to recipients:")
-		fmt.Println(recipientConnections)
-		fmt.Println("or in other words:")
-		for _, conn := range recipientConnections {
-			fmt.Println(&conn)
-		}
 		clientsMutex.RLock()
 		for _, recipientConn := range recipientConnections {
 			outChatID := incomingMsg.ChatID
@@ -224,7 +263,7 @@ func WebSocketsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 				ID:              chatID,
 				ChatID:          outChatID,
 				RequesterID:     incomingMsg.RequesterID,
-				SenderID:        senderID,
+				SenderID:        userID,
 				OtherUserName:   otherName,
 				OtherUserAvatar: otherAvatar,
 				OtherUserID:     otherID,
@@ -235,14 +274,14 @@ func WebSocketsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 			}
 			payload, err := json.Marshal(msg)
 			if err != nil {
-				log.Println("WS: marshal error:", err)
+				logger.ErrorContext(ctx, "WS: marshal failed", "err", err)
 				continue
 			}
 
 			if err := recipientConn.WriteMessage(websocket.TextMessage, payload); err != nil {
 				clientsMutex.RUnlock()
 				clientsMutex.Lock()
-				log.Println("WS: write error, dropping conn:", err)
+				logger.WarnContext(ctx, "WS: write failed, dropping connection", "err", err)
 				recipientConn.Close()
 				delete(clients, recipientConn)
 				// Remove from allGroups map using groupIdString
@@ -253,12 +292,23 @@ func WebSocketsHandler(db *dbTools.DB, w http.ResponseWriter, r *http.Request) {
 				groupsMutex.Unlock()
 				clientsMutex.Unlock()
 				clientsMutex.RLock()
+				continue
 			}
+			wsMessagesRelayed.Inc(relayedChatType(incomingMsg.ChatType))
 		}
 		clientsMutex.RUnlock()
 	}
 }
 
+// relayedChatType is the metric label for a chat type sent by the client;
+// anything but a private chat is handled as a group chat
+func relayedChatType(chatType string) string {
+	if chatType == "private" {
+		return "private"
+	}
+	return "group"
+}
+
 func cleanUp(conn *websocket.Conn) {
 	clientsMutex.Lock()
 	delete(clients, conn)
@@ -271,3 +321,37 @@ func cleanUp(conn *websocket.Conn) {
 	groupsMutex.Unlock()
 	conn.Close()
 }
+
+// sendGoingAway asks the client to disconnect. The read loop ends when the
+// client answers with its own close frame.
+func sendGoingAway(conn *websocket.Conn) {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+	if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsCloseTimeout)); err != nil {
+		slog.Debug("WS: close frame failed", "err", err)
+	}
+}
+
+// closeWebSockets stops accepting connections and sends a close frame to every client.
+// A message that is being handled is still saved and delivered.
+func closeWebSockets() {
+	clientsMutex.Lock()
+	wsClosing = true
+	conns := make([]*websocket.Conn, 0, len(clients))
+	for conn := range clients {
+		conns = append(conns, conn)
+	}
+	clientsMutex.Unlock()
+
+	for _, conn := range conns {
+		sendGoingAway(conn)
+	}
+}
+
+// dropWebSockets closes the connections of clients that did not disconnect in time
+func dropWebSockets() {
+	clientsMutex.RLock()
+	defer clientsMutex.RUnlock()
+	for conn := range clients {
+		conn.Close()
+	}
+}
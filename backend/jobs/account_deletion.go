@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"social_network/dbTools"
+	"time"
+)
+
+// AccountDeletions erases accounts whose deletion grace period is over and
+// finishes deletions that were interrupted. A failed deletion is left for the
+// next run; the others still go ahead.
+func AccountDeletions(db *dbTools.DB) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		ids, err := db.DueAccountDeletions(time.Now())
+		if err != nil {
+			return err
+		}
+
+		failed := 0
+		for _, id := range ids {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			report, err := db.RunAccountDeletion(id)
+			if err != nil {
+				slog.ErrorContext(ctx, "Account deletion failed", "deletion_id", id, "err", err)
+				failed++
+				continue
+			}
+			if report != nil {
+				slog.InfoContext(ctx, "Account deletion completed", "deletion_id", id,
+					"files_removed", report.FilesRemoved, "files_missing", report.FilesMissing, "rows", report.Rows)
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d account deletions failed", failed, len(ids))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,49 @@
+// Package jobs runs periodic background work such as erasing deleted accounts.
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Scheduler runs jobs at fixed intervals until it is stopped
+type Scheduler struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewScheduler() *Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Scheduler{ctx: ctx, cancel: cancel}
+}
+
+// Every runs job right away and then every interval. Errors are logged and the
+// job runs again at the next tick. The context is cancelled by Stop.
+func (s *Scheduler) Every(name string, interval time.Duration, job func(ctx context.Context) error) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := job(s.ctx); err != nil && s.ctx.Err() == nil {
+				slog.Error("Job failed", "job", name, "err", err)
+			}
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop cancels running jobs and waits for them to return
+func (s *Scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
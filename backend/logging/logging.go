@@ -0,0 +1,160 @@
+// Package logging configures the structured logger used across the server.
+// Records logged with a request context carry that request's ID, and
+// attributes that hold credentials or personal data are redacted before they
+// are written.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"social_network/config"
+	"strings"
+	"sync/atomic"
+)
+
+// Redacted replaces the value of sensitive attributes
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are redacted whether they are the whole attribute key or its
+// last word, so "password" also covers "new_password" and "token" covers
+// "access_token" but not "token_id"
+var sensitiveKeys = map[string]bool{
+	"password":      true,
+	"token":         true,
+	"secret":        true,
+	"code":          true,
+	"email":         true,
+	"cookie":        true,
+	"authorization": true,
+	"session_id":    true,
+}
+
+// Setup installs the logger described by cfg as the slog and log default
+func Setup(cfg config.Log) {
+	slog.SetDefault(New(os.Stderr, cfg))
+}
+
+// New returns a logger writing to w at the configured level and format
+func New(w io.Writer, cfg config.Log) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	var h slog.Handler
+	if cfg.Format == "json" {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.MessageKey) {
+		return a
+	}
+	if isSensitive(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// isSensitive reports whether an attribute with this name is redacted
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	if i := strings.LastIndexAny(key, "_-."); i >= 0 {
+		return sensitiveKeys[key[i+1:]]
+	}
+	return false
+}
+
+// contextHandler adds the request ID of the context to every record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// request is what the logger knows about the request a context belongs to.
+// The route and user are filled in by the router and by authentication, after
+// the request was tagged.
+type request struct {
+	id     string
+	route  atomic.Value // string
+	userID atomic.Int64
+}
+
+type requestKey struct{}
+
+// WithRequestID tags the context with a request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestKey{}, &request{id: id})
+}
+
+// RequestID returns the ID stored by WithRequestID, or "" outside a request
+func RequestID(ctx context.Context) string {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		return req.id
+	}
+	return ""
+}
+
+// SetRoute records the path pattern the request matched
+func SetRoute(ctx context.Context, pattern string) {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		req.route.Store(pattern)
+	}
+}
+
+// Route returns the pattern recorded by SetRoute, or "" when no route matched
+func Route(ctx context.Context) string {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		route, _ := req.route.Load().(string)
+		return route
+	}
+	return ""
+}
+
+// SetUserID records the authenticated user of the request for the access log
+func SetUserID(ctx context.Context, userID int) {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		req.userID.Store(int64(userID))
+	}
+}
+
+// UserID returns the user recorded by SetUserID, or 0 for anonymous requests
+func UserID(ctx context.Context) int {
+	if req, ok := ctx.Value(requestKey{}).(*request); ok {
+		return int(req.userID.Load())
+	}
+	return 0
+}
+
+// NewRequestID returns a random 16 character hex ID
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
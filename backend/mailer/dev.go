@@ -0,0 +1,67 @@
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogMailer writes messages to a logger instead of sending them. It is the
+// default for local development. The recipient and body, with any links and
+// codes in it, are logged as they are, so it must not be used in production.
+type LogMailer struct {
+	logger *slog.Logger
+}
+
+// NewLogMailer returns a mailer that logs every message to logger
+func NewLogMailer(logger *slog.Logger) *LogMailer {
+	return &LogMailer{logger: logger}
+}
+
+// Send logs msg
+func (m *LogMailer) Send(msg Message) error {
+	m.logger.Info("mail", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// FileMailer writes each message to its own file in a directory, so local
+// setups and scripted tests can read the mail that would have been sent.
+type FileMailer struct {
+	dir string
+	mu  sync.Mutex
+	seq int
+}
+
+// NewFileMailer creates dir if needed and returns a mailer writing into it
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	return &FileMailer{dir: dir}, nil
+}
+
+// Send writes msg to <dir>/<timestamp>-<n>-<recipient>.eml
+func (m *FileMailer) Send(msg Message) error {
+	m.mu.Lock()
+	m.seq++
+	seq := m.seq
+	m.mu.Unlock()
+
+	recipient := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, msg.To)
+	name := fmt.Sprintf("%s-%d-%s.eml", time.Now().UTC().Format("20060102T150405"), seq, recipient)
+
+	content := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Body)
+	if err := os.WriteFile(filepath.Join(m.dir, name), []byte(content), 0o600); err != nil {
+		return fmt.Errorf("failed to write mail file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+// Package mailer sends transactional email such as password reset links.
+package mailer
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// Message is a plain-text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers email. Implementations must be safe for concurrent use.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// FromEnv builds the mailer selected by MAIL_DRIVER:
+//
+//	smtp  SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME, SMTP_PASSWORD, MAIL_FROM
+//	file  writes each message to MAIL_DIR (default ./mail)
+//	log   writes each message to the server log (default)
+func FromEnv() (Mailer, error) {
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		port := 587
+		if p := os.Getenv("SMTP_PORT"); p != "" {
+			var err error
+			if port, err = strconv.Atoi(p); err != nil {
+				return nil, fmt.Errorf("invalid SMTP_PORT %q: %w", p, err)
+			}
+		}
+		return NewSMTPMailer(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		})
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return NewFileMailer(dir)
+	case "", "log":
+		return NewLogMailer(slog.Default()), nil
+	default:
+		return nil, fmt.Errorf("unknown MAIL_DRIVER %q", driver)
+	}
+}
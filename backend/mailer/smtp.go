@@ -0,0 +1,73 @@
+package mailer
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTPConfig holds the connection settings for SMTPMailer
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string // leave empty for servers that do not require auth
+	Password string
+	From     string
+}
+
+// SMTPMailer sends mail through an SMTP server. STARTTLS is used whenever the
+// server offers it, and credentials are only sent over TLS.
+type SMTPMailer struct {
+	cfg  SMTPConfig
+	addr string
+}
+
+// NewSMTPMailer validates cfg and returns an SMTP mailer
+func NewSMTPMailer(cfg SMTPConfig) (*SMTPMailer, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("SMTP host is required")
+	}
+	if cfg.From == "" {
+		return nil, errors.New("sender address is required")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 587
+	}
+	return &SMTPMailer{cfg: cfg, addr: net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))}, nil
+}
+
+// Send delivers msg to the SMTP server
+func (m *SMTPMailer) Send(msg Message) error {
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		// smtp.PlainAuth refuses to send credentials over an unencrypted connection
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+	}
+	if err := smtp.SendMail(m.addr, auth, m.cfg.From, []string{msg.To}, buildMessage(m.cfg.From, msg)); err != nil {
+		return fmt.Errorf("smtp send to %s: %w", m.addr, err)
+	}
+	return nil
+}
+
+// buildMessage renders msg as an RFC 5322 message with CRLF line endings
+func buildMessage(from string, msg Message) []byte {
+	var b strings.Builder
+	header := func(name, value string) {
+		// Strip newlines so user-controlled values cannot inject headers
+		value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+		b.WriteString(name + ": " + value + "\r\n")
+	}
+	header("From", from)
+	header("To", msg.To)
+	header("Subject", msg.Subject)
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	header("Content-Type", "text/plain; charset=UTF-8")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return []byte(b.String())
+}
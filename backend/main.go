@@ -1,125 +1,130 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"social_network/config"
 	"social_network/dbTools"
 	"social_network/handlers"
+	"social_network/jobs"
+	"social_network/logging"
+	"social_network/mailer"
 	"social_network/middleware"
+	"social_network/oidc"
+	"social_network/router"
+	"social_network/utils"
+	"syscall"
+	"time"
 )
 
-// setHandlers sets up all route handlers
-func setHandlers(db *dbTools.DB) {
-	// Static file serving for uploads
-	http.Handle("/uploads/", http.StripPrefix("/uploads/", http.FileServer(http.Dir("public/uploads"))))
-
-	// API routes
-	http.HandleFunc("/", handlers.HomeHandler)
-	http.HandleFunc("/api/login", func(w http.ResponseWriter, r *http.Request) {
-		handlers.LoginHandler(db, w, r)
-	})
-	http.HandleFunc("/api/register", func(w http.ResponseWriter, r *http.Request) {
-		handlers.RegisterHandler(db, w, r)
-	})
-	http.HandleFunc("/api/logout", func(w http.ResponseWriter, r *http.Request) {
-		handlers.LogoutHandler(db, w, r)
-	})
-	http.HandleFunc("/api/session-check", func(w http.ResponseWriter, r *http.Request) {
-		handlers.SessionCheckHandler(db, w, r)
-	})
-	http.HandleFunc("/api/profile/me", func(w http.ResponseWriter, r *http.Request) {
-		handlers.ProfileMeHandler(db, w, r)
-	})
-	http.HandleFunc("/api/profile/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.ProfileHandler(db, w, r)
-	}) // Will handle /api/profile/{uuid}
-	http.HandleFunc("/api/profile/privacy", func(w http.ResponseWriter, r *http.Request) {
-		handlers.PrivacyHandler(db, w, r)
-	})
-	http.HandleFunc("/api/groups", func(w http.ResponseWriter, r *http.Request) {
-		handlers.GroupsHandler(db, w, r)
-	})
-	http.HandleFunc("/api/events", func(w http.ResponseWriter, r *http.Request) {
-		handlers.EventsHandler(db, w, r)
-	})
-	http.HandleFunc("/api/events/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.EventsHandler(db, w, r)
-	}) // Handle event subroutes
-	http.HandleFunc("/api/groups/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.GroupsHandler(db, w, r)
-	}) // Handle subroutes under groups (must be last)
-
-	// User API routes
-	http.HandleFunc("/api/users/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.UserByIDHandler(db, w, r)
-	}) // Handle /api/users/{id}
-	http.HandleFunc("/api/users/batch", func(w http.ResponseWriter, r *http.Request) {
-		handlers.BatchUsersHandler(db, w, r)
-	}) // Handle batch user requests
-	http.HandleFunc("/api/messages/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.MessageHandler(db, w, r)
-	})
-	http.HandleFunc("/api/ws", func(w http.ResponseWriter, r *http.Request) {
-		handlers.WebSocketsHandler(db, w, r)
-	})
-
-	// Routes for POSTS and COMMENTS
-	http.HandleFunc("/api/createposts", func(w http.ResponseWriter, r *http.Request) {
-		handlers.CreatePostHandler(db, w, r)
-	})
-	http.HandleFunc("/api/getfeedposts", func(w http.ResponseWriter, r *http.Request) {
-		handlers.GetFeedPostsHandler(db, w, r)
-	})
-	http.HandleFunc("/api/getprofileposts/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.GetProfilePostsHandler(db, w, r)
-	})
-	http.HandleFunc("/api/createcomment", func(w http.ResponseWriter, r *http.Request) {
-		handlers.CreateCommentHandler(db, w, r)
-	})
-	http.HandleFunc("/api/getgroupposts/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.GetGroupPostsHandler(db, w, r)
-	})
-
-	// Routes for FOLLOWS and NOTIFICATIONS
-	http.HandleFunc("/api/followers/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.GetFollowersHandler(db, w, r)
-	})
-	http.HandleFunc("/api/following/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.GetFollowingHandler(db, w, r)
-	})
-	http.HandleFunc("/api/follow/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.FollowHandler(db, w, r)
-	})
-	http.HandleFunc("/api/follow/status/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.FollowStatusHandler(db, w, r)
-	})
-	http.HandleFunc("/api/follow_requests", func(w http.ResponseWriter, r *http.Request) {
-		handlers.FollowRequestHandler(db, w, r)
-	})
-	http.HandleFunc("/api/notifications", func(w http.ResponseWriter, r *http.Request) {
-		handlers.NotificationHandler(db, w, r)
-	})
-	http.HandleFunc("/api/notifications/", func(w http.ResponseWriter, r *http.Request) {
-		handlers.NotificationHandler(db, w, r)
-	})
-	http.HandleFunc("/api/users", func(w http.ResponseWriter, r *http.Request) {
-		handlers.UsersHandler(db, w, r)
-	})
-}
-
 func main() {
-	// Initialize database
+	listRoutes := flag.Bool("routes", false, "print the route table and exit")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+
 	db := &dbTools.DB{}
-	if _, err := db.OpenDB(); err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+	if *listRoutes {
+		// The table does not depend on configuration, so nothing is opened
+		if err := router.New(db, handlers.Routes(db, config.Default(), nil, nil)).WriteTable(os.Stdout); err != nil {
+			fatal("Failed to list routes", err)
+		}
+		return
+	}
+	if err != nil {
+		fatal("Failed to load configuration", err)
+	}
+	logging.Setup(cfg.Log)
+
+	// Initialize database
+	if _, err := db.OpenDB(cfg); err != nil {
+		fatal("Failed to initialize database", err)
 	}
-	defer db.CloseDB()
+
+	m, err := mailer.FromEnv()
+	if err != nil {
+		fatal("Failed to configure mailer", err)
+	}
+
+	if err := utils.LoadEmailVerificationSecret(); err != nil {
+		fatal("Failed to set up email verification", err)
+	}
+
+	oidcConfigs, err := oidc.ConfigsFromEnv(cfg.Server.URL)
+	if err != nil {
+		fatal("Invalid OIDC configuration", err)
+	}
+
+	// Erase accounts once their deletion grace period is over
+	scheduler := jobs.NewScheduler()
+	scheduler.Every("account deletions", 10*time.Minute, jobs.AccountDeletions(db))
 
 	// Set up routes
-	setHandlers(db)
+	rt := router.New(db, handlers.Routes(db, cfg, m, oidc.NewRegistry(oidcConfigs)))
+	allowed := middleware.NewOrigins(cfg.CORS.AllowedOrigins)
+	trusted := middleware.NewOrigins(cfg.TrustedOrigins())
 
-	log.Println("Social Network Server starting on :8080")
-	if err := http.ListenAndServe(":8080", middleware.CORSMiddleware(http.DefaultServeMux)); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	srv := &http.Server{
+		Addr:    cfg.Server.Addr,
+		Handler: middleware.RequestLogging(middleware.CORSMiddleware(allowed, middleware.AccessTokenAuth(db, middleware.CSRFProtection(trusted, middleware.RequireVerifiedEmail(db, rt))))),
+		// Errors of the server itself, such as failed TLS handshakes
+		ErrorLog: slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
 	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		slog.Info("Social Network Server starting", "addr", srv.Addr)
+		serverErr <- srv.ListenAndServe()
+	}()
+
+	exitCode := 0
+	select {
+	case err := <-serverErr:
+		slog.Error("Server failed", "err", err)
+		exitCode = 1
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received")
+	}
+	stop() // a second signal kills the process
+
+	if err := shutdown(srv, scheduler, db, cfg.Server.ShutdownTimeout.Std()); err != nil {
+		slog.Error("Shutdown incomplete", "err", err)
+		exitCode = 1
+	}
+	os.Exit(exitCode)
+}
+
+// shutdown stops the server in dependency order: no new requests, then the
+// in-flight requests, websocket messages and background work finish, then the
+// jobs stop, and only then is the database closed
+func shutdown(srv *http.Server, scheduler *jobs.Scheduler, db *dbTools.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	if err := srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("http server: %w", err))
+	}
+	if err := handlers.Drain(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("websockets and background work: %w", err))
+	}
+	scheduler.Stop()
+	if err := db.CloseDB(); err != nil {
+		errs = append(errs, fmt.Errorf("database: %w", err))
+	}
+	slog.Info("Server stopped")
+	return errors.Join(errs...)
+}
+
+// fatal logs why the server cannot start and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "err", err)
+	os.Exit(1)
 }
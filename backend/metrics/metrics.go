@@ -0,0 +1,263 @@
+// Package metrics keeps the server's counters, gauges and histograms and
+// writes them in the Prometheus text exposition format. Metrics are created
+// once, usually as package variables, and registered on creation.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are latency buckets in seconds for HTTP requests
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+var (
+	registryMu sync.Mutex
+	registry   = map[string]metric{}
+)
+
+func register(m metric) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[m.name()]; ok {
+		panic("metrics: duplicate metric " + m.name())
+	}
+	registry[m.name()] = m
+}
+
+// WriteText writes every registered metric, sorted by name
+func WriteText(w io.Writer) {
+	registryMu.Lock()
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	metrics := make([]metric, 0, len(names))
+	sort.Strings(names)
+	for _, name := range names {
+		metrics = append(metrics, registry[name])
+	}
+	registryMu.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the registered metrics to a Prometheus scraper
+func Handler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	WriteText(w)
+}
+
+// desc is the name, help text and label names shared by the metric types
+type desc struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func (d desc) name() string { return d.metricName }
+
+func (d desc) writeHeader(w io.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, escapeHelp(d.help), d.metricName, typ)
+}
+
+// key joins label values into a map key
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label values, got %d", d.metricName, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs formats {a="x",b="y"}, with extra appended after the metric's own labels
+func (d desc) labelPairs(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, label := range d.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", label, escapeLabel(values[i]))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extra[i], escapeLabel(extra[i+1]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// CounterVec is a counter per combination of label values
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	value  float64
+}
+
+// NewCounterVec registers a counter. Without labels it is a single counter.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name, help, labels}, series: map[string]*counterSeries{}}
+	register(c)
+	return c
+}
+
+// Inc adds one to the counter with these label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v, which must not be negative, to the counter with these label values
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	key := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: append([]string{}, labelValues...)}
+		c.series[key] = s
+	}
+	s.value += v
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.writeHeader(w, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.labels) == 0 && len(c.series) == 0 {
+		fmt.Fprintf(w, "%s 0\n", c.metricName)
+	}
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labelPairs(s.values), formatFloat(s.value))
+	}
+}
+
+// HistogramVec counts observations into buckets per combination of label values
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a histogram with the given upper bounds, in increasing order
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if !sort.Float64sAreSorted(buckets) {
+		panic("metrics: buckets of " + name + " are not sorted")
+	}
+	h := &HistogramVec{desc: desc{name, help, labels}, buckets: buckets, series: map[string]*histogramSeries{}}
+	register(h)
+	return h
+}
+
+// Observe records v in the histogram with these label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	key := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: append([]string{}, labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.writeHeader(w, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(s.values, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(s.values, "le", "+Inf"), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelPairs(s.values), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelPairs(s.values), s.count)
+	}
+}
+
+// GaugeFunc is a gauge whose value is read when the metrics are scraped
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc registers a gauge that reports fn()
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{metricName: name, help: help}, fn: fn}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	g.writeHeader(w, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(g.fn()))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
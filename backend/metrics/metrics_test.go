@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	requests := NewCounterVec("test_requests_total", "Requests by path.\nSecond line", "path")
+	latency := NewHistogramVec("test_latency_seconds", "Latency", []float64{0.1, 0.5, 1}, "route")
+	NewGaugeFunc("test_connections", `Open "connections"`, func() float64 { return 3 })
+	NewCounterVec("test_idle_total", "Never incremented")
+
+	requests.Inc(`C:\dir`)
+	requests.Add(2, "say \"hi\"\nbye")
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.7, 2} {
+		latency.Observe(v, "/posts/{uuid}")
+	}
+
+	var b strings.Builder
+	WriteText(&b)
+	want := `# HELP test_connections Open "connections"
+# TYPE test_connections gauge
+test_connections 3
+# HELP test_idle_total Never incremented
+# TYPE test_idle_total counter
+test_idle_total 0
+# HELP test_latency_seconds Latency
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{route="/posts/{uuid}",le="0.1"} 2
+test_latency_seconds_bucket{route="/posts/{uuid}",le="0.5"} 3
+test_latency_seconds_bucket{route="/posts/{uuid}",le="1"} 4
+test_latency_seconds_bucket{route="/posts/{uuid}",le="+Inf"} 5
+test_latency_seconds_sum{route="/posts/{uuid}"} 3.15
+test_latency_seconds_count{route="/posts/{uuid}"} 5
+# HELP test_requests_total Requests by path.\nSecond line
+# TYPE test_requests_total counter
+test_requests_total{path="C:\\dir"} 1
+test_requests_total{path="say \"hi\"\nbye"} 2
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteText wrote\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"database/sql"
+	"log/slog"
+	"net/http"
+	"social_network/dbTools"
+	"social_network/logging"
+	"social_network/utils"
+	"strings"
+)
+
+// errInvalidAccessToken answers malformed, unknown, expired and revoked tokens alike
+var errInvalidAccessToken = utils.NewAPIError(http.StatusUnauthorized, utils.CodeInvalidAccessToken, "Invalid access token")
+
+// AccessTokenAuth authenticates requests carrying "Authorization: Bearer <token>".
+// The token must be valid and hold the scope the endpoint requires; the user is
+// then stored in the request context, where utils.GetUserIDFromSession finds it.
+// Requests without a bearer token are passed through unchanged.
+func AccessTokenAuth(db *dbTools.DB, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := utils.BearerToken(r)
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if !strings.HasPrefix(token, utils.AccessTokenPrefix) {
+			utils.SendError(w, errInvalidAccessToken)
+			return
+		}
+		accessToken, err := db.AuthenticateAccessToken(utils.HashToken(token))
+		if err == sql.ErrNoRows {
+			utils.SendError(w, errInvalidAccessToken)
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Access token lookup failed", "err", err)
+			utils.SendErrorResponse(w, http.StatusInternalServerError, "Failed to check access token")
+			return
+		}
+
+		scope, ok := utils.RequiredScope(r.Method, r.URL.Path)
+		if !ok {
+			utils.SendError(w, utils.NewAPIError(http.StatusForbidden, utils.CodeInsufficientScope, "This endpoint is not available to access tokens"))
+			return
+		}
+		if !accessToken.HasScope(scope) {
+			utils.SendError(w, utils.NewAPIError(http.StatusForbidden, utils.CodeInsufficientScope, "Access token is missing the "+scope+" scope"))
+			return
+		}
+
+		logging.SetUserID(r.Context(), accessToken.UserID)
+		next.ServeHTTP(w, r.WithContext(utils.WithAccessTokenUser(r.Context(), accessToken.UserID)))
+	})
+}
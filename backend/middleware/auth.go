@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"social_network/auth"
+	"social_network/dbTools"
+	"social_network/utils"
+)
+
+// RequireAuth only lets authenticated requests through to next, with the
+// principal in the request context. Anonymous requests get a 401.
+func RequireAuth(db *dbTools.DB, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r, ok := authenticate(db, r)
+		if !ok && r.Method != http.MethodOptions {
+			WriteUnauthorized(w)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// OptionalAuth adds the principal to the request context when the request is
+// authenticated and passes anonymous requests through unchanged
+func OptionalAuth(db *dbTools.DB, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r, _ = authenticate(db, r)
+		next(w, r)
+	}
+}
+
+// WriteUnauthorized is the response to anonymous requests for something that needs a user
+func WriteUnauthorized(w http.ResponseWriter) {
+	utils.SendErrorResponse(w, http.StatusUnauthorized, "Authentication required")
+}
+
+// authenticate resolves the principal unless an earlier middleware already did
+func authenticate(db *dbTools.DB, r *http.Request) (*http.Request, bool) {
+	if _, ok := auth.FromRequest(r); ok {
+		return r, true
+	}
+	p, err := auth.Resolve(db, r)
+	if err != nil {
+		return r, false
+	}
+	return r.WithContext(auth.WithPrincipal(r.Context(), p)), true
+}
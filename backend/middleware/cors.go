@@ -1,27 +1,57 @@
 package middleware
 
-import "net/http"
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
 
-// SetCORSHeaders adds CORS headers to allow React frontend to call Go backend
-func SetCORSHeaders(w http.ResponseWriter) {
-	w.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-	w.Header().Set("Access-Control-Allow-Credentials", "true")
+// Origins is a set of browser origins, normalized to scheme://host[:port]
+type Origins []string
+
+// NewOrigins normalizes the configured origins, skipping any that do not parse
+func NewOrigins(origins []string) Origins {
+	var normalized Origins
+	for _, origin := range origins {
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			slog.Warn("Ignoring invalid origin", "origin", origin)
+			continue
+		}
+		normalized = append(normalized, strings.ToLower(u.Scheme+"://"+u.Host))
+	}
+	return normalized
 }
 
-func HandlePreflight(w http.ResponseWriter, r *http.Request) {
-	if r.Method == "OPTIONS" {
-		SetCORSHeaders(w)
-		w.WriteHeader(http.StatusOK)
-		return
+// Contains reports whether the value of an Origin header is in the set
+func (o Origins) Contains(origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		// Includes "null", sent from sandboxed frames and some redirects
+		return false
 	}
+	normalized := strings.ToLower(u.Scheme + "://" + u.Host)
+	for _, allowed := range o {
+		if normalized == allowed {
+			return true
+		}
+	}
+	return false
 }
 
-// CORSMiddleware wraps an http.Handler to add CORS headers
-func CORSMiddleware(next http.Handler) http.Handler {
+// CORSMiddleware lets the allowed frontends call the API with credentials.
+// The request's origin is echoed back when it is allowed; other origins get no
+// CORS headers, so the browser keeps the response from them.
+func CORSMiddleware(allowed Origins, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		SetCORSHeaders(w)
+		w.Header().Add("Vary", "Origin")
+		if origin := r.Header.Get("Origin"); origin != "" && allowed.Contains(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"social_network/utils"
+	"testing"
+)
+
+func TestCSRFProtection(t *testing.T) {
+	trusted := NewOrigins([]string{"http://localhost:3000", "https://app.example.com"})
+	handler := CSRFProtection(trusted, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		fetchSite   string
+		accessToken bool
+		// bearer sets an Authorization header without AccessTokenAuth having accepted it
+		bearer  bool
+		allowed bool
+	}{
+		{name: "same origin", method: "POST", origin: "http://api.local", allowed: true},
+		{name: "allowed frontend", method: "POST", origin: "http://localhost:3000", allowed: true},
+		{name: "trusted extra origin", method: "PUT", origin: "https://app.example.com", allowed: true},
+		{name: "trusted origin in other case", method: "DELETE", origin: "HTTPS://APP.EXAMPLE.COM", allowed: true},
+		{name: "other site", method: "POST", origin: "https://evil.example", allowed: false},
+		{name: "trusted host on another scheme", method: "POST", origin: "http://app.example.com", allowed: false},
+		{name: "null origin", method: "POST", origin: "null", allowed: false},
+		{name: "null origin with same-origin fetch metadata", method: "POST", origin: "null", fetchSite: "same-origin", allowed: false},
+		{name: "no origin, cross-site", method: "POST", fetchSite: "cross-site", allowed: false},
+		{name: "no origin, same-site", method: "POST", fetchSite: "same-site", allowed: false},
+		{name: "no origin, same-origin", method: "POST", fetchSite: "same-origin", allowed: true},
+		{name: "no origin, user navigation", method: "POST", fetchSite: "none", allowed: true},
+		{name: "no browser headers", method: "POST", allowed: true},
+		{name: "GET from other site", method: "GET", origin: "https://evil.example", fetchSite: "cross-site", allowed: true},
+		{name: "HEAD from other site", method: "HEAD", origin: "https://evil.example", allowed: true},
+		{name: "preflight from other site", method: "OPTIONS", origin: "https://evil.example", allowed: true},
+		{name: "access token from other site", method: "POST", origin: "https://evil.example", fetchSite: "cross-site", accessToken: true, allowed: true},
+		{name: "unchecked bearer header from other site", method: "POST", origin: "https://evil.example", bearer: true, allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "http://api.local/api/v1/posts", nil)
+			if tt.origin != "" {
+				r.Header.Set("Origin", tt.origin)
+			}
+			if tt.fetchSite != "" {
+				r.Header.Set("Sec-Fetch-Site", tt.fetchSite)
+			}
+			if tt.bearer {
+				r.Header.Set("Authorization", "Bearer snpat_test")
+			}
+			if tt.accessToken {
+				r.Header.Set("Authorization", "Bearer snpat_test")
+				r = r.WithContext(utils.WithAccessTokenUser(r.Context(), 1))
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if allowed := w.Code == http.StatusNoContent; allowed != tt.allowed {
+				t.Errorf("status %d, allowed = %v, want %v", w.Code, allowed, tt.allowed)
+			}
+			if !tt.allowed && w.Code != http.StatusForbidden {
+				t.Errorf("rejected with status %d, want 403", w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"net/http"
+	"social_network/metrics"
+	"strconv"
+	"time"
+)
+
+var (
+	httpRequests = metrics.NewCounterVec("http_requests_total",
+		"HTTP requests by method, route pattern and status code.", "method", "route", "status")
+	httpDuration = metrics.NewHistogramVec("http_request_duration_seconds",
+		"Time to answer HTTP requests by method and route pattern. Websocket sessions are left out.",
+		metrics.DefBuckets, "method", "route")
+)
+
+// observeRequest records a finished request. Requests that matched no route
+// share one label, so scanners probing random paths cannot grow the series.
+func observeRequest(method, route string, status int, elapsed time.Duration) {
+	if route == "" {
+		route = "unmatched"
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		default:
+			method = "OTHER"
+		}
+	}
+	httpRequests.Inc(method, route, strconv.Itoa(status))
+	if status != http.StatusSwitchingProtocols {
+		httpDuration.Observe(elapsed.Seconds(), method, route)
+	}
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"social_network/auth"
+	"social_network/dbTools"
+	"social_network/utils"
+)
+
+// unverifiedAllowedPaths are the write endpoints an account with an unconfirmed
+// email may still use: signing in and out, confirming the email and securing the account
+var unverifiedAllowedPaths = []string{
+	"/api/login",
+	"/api/logout",
+	"/api/register",
+	"/api/email/",
+	"/api/password/",
+	"/api/sessions",
+	"/api/2fa/",
+	"/api/account/",
+	"/api/auth/",
+}
+
+// RequireVerifiedEmail limits accounts in the pending_verification state to
+// read-only requests. Requests without a session are passed through; the
+// route's RequireAuth or OptionalAuth decides whether they need one. The
+// principal resolved here is kept in the context so it is not looked up twice.
+func RequireVerifiedEmail(db *dbTools.DB, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isReadOnlyMethod(r.Method) || utils.MatchesPath(r.URL.Path, unverifiedAllowedPaths...) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r, ok := authenticate(db, r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if p, _ := auth.FromRequest(r); p.Status == "pending_verification" {
+			utils.SendError(w, utils.NewAPIError(http.StatusForbidden, utils.CodeEmailNotVerified, "Please verify your email address first"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
@@ -0,0 +1,114 @@
+// Package oidc implements the relying party side of OpenID Connect: the
+// authorization code flow with PKCE and ID token verification.
+package oidc
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ProviderConfig configures one OpenID Connect provider
+type ProviderConfig struct {
+	Name         string // used in URLs, e.g. "google"
+	DisplayName  string // shown on the login page
+	Issuer       string // discovery is done at Issuer + "/.well-known/openid-configuration"
+	ClientID     string
+	ClientSecret string // empty for public clients
+	RedirectURL  string // must point at /api/auth/oidc/{name}/callback
+	Scopes       []string
+}
+
+// ConfigsFromEnv reads provider configurations from the environment.
+// OIDC_PROVIDERS is a comma separated list of names; each provider NAME is
+// configured through OIDC_NAME_ISSUER, OIDC_NAME_CLIENT_ID,
+// OIDC_NAME_CLIENT_SECRET, OIDC_NAME_REDIRECT_URL, OIDC_NAME_DISPLAY_NAME and
+// OIDC_NAME_SCOPES (space separated, default "openid email profile").
+// backendURL is used to build the default redirect URL.
+func ConfigsFromEnv(backendURL string) ([]ProviderConfig, error) {
+	var configs []ProviderConfig
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		env := func(key string) string {
+			return strings.TrimSpace(os.Getenv("OIDC_" + strings.ToUpper(name) + "_" + key))
+		}
+
+		cfg := ProviderConfig{
+			Name:         name,
+			DisplayName:  env("DISPLAY_NAME"),
+			Issuer:       strings.TrimSuffix(env("ISSUER"), "/"),
+			ClientID:     env("CLIENT_ID"),
+			ClientSecret: env("CLIENT_SECRET"),
+			RedirectURL:  env("REDIRECT_URL"),
+			Scopes:       strings.Fields(env("SCOPES")),
+		}
+		if cfg.DisplayName == "" {
+			cfg.DisplayName = name
+		}
+		if cfg.RedirectURL == "" {
+			cfg.RedirectURL = strings.TrimSuffix(backendURL, "/") + "/api/auth/oidc/" + name + "/callback"
+		}
+		if len(cfg.Scopes) == 0 {
+			cfg.Scopes = []string{"openid", "email", "profile"}
+		}
+		if err := cfg.validate(); err != nil {
+			return nil, err
+		}
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+func (c ProviderConfig) validate() error {
+	for _, r := range c.Name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("oidc provider name %q may only contain a-z, 0-9, - and _", c.Name)
+		}
+	}
+	if c.Issuer == "" {
+		return fmt.Errorf("oidc provider %s: issuer is required", c.Name)
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("oidc provider %s: client id is required", c.Name)
+	}
+	hasOpenID := false
+	for _, s := range c.Scopes {
+		hasOpenID = hasOpenID || s == "openid"
+	}
+	if !hasOpenID {
+		return fmt.Errorf("oidc provider %s: scopes must include openid", c.Name)
+	}
+	return nil
+}
+
+// Registry holds the configured providers in configuration order
+type Registry struct {
+	providers []*Provider
+}
+
+// NewRegistry creates a provider for each configuration
+func NewRegistry(configs []ProviderConfig) *Registry {
+	r := &Registry{}
+	for _, cfg := range configs {
+		r.providers = append(r.providers, NewProvider(cfg))
+	}
+	return r
+}
+
+// Get returns the provider with the given name, or nil
+func (r *Registry) Get(name string) *Provider {
+	for _, p := range r.providers {
+		if p.Config.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Providers returns all configured providers
+func (r *Registry) Providers() []*Provider {
+	return r.providers
+}
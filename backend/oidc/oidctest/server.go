@@ -0,0 +1,319 @@
+// Package oidctest is a minimal OpenID Connect provider for local development
+// and tests. It signs in whoever fills in its form, so never expose it
+// outside a dev machine.
+package oidctest
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// authRequest is an issued authorization code waiting to be exchanged
+type authRequest struct {
+	clientID      string
+	redirectURI   string
+	nonce         string
+	codeChallenge string
+	claims        map[string]interface{}
+	expiresAt     time.Time
+}
+
+// Server is the provider. Issuer must be the URL it is reachable at, as seen
+// by the backend and the browser; it may be set after the server is started.
+type Server struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string // empty for a public client
+
+	mu          sync.Mutex
+	key         *rsa.PrivateKey
+	keyID       string
+	keySeq      int
+	codes       map[string]authRequest
+	jwksFetches int
+}
+
+// NewServer returns a provider with a fresh signing key
+func NewServer(issuer, clientID, clientSecret string) (*Server, error) {
+	s := &Server{
+		Issuer:       strings.TrimSuffix(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		codes:        make(map[string]authRequest),
+	}
+	if err := s.RotateKey(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Handler serves discovery, the login form, the token endpoint and the JWKS
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/jwks", s.jwks)
+	return mux
+}
+
+// RotateKey replaces the signing key. The JWKS only publishes the new one, so
+// tokens signed before stop verifying once a client refetches it.
+func (s *Server) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keySeq++
+	s.key = key
+	s.keyID = fmt.Sprintf("mock-%d", s.keySeq)
+	return nil
+}
+
+// JWKSRequests returns how often the JWKS was fetched
+func (s *Server) JWKSRequests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jwksFetches
+}
+
+var loginForm = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html><head><title>Mock IdP</title></head>
+<body>
+<h1>Mock identity provider</h1>
+<form method="POST">
+{{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">
+{{end}}
+<p><label>Subject <input name="sub" value="user-1" required></label></p>
+<p><label>Email <input name="email" value="mock.user@example.com"></label></p>
+<p><label><input type="checkbox" name="email_verified" value="true" checked> Email verified</label></p>
+<p><label>Given name <input name="given_name" value="Mock"></label></p>
+<p><label>Family name <input name="family_name" value="User"></label></p>
+<p><label>Birthdate <input name="birthdate" placeholder="YYYY-MM-DD"></label></p>
+<p><label>Picture URL <input name="picture"></label></p>
+<p><button type="submit">Sign in</button></p>
+</form>
+</body></html>`))
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.Issuer,
+		"authorization_endpoint":                s.Issuer + "/authorize",
+		"token_endpoint":                        s.Issuer + "/token",
+		"jwks_uri":                              s.Issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+	})
+}
+
+// authorize shows the login form on GET and issues a code on POST
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	params := url.Values{}
+	for _, k := range []string{"client_id", "redirect_uri", "state", "nonce", "code_challenge", "code_challenge_method", "response_type", "scope"} {
+		params.Set(k, r.Form.Get(k))
+	}
+
+	if params.Get("client_id") != s.ClientID || params.Get("response_type") != "code" {
+		http.Error(w, "unknown client or unsupported response_type", http.StatusBadRequest)
+		return
+	}
+	redirectURI, err := url.Parse(params.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if params.Get("code_challenge") == "" || params.Get("code_challenge_method") != "S256" {
+		http.Error(w, "PKCE with S256 is required", http.StatusBadRequest)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		loginForm.Execute(w, map[string]interface{}{"Params": params})
+		return
+	}
+
+	claims := map[string]interface{}{"email_verified": r.PostForm.Get("email_verified") == "true"}
+	for _, k := range []string{"sub", "email", "given_name", "family_name", "birthdate", "picture"} {
+		if v := strings.TrimSpace(r.PostForm.Get(k)); v != "" {
+			claims[k] = v
+		}
+	}
+	if claims["sub"] == nil {
+		http.Error(w, "sub is required", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString(24)
+	s.mu.Lock()
+	s.codes[code] = authRequest{
+		clientID:      params.Get("client_id"),
+		redirectURI:   params.Get("redirect_uri"),
+		nonce:         params.Get("nonce"),
+		codeChallenge: params.Get("code_challenge"),
+		claims:        claims,
+		expiresAt:     time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	query := redirectURI.Query()
+	query.Set("code", code)
+	query.Set("state", params.Get("state"))
+	redirectURI.RawQuery = query.Encode()
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+// token exchanges an authorization code for an ID token
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+	}
+	if clientID != s.ClientID || subtle.ConstantTimeCompare([]byte(secret), []byte(s.ClientSecret)) != 1 {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	// Codes are single use whether or not the exchange succeeds
+	s.mu.Lock()
+	req, ok := s.codes[r.PostForm.Get("code")]
+	delete(s.codes, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	switch {
+	case !ok || time.Now().After(req.expiresAt) || req.clientID != clientID:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	case req.redirectURI != r.PostForm.Get("redirect_uri"):
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "redirect_uri mismatch"})
+		return
+	case codeChallenge(r.PostForm.Get("code_verifier")) != req.codeChallenge:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"})
+		return
+	}
+
+	claims := s.Claims(req.nonce)
+	for k, v := range req.claims {
+		claims[k] = v
+	}
+
+	idToken, err := s.Sign(claims)
+	if err != nil {
+		log.Printf("Failed to sign ID token: %v", err)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(24),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.jwksFetches++
+	key, keyID := s.key, s.keyID
+	s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": keyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	})
+}
+
+// Claims returns the registered claims of an ID token for the client, valid
+// for five minutes, with nonce if it is not empty
+func (s *Server) Claims(nonce string) map[string]interface{} {
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": s.Issuer,
+		"aud": s.ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(5 * time.Minute).Unix(),
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	return claims
+}
+
+// Sign produces an RS256 compact JWS of claims with the current key
+func (s *Server) Sign(claims map[string]interface{}) (string, error) {
+	s.mu.Lock()
+	key, keyID := s.key, s.keyID
+	s.mu.Unlock()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": keyID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// codeChallenge is the S256 PKCE challenge of a verifier
+func codeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func randomString(nBytes int) string {
+	b := make([]byte, nBytes)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,33 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+)
+
+// NewCodeVerifier returns a random PKCE code verifier (RFC 7636)
+func NewCodeVerifier() (string, error) {
+	return randomString(32)
+}
+
+// CodeChallenge returns the S256 code challenge for a verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomString returns nBytes of randomness encoded as unpadded base64url
+func randomString(nBytes int) (string, error) {
+	b := make([]byte, nBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random value: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// NewState returns a random value for the state or nonce parameter
+func NewState() (string, error) {
+	return randomString(32)
+}
@@ -0,0 +1,254 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clockSkew is the tolerance for exp and iat checks
+	clockSkew = 2 * time.Minute
+	// maxResponseSize bounds discovery, JWKS and token responses
+	maxResponseSize = 1 << 20
+)
+
+// Claims are the ID token claims used to sign a user in
+type Claims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Audience      audience `json:"aud"`
+	AuthorizedBy  string   `json:"azp"`
+	Expiry        int64    `json:"exp"`
+	IssuedAt      int64    `json:"iat"`
+	Nonce         string   `json:"nonce"`
+	Email         string   `json:"email"`
+	EmailVerified boolish  `json:"email_verified"`
+	Name          string   `json:"name"`
+	GivenName     string   `json:"given_name"`
+	FamilyName    string   `json:"family_name"`
+	Picture       string   `json:"picture"`
+	Birthdate     string   `json:"birthdate"`
+}
+
+// Provider is a configured OpenID Connect provider. Its discovery document and
+// signing keys are fetched on first use and cached.
+type Provider struct {
+	Config ProviderConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	discovery *discoveryDocument
+	keys      *keySet
+}
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewProvider returns a provider for cfg
+func NewProvider(cfg ProviderConfig) *Provider {
+	return &Provider{Config: cfg, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// AuthCodeURL returns the URL to send the browser to. The verifier is kept
+// server-side; only its S256 challenge is sent to the provider.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	doc, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.Config.ClientID)
+	params.Set("redirect_uri", p.Config.RedirectURL)
+	params.Set("scope", strings.Join(p.Config.Scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", CodeChallenge(codeVerifier))
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(doc.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return doc.AuthorizationEndpoint + sep + params.Encode(), nil
+}
+
+// Exchange trades an authorization code for tokens and returns the verified ID token claims
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	doc, _, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.Config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	if p.Config.ClientSecret == "" {
+		form.Set("client_id", p.Config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, doc.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.Config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.Config.ClientID), url.QueryEscape(p.Config.ClientSecret))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&token); err != nil {
+		return nil, fmt.Errorf("invalid token response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK || token.Error != "" {
+		return nil, fmt.Errorf("token request rejected: %s %s", token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	return p.VerifyIDToken(ctx, token.IDToken, nonce)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of an ID token
+func (p *Provider) VerifyIDToken(ctx context.Context, rawIDToken, nonce string) (*Claims, error) {
+	doc, keys, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := verifyJWS(ctx, keys, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("malformed id token claims: %w", err)
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != doc.Issuer:
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	case !claims.Audience.contains(p.Config.ClientID):
+		return nil, errors.New("id token was not issued for this client")
+	case len(claims.Audience) > 1 && claims.AuthorizedBy != p.Config.ClientID:
+		return nil, errors.New("id token has an unexpected authorized party")
+	case claims.Expiry == 0 || now.After(time.Unix(claims.Expiry, 0).Add(clockSkew)):
+		return nil, errors.New("id token expired")
+	case claims.IssuedAt > 0 && time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)):
+		return nil, errors.New("id token issued in the future")
+	case claims.Nonce != nonce:
+		return nil, errors.New("id token nonce mismatch")
+	case claims.Subject == "":
+		return nil, errors.New("id token has no subject")
+	}
+	return &claims, nil
+}
+
+// discover fetches and caches the provider metadata. A failed fetch is retried on the next call.
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, *keySet, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, p.keys, nil
+	}
+
+	var doc discoveryDocument
+	if err := getJSON(ctx, p.client, p.Config.Issuer+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, nil, fmt.Errorf("oidc discovery for %s failed: %w", p.Config.Name, err)
+	}
+	if strings.TrimSuffix(doc.Issuer, "/") != p.Config.Issuer {
+		return nil, nil, fmt.Errorf("oidc discovery for %s returned issuer %q", p.Config.Name, doc.Issuer)
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" || doc.JWKSURI == "" {
+		return nil, nil, fmt.Errorf("oidc discovery for %s is missing endpoints", p.Config.Name)
+	}
+
+	p.discovery = &doc
+	p.keys = newKeySet(doc.JWKSURI, p.client)
+	return p.discovery, p.keys, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(v)
+}
+
+// audience accepts both forms of the aud claim: a string or an array of strings
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a audience) contains(clientID string) bool {
+	for _, aud := range a {
+		if aud == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// boolish accepts email_verified as a JSON boolean or as the string "true",
+// which some providers send
+type boolish bool
+
+func (b *boolish) UnmarshalJSON(data []byte) error {
+	switch strings.Trim(string(data), `"`) {
+	case "true":
+		*b = true
+	default:
+		*b = false
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"social_network/oidc/oidctest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID     = "social-network"
+	testClientSecret = "dev-secret"
+	testRedirectURL  = "http://backend.test/api/v1/auth/oidc/mock/callback"
+	testNonce        = "nonce-1"
+)
+
+// newTestProvider starts a mock identity provider and returns a provider configured for it
+func newTestProvider(t *testing.T) (*Provider, *oidctest.Server) {
+	t.Helper()
+	idp, err := oidctest.NewServer("", testClientID, testClientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(idp.Handler())
+	t.Cleanup(srv.Close)
+	idp.Issuer = srv.URL
+
+	return NewProvider(ProviderConfig{
+		Name:         "mock",
+		Issuer:       srv.URL,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+		RedirectURL:  testRedirectURL,
+		Scopes:       []string{"openid", "email", "profile"},
+	}), idp
+}
+
+// signJWS builds a compact JWS with any header, signed with an RSA key
+// (RS256) or, for alg HS256, with secret
+func signJWS(t *testing.T, header, claims map[string]interface{}, key *rsa.PrivateKey, secret []byte) string {
+	t.Helper()
+	h, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+
+	var sig []byte
+	switch {
+	case key != nil:
+		digest := sha256.Sum256([]byte(input))
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case secret != nil:
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// jwksKey returns the modulus of the published key: public, so what an attacker
+// would use as the secret of a forged HS256 token
+func jwksKey(t *testing.T, p *Provider) []byte {
+	t.Helper()
+	_, keys, err := p.discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := keys.key(context.Background(), "mock-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key.N.Bytes()
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	p, idp := newTestProvider(t)
+	ctx := context.Background()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+
+	// Each case changes valid claims, or builds the token itself
+	tests := []struct {
+		name   string
+		claims func(c map[string]interface{})
+		token  func(c map[string]interface{}) string
+		nonce  string
+		ok     bool
+	}{
+		{name: "valid", ok: true},
+		{name: "audience list with this client as authorized party", ok: true, claims: func(c map[string]interface{}) {
+			c["aud"] = []string{testClientID, "other"}
+			c["azp"] = testClientID
+		}},
+		{name: "expired within the clock skew", ok: true, claims: func(c map[string]interface{}) {
+			c["exp"] = now.Add(-time.Minute).Unix()
+		}},
+		{name: "expired", claims: func(c map[string]interface{}) { c["exp"] = now.Add(-time.Hour).Unix() }},
+		{name: "no expiry", claims: func(c map[string]interface{}) { delete(c, "exp") }},
+		{name: "issued in the future", claims: func(c map[string]interface{}) { c["iat"] = now.Add(time.Hour).Unix() }},
+		{name: "wrong issuer", claims: func(c map[string]interface{}) { c["iss"] = "https://evil.example" }},
+		{name: "wrong audience", claims: func(c map[string]interface{}) { c["aud"] = "other-client" }},
+		{name: "audience list without authorized party", claims: func(c map[string]interface{}) {
+			c["aud"] = []string{testClientID, "other"}
+		}},
+		{name: "audience list with another authorized party", claims: func(c map[string]interface{}) {
+			c["aud"] = []string{testClientID, "other"}
+			c["azp"] = "other"
+		}},
+		{name: "wrong nonce", nonce: "nonce-2"},
+		{name: "missing nonce", claims: func(c map[string]interface{}) { delete(c, "nonce") }},
+		{name: "no subject", claims: func(c map[string]interface{}) { delete(c, "sub") }},
+		{name: "signed with another key", token: func(c map[string]interface{}) string {
+			return signJWS(t, map[string]interface{}{"alg": "RS256", "kid": "mock-1"}, c, otherKey, nil)
+		}},
+		{name: "unknown key ID", token: func(c map[string]interface{}) string {
+			return signJWS(t, map[string]interface{}{"alg": "RS256", "kid": "mock-9"}, c, otherKey, nil)
+		}},
+		{name: "alg none", token: func(c map[string]interface{}) string {
+			return signJWS(t, map[string]interface{}{"alg": "none", "kid": "mock-1"}, c, nil, nil)
+		}},
+		{name: "HS256 keyed with the public key", token: func(c map[string]interface{}) string {
+			return signJWS(t, map[string]interface{}{"alg": "HS256", "kid": "mock-1"}, c, nil, jwksKey(t, p))
+		}},
+		{name: "payload swapped under a valid signature", token: func(c map[string]interface{}) string {
+			valid, err := idp.Sign(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c["sub"] = "someone-else"
+			forged, _ := json.Marshal(c)
+			parts := strings.Split(valid, ".")
+			return parts[0] + "." + base64.RawURLEncoding.EncodeToString(forged) + "." + parts[2]
+		}},
+		{name: "not a JWS", token: func(c map[string]interface{}) string { return "a.b" }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := idp.Claims(testNonce)
+			claims["sub"] = "user-1"
+			if tt.claims != nil {
+				tt.claims(claims)
+			}
+			var raw string
+			if tt.token != nil {
+				raw = tt.token(claims)
+			} else if raw, err = idp.Sign(claims); err != nil {
+				t.Fatal(err)
+			}
+			nonce := testNonce
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			got, err := p.VerifyIDToken(ctx, raw, nonce)
+			if tt.ok && err != nil {
+				t.Fatalf("VerifyIDToken: %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatalf("VerifyIDToken accepted the token: %+v", got)
+			}
+			if tt.ok && got.Subject != "user-1" {
+				t.Errorf("subject = %q, want user-1", got.Subject)
+			}
+		})
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	p, idp := newTestProvider(t)
+	ctx := context.Background()
+	sign := func() string {
+		claims := idp.Claims(testNonce)
+		claims["sub"] = "user-1"
+		raw, err := idp.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+
+	oldToken := sign()
+	if _, err := p.VerifyIDToken(ctx, oldToken, testNonce); err != nil {
+		t.Fatalf("token of the first key: %v", err)
+	}
+	if n := idp.JWKSRequests(); n != 1 {
+		t.Fatalf("JWKS fetched %d times, want 1", n)
+	}
+
+	if err := idp.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	newToken := sign()
+
+	// Unknown key IDs refetch the JWKS at most once per interval
+	if _, err := p.VerifyIDToken(ctx, newToken, testNonce); !errors.Is(err, errUnknownKey) {
+		t.Fatalf("token of the new key right after a fetch: err = %v, want errUnknownKey", err)
+	}
+	if n := idp.JWKSRequests(); n != 1 {
+		t.Fatalf("JWKS fetched %d times within the refresh interval, want 1", n)
+	}
+
+	p.keys.lastFetched = time.Now().Add(-jwksRefreshInterval)
+	if _, err := p.VerifyIDToken(ctx, newToken, testNonce); err != nil {
+		t.Fatalf("token of the new key after the interval: %v", err)
+	}
+	if n := idp.JWKSRequests(); n != 2 {
+		t.Fatalf("JWKS fetched %d times, want 2", n)
+	}
+	// The retired key is no longer trusted
+	if _, err := p.VerifyIDToken(ctx, oldToken, testNonce); err == nil {
+		t.Error("token of the retired key still verifies")
+	}
+}
+
+// authorize signs in at the mock provider and returns the authorization code
+func authorize(t *testing.T, p *Provider, verifier, nonce string) string {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), "state-1", nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := u.Query()
+	form.Set("sub", "user-1")
+	form.Set("email", "mock.user@example.com")
+	form.Set("email_verified", "true")
+	u.RawQuery = ""
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.PostForm(u.String(), form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	callback, err := resp.Location()
+	if err != nil {
+		t.Fatalf("no redirect back from the provider (status %d): %v", resp.StatusCode, err)
+	}
+	if got := callback.Query().Get("state"); got != "state-1" {
+		t.Fatalf("state = %q, want state-1", got)
+	}
+	return callback.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	p, _ := newTestProvider(t)
+	ctx := context.Background()
+	verifier, err := NewCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := authorize(t, p, verifier, testNonce)
+	claims, err := p.Exchange(ctx, code, verifier, testNonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.Subject != "user-1" || claims.Email != "mock.user@example.com" || !bool(claims.EmailVerified) {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// Codes are single use
+	if _, err := p.Exchange(ctx, code, verifier, testNonce); err == nil {
+		t.Error("a code was exchanged twice")
+	}
+
+	// The verifier must match the challenge sent with the authorization request
+	other, err := NewCodeVerifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code = authorize(t, p, verifier, testNonce)
+	if _, err := p.Exchange(ctx, code, other, testNonce); err == nil {
+		t.Error("Exchange succeeded with the wrong PKCE verifier")
+	}
+
+	// The nonce of the login must come back in the ID token
+	code = authorize(t, p, verifier, testNonce)
+	if _, err := p.Exchange(ctx, code, verifier, "nonce-2"); err == nil {
+		t.Error("Exchange accepted an ID token for another login's nonce")
+	}
+}
+
+// TestCodeChallenge checks the example of RFC 7636 appendix B
+func TestCodeChallenge(t *testing.T) {
+	got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("CodeChallenge = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,117 @@
+// Package policy decides who can see and interact with content. It is pure:
+// callers load the facts about a viewer and a post, and the same rule table is
+// rendered as SQL for queries that filter many posts at once.
+package policy
+
+import "strings"
+
+// Audiences a post can have. Group posts are seen by the group whatever their
+// privacy value; the others follow posts.privacy.
+const (
+	AudiencePublic    = "public"       // anyone
+	AudienceFollowers = "semi-private" // the author's accepted followers
+	AudienceSelected  = "private"      // the viewers chosen by the author
+	AudienceGroup     = "group"        // accepted members of the post's group
+)
+
+// Post is what the policy needs to know about a post
+type Post struct {
+	AuthorID int
+	Privacy  string // public, semi-private, private
+	InGroup  bool
+}
+
+// Audience returns which rule applies to the post
+func (p Post) Audience() string {
+	if p.InGroup {
+		return AudienceGroup
+	}
+	return p.Privacy
+}
+
+// Relation is how a viewer relates to a post's author, viewer list and group
+type Relation struct {
+	Follower       bool // follows the author, and the follow was accepted
+	SelectedViewer bool // listed in post_private_viewers
+	GroupMember    bool // accepted member of the post's group
+}
+
+type rule struct {
+	allows func(Relation) bool
+	// match selects posts with this audience from posts aliased p
+	match string
+	// viewer selects those posts the viewer may see; each ? is the viewer's user_id
+	viewer string
+}
+
+var rules = map[string]rule{
+	AudiencePublic: {
+		allows: func(Relation) bool { return true },
+		match:  `p.group_id IS NULL AND p.privacy = 'public'`,
+		viewer: `1 = 1`,
+	},
+	AudienceFollowers: {
+		allows: func(rel Relation) bool { return rel.Follower },
+		match:  `p.group_id IS NULL AND p.privacy = 'semi-private'`,
+		viewer: `EXISTS (SELECT 1 FROM follows fo WHERE fo.followed_user_id = p.poster_id AND fo.follower_user_id = ? AND fo.status = 'accepted')`,
+	},
+	AudienceSelected: {
+		allows: func(rel Relation) bool { return rel.SelectedViewer },
+		match:  `p.group_id IS NULL AND p.privacy = 'private'`,
+		viewer: `EXISTS (SELECT 1 FROM post_private_viewers pv WHERE pv.post_id = p.post_id AND pv.user_id = ?)`,
+	},
+	AudienceGroup: {
+		allows: func(rel Relation) bool { return rel.GroupMember },
+		match:  `p.group_id IS NOT NULL`,
+		viewer: `EXISTS (SELECT 1 FROM group_members gm WHERE gm.group_id = p.group_id AND gm.member_id = ? AND gm.status = 'accepted')`,
+	},
+}
+
+// ruleOrder keeps the generated SQL stable
+var ruleOrder = []string{AudiencePublic, AudienceFollowers, AudienceSelected, AudienceGroup}
+
+// CanView reports whether the viewer may see the post; viewerID is 0 for anonymous requests.
+// Authors always see their own posts.
+func CanView(post Post, viewerID int, rel Relation) bool {
+	if viewerID != 0 && viewerID == post.AuthorID {
+		return true
+	}
+	r, ok := rules[post.Audience()]
+	if !ok {
+		return false
+	}
+	return r.allows(rel)
+}
+
+// CanComment reports whether the viewer may comment on the post: any signed-in user who can see it
+func CanComment(post Post, viewerID int, rel Relation) bool {
+	return viewerID != 0 && CanView(post, viewerID, rel)
+}
+
+// CanReact reports whether the viewer may like or dislike the post or its comments,
+// which follows the same rule as commenting
+func CanReact(post Post, viewerID int, rel Relation) bool {
+	return CanComment(post, viewerID, rel)
+}
+
+// VisibleSQL is CanView as a condition on posts aliased p. Bind it with VisibleArgs.
+var VisibleSQL, visibleParams = buildVisibleSQL()
+
+// VisibleArgs returns the arguments for VisibleSQL
+func VisibleArgs(viewerID int) []interface{} {
+	args := make([]interface{}, visibleParams)
+	for i := range args {
+		args[i] = viewerID
+	}
+	return args
+}
+
+func buildVisibleSQL() (string, int) {
+	conditions := []string{`p.poster_id = ?`}
+	for _, audience := range ruleOrder {
+		r := rules[audience]
+		conditions = append(conditions, "("+r.match+" AND "+r.viewer+")")
+	}
+	sql := "(\n            " + strings.Join(conditions, "\n            OR ") + "\n        )"
+	return sql, strings.Count(sql, "?")
+}
@@ -0,0 +1,175 @@
+package policy
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// The fixture: user 1 writes one post of each audience. User 2 follows
+// them, user 3 is chosen for the private post, user 4 is a member of the
+// group and user 5 has no relation to any of it.
+const (
+	author    = 1
+	follower  = 2
+	selected  = 3
+	member    = 4
+	stranger  = 5
+	anonymous = 0
+)
+
+type fixturePost struct {
+	id      int
+	privacy string
+	groupID int // 0 outside groups
+}
+
+var fixturePosts = []fixturePost{
+	{id: 1, privacy: "public"},
+	{id: 2, privacy: "semi-private"},
+	{id: 3, privacy: "private"},
+	// Group posts follow the group whatever their privacy value
+	{id: 4, privacy: "public", groupID: 1},
+}
+
+func (fp fixturePost) post() Post {
+	return Post{AuthorID: author, Privacy: fp.privacy, InGroup: fp.groupID != 0}
+}
+
+// relation is what the handlers would load about the viewer for the post
+func (fp fixturePost) relation(viewerID int) Relation {
+	return Relation{
+		Follower:       viewerID == follower,
+		SelectedViewer: viewerID == selected && fp.privacy == "private" && fp.groupID == 0,
+		GroupMember:    viewerID == member && fp.groupID != 0,
+	}
+}
+
+func TestPostPermissions(t *testing.T) {
+	public, followers, private, group := fixturePosts[0], fixturePosts[1], fixturePosts[2], fixturePosts[3]
+	tests := []struct {
+		post   fixturePost
+		viewer int
+		// view is the expected CanView; comment is CanComment and CanReact
+		view, comment bool
+	}{
+		{public, author, true, true},
+		{public, follower, true, true},
+		{public, selected, true, true},
+		{public, member, true, true},
+		{public, stranger, true, true},
+		{public, anonymous, true, false},
+
+		{followers, author, true, true},
+		{followers, follower, true, true},
+		{followers, selected, false, false},
+		{followers, member, false, false},
+		{followers, stranger, false, false},
+		{followers, anonymous, false, false},
+
+		{private, author, true, true},
+		{private, follower, false, false},
+		{private, selected, true, true},
+		{private, member, false, false},
+		{private, stranger, false, false},
+		{private, anonymous, false, false},
+
+		{group, author, true, true},
+		{group, follower, false, false},
+		{group, selected, false, false},
+		{group, member, true, true},
+		{group, stranger, false, false},
+		{group, anonymous, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/viewer%d", tt.post.post().Audience(), tt.viewer), func(t *testing.T) {
+			post, rel := tt.post.post(), tt.post.relation(tt.viewer)
+			if got := CanView(post, tt.viewer, rel); got != tt.view {
+				t.Errorf("CanView = %v, want %v", got, tt.view)
+			}
+			if got := CanComment(post, tt.viewer, rel); got != tt.comment {
+				t.Errorf("CanComment = %v, want %v", got, tt.comment)
+			}
+			if got := CanReact(post, tt.viewer, rel); got != tt.comment {
+				t.Errorf("CanReact = %v, want %v", got, tt.comment)
+			}
+		})
+	}
+}
+
+func TestUnknownAudienceIsHidden(t *testing.T) {
+	post := Post{AuthorID: author, Privacy: "friends"}
+	if CanView(post, stranger, Relation{Follower: true, SelectedViewer: true}) {
+		t.Error("CanView allowed a post with an unknown privacy")
+	}
+	if !CanView(post, author, Relation{}) {
+		t.Error("CanView hid a post from its author")
+	}
+}
+
+// TestVisibleSQLMatchesCanView runs VisibleSQL over the fixture and checks
+// that it selects exactly the posts CanView allows
+func TestVisibleSQLMatchesCanView(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	statements := []string{
+		`CREATE TABLE posts (post_id INTEGER PRIMARY KEY, poster_id INTEGER, privacy TEXT, group_id INTEGER)`,
+		`CREATE TABLE follows (follower_user_id INTEGER, followed_user_id INTEGER, status TEXT)`,
+		`CREATE TABLE post_private_viewers (post_id INTEGER, user_id INTEGER)`,
+		`CREATE TABLE group_members (group_id INTEGER, member_id INTEGER, status TEXT)`,
+		fmt.Sprintf(`INSERT INTO follows VALUES (%d, %d, 'accepted')`, follower, author),
+		// Follow requests and group invitations that were not accepted grant nothing
+		fmt.Sprintf(`INSERT INTO follows VALUES (%d, %d, 'pending')`, stranger, author),
+		fmt.Sprintf(`INSERT INTO post_private_viewers VALUES (3, %d)`, selected),
+		fmt.Sprintf(`INSERT INTO group_members VALUES (1, %d, 'accepted')`, member),
+		fmt.Sprintf(`INSERT INTO group_members VALUES (1, %d, 'invited')`, stranger),
+	}
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+	}
+	for _, fp := range fixturePosts {
+		var groupID interface{}
+		if fp.groupID != 0 {
+			groupID = fp.groupID
+		}
+		if _, err := db.Exec(`INSERT INTO posts VALUES (?, ?, ?, ?)`, fp.id, author, fp.privacy, groupID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, viewer := range []int{author, follower, selected, member, stranger, anonymous} {
+		rows, err := db.Query(`SELECT p.post_id FROM posts p WHERE `+VisibleSQL, VisibleArgs(viewer)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		selectedBySQL := map[int]bool{}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				t.Fatal(err)
+			}
+			selectedBySQL[id] = true
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		rows.Close()
+
+		for _, fp := range fixturePosts {
+			want := CanView(fp.post(), viewer, fp.relation(viewer))
+			if selectedBySQL[fp.id] != want {
+				t.Errorf("viewer %d, post %d (%s): VisibleSQL = %v, CanView = %v",
+					viewer, fp.id, fp.post().Audience(), selectedBySQL[fp.id], want)
+			}
+		}
+	}
+}
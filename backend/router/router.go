@@ -0,0 +1,159 @@
+// Package router registers the API on method-aware http.ServeMux patterns.
+// Every route is declared once in a table with its method, path and the
+// authentication it needs; the mux answers 405 with an Allow header for
+// known paths with the wrong method.
+package router
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"social_network/dbTools"
+	"social_network/logging"
+	"social_network/middleware"
+	"social_network/utils"
+	"strings"
+	"text/tabwriter"
+)
+
+// Auth is how much authentication a route needs
+type Auth int
+
+const (
+	Public   Auth = iota // no principal is resolved
+	Optional             // the principal is resolved when there is one
+	Required             // anonymous requests get a 401
+)
+
+func (a Auth) String() string {
+	switch a {
+	case Optional:
+		return "optional"
+	case Required:
+		return "required"
+	default:
+		return "public"
+	}
+}
+
+// Route is one entry of the route table
+type Route struct {
+	Method  string // GET, POST, PUT or DELETE
+	Path    string // ServeMux path pattern, e.g. /api/v1/groups/{id}/members
+	Auth    Auth
+	Handler http.HandlerFunc
+	// Legacy are older paths for the same route. They keep working but their
+	// responses carry a Deprecation header and a Link to Path.
+	Legacy []string
+}
+
+// Router serves a route table
+type Router struct {
+	mux    *http.ServeMux
+	routes []Route
+}
+
+// New registers the routes on a fresh ServeMux. It panics on conflicting
+// patterns, like http.ServeMux does.
+func New(db *dbTools.DB, routes []Route) *Router {
+	rt := &Router{mux: http.NewServeMux(), routes: routes}
+	for _, route := range routes {
+		handler := withAuth(db, route.Auth, route.Handler)
+		rt.mux.HandleFunc(route.Method+" "+route.Path, named(route.Path, handler))
+		for _, legacy := range route.Legacy {
+			rt.mux.HandleFunc(route.Method+" "+legacy, named(legacy, deprecated(route.Path, handler)))
+		}
+	}
+	return rt
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := rt.mux.Handler(r); pattern == "" {
+		rt.unmatched(w, r)
+		return
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+// unmatched answers requests no route matched with the API's JSON error. The
+// mux still decides between 404 and 405 and sets the Allow header.
+func (rt *Router) unmatched(w http.ResponseWriter, r *http.Request) {
+	rec := &headerRecorder{header: w.Header()}
+	rt.mux.ServeHTTP(rec, r)
+	if rec.status == http.StatusMethodNotAllowed {
+		utils.SendErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	utils.SendErrorResponse(w, http.StatusNotFound, "Not found")
+}
+
+// headerRecorder keeps the headers and status the mux writes and drops the
+// plain text body
+type headerRecorder struct {
+	header http.Header
+	status int
+}
+
+func (rec *headerRecorder) Header() http.Header         { return rec.header }
+func (rec *headerRecorder) WriteHeader(status int)      { rec.status = status }
+func (rec *headerRecorder) Write(b []byte) (int, error) { return len(b), nil }
+
+// Routes returns the route table
+func (rt *Router) Routes() []Route {
+	return rt.routes
+}
+
+// WriteTable lists the routes, one per line
+func (rt *Router) WriteTable(out io.Writer) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tAUTH\tDEPRECATED ALIASES")
+	for _, route := range rt.routes {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", route.Method, route.Path, route.Auth, strings.Join(route.Legacy, ", "))
+	}
+	return tw.Flush()
+}
+
+func withAuth(db *dbTools.DB, auth Auth, next http.HandlerFunc) http.HandlerFunc {
+	switch auth {
+	case Optional:
+		return middleware.OptionalAuth(db, next)
+	case Required:
+		return middleware.RequireAuth(db, next)
+	default:
+		return next
+	}
+}
+
+// named records the matched path pattern, which labels the access log line and
+// the request metrics without the IDs of the actual path
+func named(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logging.SetRoute(r.Context(), pattern)
+		next(w, r)
+	}
+}
+
+// deprecated marks responses to a legacy path and points at its replacement
+func deprecated(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+expand(successor, r)+`>; rel="successor-version"`)
+		next(w, r)
+	}
+}
+
+// expand fills the wildcards of a path pattern with the request's path values
+func expand(pattern string, r *http.Request) string {
+	segments := strings.Split(pattern, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			name := strings.TrimSuffix(strings.Trim(segment, "{}"), "...")
+			if name != "$" {
+				segments[i] = r.PathValue(name)
+			} else {
+				segments[i] = ""
+			}
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// AccessTokenPrefix marks personal access tokens so they are easy to spot in
+// scripts and by secret scanners
+const AccessTokenPrefix = "snpat_"
+
+// Personal access token scopes
+const (
+	ScopeRead       = "read"        // GET requests outside chat and admin
+	ScopeWritePosts = "write:posts" // create, edit and delete posts and comments, and react to them
+	ScopeChat       = "chat"        // chat history and the websocket
+	ScopeAdmin      = "admin"       // admin endpoints and /metrics, only for admins
+)
+
+// AccessTokenScopes lists every scope a token can be granted
+var AccessTokenScopes = []string{ScopeRead, ScopeWritePosts, ScopeChat, ScopeAdmin}
+
+// sessionOnlyPaths manage the account itself and are never available to
+// access tokens, so a leaked token cannot be turned into a full takeover
+var sessionOnlyPaths = []string{
+	"/api/login",
+	"/api/logout",
+	"/api/register",
+	"/api/session-check",
+	"/api/sessions",
+	"/api/tokens",
+	"/api/2fa/",
+	"/api/account/",
+	"/api/email/",
+	"/api/password/",
+	"/api/auth/",
+}
+
+var postWritePaths = []string{
+	"/api/createposts",
+	"/api/createcomment",
+	"/api/posts",    // /api/v1/posts, /api/v1/posts/{uuid} and its comments and reactions
+	"/api/comments", // /api/v1/comments/{id} and its reactions
+}
+
+// IsValidScope reports whether scope is a known access token scope
+func IsValidScope(scope string) bool {
+	for _, s := range AccessTokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiredScope returns the scope an access token needs for a request.
+// ok is false for endpoints that cannot be used with an access token at all.
+func RequiredScope(method, path string) (scope string, ok bool) {
+	switch {
+	case MatchesPath(path, sessionOnlyPaths...):
+		return "", false
+	case MatchesPath(path, "/api/admin/", "/metrics"):
+		return ScopeAdmin, true
+	case MatchesPath(path, "/api/ws", "/api/messages/"):
+		return ScopeChat, true
+	case method == http.MethodGet || method == http.MethodHead:
+		return ScopeRead, true
+	case (method == http.MethodPost || method == http.MethodPut || method == http.MethodDelete) && MatchesPath(path, postWritePaths...):
+		return ScopeWritePosts, true
+	default:
+		return "", false
+	}
+}
+
+// BearerToken returns the token from an "Authorization: Bearer" header, or ""
+func BearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+type accessTokenUserKey struct{}
+
+// WithAccessTokenUser marks the request context as authenticated by an access token
+func WithAccessTokenUser(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, accessTokenUserKey{}, userID)
+}
+
+// AccessTokenUserID returns the user authenticated by an access token, if any
+func AccessTokenUserID(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(accessTokenUserKey{}).(int)
+	return userID, ok
+}
+
+// MatchesPath reports whether path equals one of prefixes or lies below it.
+// Prefixes are written without the API version: /api/v1/tokens matches "/api/tokens".
+func MatchesPath(path string, prefixes ...string) bool {
+	if rest, ok := strings.CutPrefix(path, "/api/v1"); ok && (rest == "" || rest[0] == '/') {
+		path = "/api" + rest
+	}
+	for _, p := range prefixes {
+		if path == p || (strings.HasPrefix(path, p) && (strings.HasSuffix(p, "/") || path[len(p)] == '/')) {
+			return true
+		}
+	}
+	return false
+}
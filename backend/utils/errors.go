@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Error codes of API error responses. Clients branch on the code rather than
+// the message, so a code never changes meaning once it is released.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeConflict         = "conflict"
+	CodeGone             = "gone"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeTooManyRequests  = "too_many_requests"
+	CodeInternal         = "internal_error"
+	CodeUnavailable      = "service_unavailable"
+
+	CodeInvalidCredentials = "invalid_credentials"
+	CodeLoginBlocked       = "login_blocked"
+	CodeEmailNotVerified   = "email_not_verified"
+	CodeInvalidAccessToken = "invalid_access_token"
+	CodeInsufficientScope  = "insufficient_scope"
+	CodeCrossSiteRequest   = "cross_site_request"
+)
+
+// Codes of field errors
+const (
+	FieldRequired = "required"
+	FieldTooShort = "too_short"
+	FieldTooLong  = "too_long"
+	FieldFormat   = "invalid_format"
+	FieldTaken    = "taken"
+	FieldWeak     = "too_weak"
+	FieldInvalid  = "invalid"
+)
+
+// APIError is an error answered to the client. Every error response has the
+// same shape:
+//
+//	{"success": false, "code": "validation_failed", "message": "...",
+//	 "fields": [{"field": "email", "code": "invalid_format", "message": "..."}]}
+type APIError struct {
+	Status  int          `json:"-"`
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// NewAPIError returns an error with a specific code
+func NewAPIError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// FieldError is what is wrong with one field of a request, for the frontend
+// to show next to that field
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// FieldErrors collects the field errors of a request
+type FieldErrors []FieldError
+
+// Check records err, usually returned by a validation helper, against field.
+// A nil err is ignored; errors that are not a *FieldError get the invalid code.
+func (f *FieldErrors) Check(field string, err error) {
+	if err == nil {
+		return
+	}
+	fe := FieldError{Field: field, Code: FieldInvalid, Message: err.Error()}
+	var known *FieldError
+	if errors.As(err, &known) {
+		fe.Code = known.Code
+	}
+	*f = append(*f, fe)
+}
+
+// Add records a field error that has no validation helper
+func (f *FieldErrors) Add(field, code, message string) {
+	*f = append(*f, FieldError{Field: field, Code: code, Message: message})
+}
+
+// Err returns a validation error listing the fields, or nil when there are none
+func (f FieldErrors) Err() *APIError {
+	if len(f) == 0 {
+		return nil
+	}
+	messages := make([]string, len(f))
+	for i, fe := range f {
+		messages[i] = fe.Message
+	}
+	return &APIError{
+		Status:  http.StatusBadRequest,
+		Code:    CodeValidation,
+		Message: strings.Join(messages, "; "),
+		Fields:  f,
+	}
+}
+
+// InvalidField is the validation error for a single field
+func InvalidField(field string, err error) *APIError {
+	var f FieldErrors
+	f.Check(field, err)
+	return f.Err()
+}
+
+// CodeForStatus is the code of errors that have nothing more specific
+func CodeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusGone:
+		return CodeGone
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusTooManyRequests:
+		return CodeTooManyRequests
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// SendError writes err as a JSON error response
+func SendError(w http.ResponseWriter, err *APIError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Status)
+	json.NewEncoder(w).Encode(struct {
+		Success bool `json:"success"`
+		*APIError
+	}{false, err})
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MaxTagLength is the longest category or hashtag, in characters
+	MaxTagLength = 32
+	// MaxPostTags is how many categories and hashtags a post can have together
+	MaxPostTags = 10
+)
+
+// hashtagPattern finds #tags that start a word. "&#39;" and other character
+// references left by Sanitize are not tags, nor is the second # in "a##b".
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+
+// NormalizeTag turns a category or hashtag into the form it is stored and
+// searched in: without the leading #, in lower case
+func NormalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" {
+		return "", &FieldError{Code: FieldRequired, Message: "Tag cannot be empty"}
+	}
+	if len([]rune(tag)) > MaxTagLength {
+		return "", &FieldError{Code: FieldTooLong, Message: fmt.Sprintf("Tags must be at most %d characters", MaxTagLength)}
+	}
+	for _, c := range tag {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' {
+			return "", &FieldError{Code: FieldFormat, Message: "Tags can only contain letters, digits and _"}
+		}
+	}
+	return tag, nil
+}
+
+// ExtractHashtags returns the normalized #hashtags of a text, without
+// duplicates, in the order they first appear. Run it on the text before
+// Sanitize. Hashtags that are too long are skipped.
+func ExtractHashtags(text string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, m := range hashtagPattern.FindAllStringSubmatch(text, -1) {
+		tag, err := NormalizeTag(m[1])
+		if err != nil || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// MergeTags combines categories and hashtags, keeping the first occurrence of each
+func MergeTags(lists ...[]string) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, list := range lists {
+		for _, tag := range list {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
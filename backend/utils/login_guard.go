@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Brute-force protection settings.
+// Failed attempts are counted per account (since its last successful login)
+// and per client IP, inside a sliding window.
+const (
+	LoginFailureWindow = 15 * time.Minute
+
+	// After LoginDelayThreshold failures, each further attempt must wait
+	// LoginBaseDelay, doubling per failure up to LoginMaxDelay.
+	LoginDelayThreshold = 3
+	LoginBaseDelay      = time.Second
+	LoginMaxDelay       = 30 * time.Second
+
+	AccountLockoutThreshold = 10
+	AccountLockoutDuration  = 15 * time.Minute
+
+	IPLockoutThreshold = 50
+	IPLockoutDuration  = 15 * time.Minute
+)
+
+// Login attempt failure reasons stored in login_attempts.failure_reason
+const (
+	LoginFailureInvalidCredentials = "invalid_credentials"
+	LoginFailureThrottled          = "throttled"
+	LoginFailureLocked             = "locked"
+)
+
+// ErrAccountLocked is returned when an account is temporarily locked after too many failed logins
+var ErrAccountLocked = errors.New("account temporarily locked")
+
+// LoginBlockedError describes why a login attempt was rejected before checking the password
+type LoginBlockedError struct {
+	Locked     bool // true for lockout, false for a progressive delay
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed login attempts, try again in %d seconds", e.RetryAfterSeconds())
+	}
+	return fmt.Sprintf("too many login attempts, wait %d seconds", e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds returns the wait time rounded up to whole seconds, for the Retry-After header
+func (e *LoginBlockedError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Reason returns the login_attempts failure_reason for the block
+func (e *LoginBlockedError) Reason() string {
+	if e.Locked {
+		return LoginFailureLocked
+	}
+	return LoginFailureThrottled
+}
+
+// NormalizeEmail lowercases and trims an email so attempts are counted per address
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CheckLoginAllowed decides whether a login attempt for email from ip may proceed.
+// It returns a *LoginBlockedError if the attempt must be rejected.
+func CheckLoginAllowed(db *sql.DB, email, ip string) error {
+	now := time.Now()
+
+	accountFailures, accountLast, err := countAccountFailures(db, NormalizeEmail(email))
+	if err != nil {
+		return err
+	}
+	ipFailures, ipLast, err := countIPFailures(db, ip)
+	if err != nil {
+		return err
+	}
+
+	var blocked *LoginBlockedError
+	consider := func(b *LoginBlockedError) {
+		if b == nil {
+			return
+		}
+		if blocked == nil || b.RetryAfter > blocked.RetryAfter {
+			blocked = b
+		}
+	}
+	consider(lockoutFor(accountFailures, accountLast, AccountLockoutThreshold, AccountLockoutDuration, now))
+	consider(lockoutFor(ipFailures, ipLast, IPLockoutThreshold, IPLockoutDuration, now))
+	if blocked != nil {
+		return blocked
+	}
+
+	consider(delayFor(accountFailures, accountLast, now))
+	consider(delayFor(ipFailures, ipLast, now))
+	if blocked != nil {
+		return blocked
+	}
+	return nil
+}
+
+// CheckAccountLockout returns ErrAccountLocked if the user's account is currently locked.
+// It is used on the session-creation path so no login flow can bypass the lockout.
+func CheckAccountLockout(db *sql.DB, userID int64) error {
+	var email string
+	if err := db.QueryRow(`SELECT email FROM users WHERE user_id = ?`, userID).Scan(&email); err != nil {
+		return fmt.Errorf("failed to look up user: %w", err)
+	}
+
+	failures, last, err := countAccountFailures(db, NormalizeEmail(email))
+	if err != nil {
+		return err
+	}
+	if lockoutFor(failures, last, AccountLockoutThreshold, AccountLockoutDuration, time.Now()) != nil {
+		return ErrAccountLocked
+	}
+	return nil
+}
+
+// RecordLoginAttempt stores a login attempt. userID is 0 when the email is unknown.
+func RecordLoginAttempt(db *sql.DB, r *http.Request, email string, userID int, success bool, failureReason string) {
+	var uid sql.NullInt64
+	if userID > 0 {
+		uid = sql.NullInt64{Int64: int64(userID), Valid: true}
+	}
+	_, err := db.Exec(`INSERT INTO login_attempts (email, user_id, ip_address, user_agent, success, failure_reason)
+	                   VALUES (?, ?, ?, ?, ?, ?)`,
+		NormalizeEmail(email), uid, ClientIP(r), NullIfEmpty(r.UserAgent()), success, NullIfEmpty(failureReason))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Login attempt record failed", "user_id", userID, "err", err)
+	}
+}
+
+// countAccountFailures counts invalid-credential failures for an email inside the
+// window and since the last successful login, and returns the time of the latest one.
+func countAccountFailures(db *sql.DB, email string) (int, time.Time, error) {
+	return countFailures(db, `
+        SELECT COUNT(*), COALESCE(CAST(strftime('%s', MAX(created_at)) AS INTEGER), 0)
+        FROM login_attempts
+        WHERE email = ? AND success = 0 AND failure_reason = 'invalid_credentials'
+          AND created_at > datetime('now', ?)
+          AND created_at > COALESCE((SELECT MAX(created_at) FROM login_attempts WHERE email = ? AND success = 1), '')
+    `, email, windowModifier(), email)
+}
+
+// countIPFailures counts invalid-credential failures from an IP inside the window.
+// A successful login does not reset it, so an attacker cannot clear the counter
+// by logging into their own account.
+func countIPFailures(db *sql.DB, ip string) (int, time.Time, error) {
+	return countFailures(db, `
+        SELECT COUNT(*), COALESCE(CAST(strftime('%s', MAX(created_at)) AS INTEGER), 0)
+        FROM login_attempts
+        WHERE ip_address = ? AND success = 0 AND failure_reason = 'invalid_credentials'
+          AND created_at > datetime('now', ?)
+    `, ip, windowModifier())
+}
+
+func countFailures(db *sql.DB, query string, args ...interface{}) (int, time.Time, error) {
+	var count int
+	var lastUnix int64
+	if err := db.QueryRow(query, args...).Scan(&count, &lastUnix); err != nil {
+		return 0, time.Time{}, fmt.Errorf("failed to count login failures: %w", err)
+	}
+	return count, time.Unix(lastUnix, 0), nil
+}
+
+func windowModifier() string {
+	return fmt.Sprintf("-%d seconds", int(LoginFailureWindow.Seconds()))
+}
+
+func lockoutFor(failures int, last time.Time, threshold int, duration time.Duration, now time.Time) *LoginBlockedError {
+	if failures < threshold {
+		return nil
+	}
+	if until := last.Add(duration); now.Before(until) {
+		return &LoginBlockedError{Locked: true, RetryAfter: until.Sub(now)}
+	}
+	return nil
+}
+
+func delayFor(failures int, last time.Time, now time.Time) *LoginBlockedError {
+	if failures < LoginDelayThreshold {
+		return nil
+	}
+	delay := LoginBaseDelay << uint(failures-LoginDelayThreshold)
+	if delay > LoginMaxDelay || delay <= 0 {
+		delay = LoginMaxDelay
+	}
+	if until := last.Add(delay); now.Before(until) {
+		return &LoginBlockedError{RetryAfter: until.Sub(now)}
+	}
+	return nil
+}
@@ -5,14 +5,10 @@ import (
 	"net/http"
 )
 
-// SendErrorResponse sends a standardized JSON error response
+// SendErrorResponse sends a standardized JSON error response with the code
+// that goes with the status
 func SendErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	w.WriteHeader(statusCode)
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": false,
-		"message": message,
-	})
+	SendError(w, NewAPIError(statusCode, CodeForStatus(statusCode), message))
 }
 
 // SendSuccessResponse sends a standardized JSON success response
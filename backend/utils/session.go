@@ -1,57 +1,110 @@
 package utils
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"social_network/config"
+	"social_network/logging"
 	"time"
 )
 
 const (
 	SessionCookieName = "session_id"
-	SessionDuration   = 24 * time.Hour
+	// sessionTouchInterval limits how often last_seen_at/expires_at are written.
+	sessionTouchInterval = time.Minute
 )
 
-// GetUserIDFromSession retrieves the user ID from the session cookie
-func GetUserIDFromSession(db *sql.DB, r *http.Request) (int, error) {
+// GetUserIDFromSession retrieves the user ID from the session cookie, or from
+// the personal access token already checked by middleware.AccessTokenAuth
+func GetUserIDFromSession(db *sql.DB, sessions config.Sessions, r *http.Request) (int, error) {
+	if userID, ok := AccessTokenUserID(r.Context()); ok {
+		return userID, nil
+	}
+
 	cookie, err := r.Cookie(SessionCookieName)
 	if err != nil {
 		return 0, fmt.Errorf("no session cookie: %w", err)
 	}
 
 	var userID int
-	query := `SELECT user_id FROM sessions WHERE session_uuid = ? AND status = 'active' AND expires_at > CURRENT_TIMESTAMP`
-	err = db.QueryRow(query, cookie.Value).Scan(&userID)
+	query := `SELECT user_id FROM sessions
+	          WHERE session_uuid = ? AND status = 'active' AND expires_at > CURRENT_TIMESTAMP AND created_at > ?`
+	err = db.QueryRow(query, cookie.Value, time.Now().UTC().Add(-sessions.MaxLifetime.Std())).Scan(&userID)
 	if err != nil {
 		return 0, fmt.Errorf("invalid or expired session: %w", err)
 	}
+
+	touchSession(r.Context(), db, sessions, cookie.Value)
 	return userID, nil
 }
 
-// CreateSession generates a new session for a user and sets the session cookie
-func CreateSession(db *sql.DB, w http.ResponseWriter, userID int64) (string, error) {
+// touchSession slides the session expiry forward and records activity.
+// Writes are throttled so that a burst of requests only updates the row once.
+func touchSession(ctx context.Context, db *sql.DB, sessions config.Sessions, sessionUUID string) {
+	now := time.Now().UTC()
+	_, err := db.Exec(`UPDATE sessions SET last_seen_at = ?, expires_at = ?
+	                   WHERE session_uuid = ? AND (last_seen_at IS NULL OR last_seen_at < ?)`,
+		now, now.Add(sessions.Duration.Std()), sessionUUID, now.Add(-sessionTouchInterval))
+	if err != nil {
+		slog.ErrorContext(ctx, "Session touch failed", "err", err)
+	}
+}
+
+// CreateSession generates a new session for a user and sets the session cookie.
+// The user agent and client IP of the request are stored with the session so
+// the user can recognise it in the sessions list.
+// It returns ErrAccountLocked if the account is locked after failed logins.
+func CreateSession(db *sql.DB, sessions config.Sessions, w http.ResponseWriter, r *http.Request, userID int64) (string, error) {
+	if err := CheckAccountLockout(db, userID); err != nil {
+		return "", err
+	}
+
 	sessionUUID, err := GenerateUUID()
 	if err != nil {
 		return "", fmt.Errorf("failed to generate session UUID: %w", err)
 	}
+	publicUUID, err := GenerateUUID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate session UUID: %w", err)
+	}
 
-	expiresAt := time.Now().Add(SessionDuration)
-	_, err = db.Exec("INSERT INTO sessions (session_uuid, user_id, status, created_at, expires_at) VALUES (?, ?, 'active', CURRENT_TIMESTAMP, ?)",
-		sessionUUID, userID, expiresAt)
+	now := time.Now().UTC()
+	_, err = db.Exec(`INSERT INTO sessions (session_uuid, public_uuid, user_id, status, user_agent, ip_address, created_at, last_seen_at, expires_at)
+	                  VALUES (?, ?, ?, 'active', ?, ?, CURRENT_TIMESTAMP, ?, ?)`,
+		sessionUUID, publicUUID, userID, NullIfEmpty(r.UserAgent()), NullIfEmpty(ClientIP(r)), now, now.Add(sessions.Duration.Std()))
 	if err != nil {
 		return "", fmt.Errorf("failed to create session: %w", err)
 	}
 
-	SetSessionCookie(w, sessionUUID)
+	SetSessionCookie(w, sessionUUID, sessions.MaxLifetime.Std())
+	logging.SetUserID(r.Context(), int(userID))
 	return sessionUUID, nil
 }
 
-// SetSessionCookie sets the session cookie in the response
-func SetSessionCookie(w http.ResponseWriter, sessionUUID string) {
+// SetSessionCookie sets the session cookie in the response.
+// The cookie lives for the maximum session lifetime; the idle timeout is
+// enforced server-side through expires_at.
+func SetSessionCookie(w http.ResponseWriter, sessionUUID string, maxLifetime time.Duration) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     SessionCookieName,
 		Value:    sessionUUID,
-		Expires:  time.Now().Add(SessionDuration),
+		Expires:  time.Now().Add(maxLifetime),
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// ClearSessionCookie expires the session cookie in the browser
+func ClearSessionCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
 		Path:     "/",
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
@@ -70,13 +123,24 @@ func ClearSession(db *sql.DB, w http.ResponseWriter, r *http.Request) error {
 		return fmt.Errorf("failed to invalidate session: %w", err)
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:     SessionCookieName,
-		Value:    "",
-		Expires:  time.Now().Add(-time.Hour),
-		Path:     "/",
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-	})
+	ClearSessionCookie(w)
 	return nil
 }
+
+// GetSessionUUID returns the session UUID from the request cookie, or "" if there is none
+func GetSessionUUID(r *http.Request) string {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// ClientIP returns the IP address of the client that sent the request
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
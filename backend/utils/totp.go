@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RFC 6238 parameters. These are the defaults every authenticator app supports.
+const (
+	TOTPIssuer   = "Social Network"
+	TOTPDigits   = 6
+	TOTPPeriod   = 30 // seconds
+	TOTPSkew     = 1  // accepted steps before/after the current one
+	totpSecretSz = 20 // bytes, as recommended by RFC 4226
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a new random base32-encoded TOTP secret
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, totpSecretSz)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return totpEncoding.EncodeToString(b), nil
+}
+
+// TOTPURI builds the otpauth:// URI that authenticator apps import (usually as a QR code)
+func TOTPURI(accountName, secret string) string {
+	label := url.PathEscape(TOTPIssuer) + ":" + url.PathEscape(accountName)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", TOTPIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(TOTPDigits))
+	params.Set("period", fmt.Sprint(TOTPPeriod))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// TOTPStep returns the RFC 6238 time step for t
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / TOTPPeriod
+}
+
+// TOTPCode computes the code for a given time step
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", fmt.Errorf("invalid TOTP secret: %w", err)
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%mod), nil
+}
+
+// ValidateTOTP checks code against the steps around t. It returns the matching
+// step so callers can reject replays of a code that was already used.
+func ValidateTOTP(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+
+	current := TOTPStep(t)
+	for i := -TOTPSkew; i <= TOTPSkew; i++ {
+		step := current + int64(i)
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns n one-time recovery codes formatted as xxxxx-xxxxx
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, fmt.Errorf("failed to generate recovery code: %w", err)
+		}
+		raw := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode makes recovery code comparison insensitive to case, spaces and dashes
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, " ", "")
+	return strings.ReplaceAll(code, "-", "")
+}
@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the RFC 6238 SHA-1 test key "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestTOTPCodeRFC6238 checks the SHA-1 vectors of RFC 6238 appendix B. The RFC
+// lists 8 digit codes; ours are their last 6 digits.
+func TestTOTPCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPCodeInvalidSecret(t *testing.T) {
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode accepted a secret that is not base32")
+	}
+}
+
+func TestValidateTOTPWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+	code := func(step int64) string {
+		c, err := TOTPCode(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		offset int64
+		ok     bool
+	}{
+		{"two steps early", -2, false},
+		{"one step early", -1, true},
+		{"current step", 0, true},
+		{"one step late", 1, true},
+		{"two steps late", 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(rfcSecret, code(current+tt.offset), now)
+			if ok != tt.ok {
+				t.Fatalf("ValidateTOTP ok = %v, want %v", ok, tt.ok)
+			}
+			// The matching step is what the caller stores to reject replays
+			if ok && step != current+tt.offset {
+				t.Errorf("ValidateTOTP step = %d, want %d", step, current+tt.offset)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPInput(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	tests := []struct {
+		code string
+		ok   bool
+	}{
+		{"050471", true},
+		{" 050 471 ", true},
+		{"50471", false},
+		{"0504710", false},
+		{"", false},
+		{"000000", false},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(rfcSecret, tt.code, now); ok != tt.ok {
+			t.Errorf("ValidateTOTP(%q) = %v, want %v", tt.code, ok, tt.ok)
+		}
+	}
+	if _, ok := ValidateTOTP("not base32!", "050471", now); ok {
+		t.Error("ValidateTOTP accepted a code for an invalid secret")
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"abcde-fghij", "abcdefghij"},
+		{"ABCDE-FGHIJ", "abcdefghij"},
+		{" abcde fghij ", "abcdefghij"},
+		{"abcdefghij", "abcdefghij"},
+		{"ab-cde fg-hij", "abcdefghij"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeRecoveryCode(tt.in); got != tt.want {
+			t.Errorf("NormalizeRecoveryCode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 10 {
+		t.Fatalf("got %d codes, want 10", len(codes))
+	}
+	seen := map[string]bool{}
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' {
+			t.Errorf("code %q is not formatted as xxxxx-xxxxx", code)
+		}
+		normalized := NormalizeRecoveryCode(code)
+		if len(normalized) != 10 {
+			t.Errorf("code %q normalizes to %q", code, normalized)
+		}
+		if seen[normalized] {
+			t.Errorf("code %q was generated twice", code)
+		}
+		seen[normalized] = true
+	}
+}
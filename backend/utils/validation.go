@@ -2,26 +2,60 @@ package utils
 
 import (
 	"database/sql"
-	"errors"
+	"fmt"
 	"regexp"
 	"strings"
+	"time"
 )
 
+// The validation helpers return a *FieldError, which FieldErrors.Check
+// records against the name of the field in the request
+
+// ValidateRequired rejects empty values. label names the field in the message.
+func ValidateRequired(label, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return &FieldError{Code: FieldRequired, Message: label + " is required"}
+	}
+	return nil
+}
+
+// ValidateText accepts non-empty text of at most max bytes
+func ValidateText(label, value string, max int) error {
+	if strings.TrimSpace(value) == "" {
+		return &FieldError{Code: FieldRequired, Message: label + " cannot be empty"}
+	}
+	if len(value) > max {
+		return &FieldError{Code: FieldTooLong, Message: fmt.Sprintf("%s must be at most %d characters", label, max)}
+	}
+	return nil
+}
+
+// ValidateDate accepts dates in the YYYY-MM-DD format
+func ValidateDate(label, value string) error {
+	if err := ValidateRequired(label, value); err != nil {
+		return err
+	}
+	if _, err := time.Parse("2006-01-02", strings.TrimSpace(value)); err != nil {
+		return &FieldError{Code: FieldFormat, Message: "Invalid " + strings.ToLower(label[:1]) + label[1:] + " format (use YYYY-MM-DD)"}
+	}
+	return nil
+}
+
 func ValidateEmail(email string) error {
 	email = strings.TrimSpace(email)
 
 	if email == "" {
-		return errors.New("email is required")
+		return &FieldError{Code: FieldRequired, Message: "email is required"}
 	}
 
 	if len(email) > 254 {
-		return errors.New("email too long")
+		return &FieldError{Code: FieldTooLong, Message: "email too long"}
 	}
 
 	// Basic email format
 	pattern := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
 	if !regexp.MustCompile(pattern).MatchString(email) {
-		return errors.New("invalid email format")
+		return &FieldError{Code: FieldFormat, Message: "invalid email format"}
 	}
 
 	return nil
@@ -31,17 +65,17 @@ func ValidatePassword(password string) error {
 	password = strings.TrimSpace(password)
 
 	if password == "" {
-		return errors.New("password is required")
+		return &FieldError{Code: FieldRequired, Message: "password is required"}
 	}
 
 	// Minimum length (industry standard)
 	if len(password) < 8 {
-		return errors.New("password must be at least 8 characters")
+		return &FieldError{Code: FieldTooShort, Message: "password must be at least 8 characters"}
 	}
 
 	// Maximum length (prevent DoS attacks)
 	if len(password) > 128 {
-		return errors.New("password too long")
+		return &FieldError{Code: FieldTooLong, Message: "password too long"}
 	}
 
 	return nil
@@ -55,33 +89,69 @@ func NullIfEmpty(s string) sql.NullString {
 	return sql.NullString{String: s, Valid: true}
 }
 
-// For registration - stronger requirements (commented out for testing)
-/*
-func ValidatePasswordStrength(password string) error {
-	// First do basic validation
-	if err := ValidatePassword(password); err != nil {
-		return err
+// PasswordPolicy describes the complexity rules for new passwords, on top of
+// the length limits in ValidatePassword
+type PasswordPolicy struct {
+	MinLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireNumber  bool
+	RequireSpecial bool
+}
+
+var (
+	// BasicPasswordPolicy only enforces the minimum length
+	BasicPasswordPolicy = PasswordPolicy{MinLength: 8}
+	// StrongPasswordPolicy requires every character class
+	StrongPasswordPolicy = PasswordPolicy{MinLength: 10, RequireUpper: true, RequireLower: true, RequireNumber: true, RequireSpecial: true}
+)
+
+// PasswordPolicyByName returns the policy for "basic" or "strong"
+func PasswordPolicyByName(name string) (PasswordPolicy, error) {
+	switch name {
+	case "", "basic":
+		return BasicPasswordPolicy, nil
+	case "strong":
+		return StrongPasswordPolicy, nil
+	default:
+		return PasswordPolicy{}, fmt.Errorf("unknown password policy %q", name)
+	}
+}
+
+// Validate checks password against the policy
+func (p PasswordPolicy) Validate(password string) error {
+	if len(password) < p.MinLength {
+		return &FieldError{Code: FieldTooShort, Message: fmt.Sprintf("password must be at least %d characters", p.MinLength)}
 	}
 
-	// Check complexity
 	hasUpper := regexp.MustCompile(`[A-Z]`).MatchString(password)
 	hasLower := regexp.MustCompile(`[a-z]`).MatchString(password)
 	hasNumber := regexp.MustCompile(`[0-9]`).MatchString(password)
 	hasSpecial := regexp.MustCompile(`[!@#$%^&*()_+\-=\[\]{};':"\\|,.<>\/?]`).MatchString(password)
 
-	if !hasUpper {
-		return errors.New("password must contain at least one uppercase letter")
+	if p.RequireUpper && !hasUpper {
+		return &FieldError{Code: FieldWeak, Message: "password must contain at least one uppercase letter"}
 	}
-	if !hasLower {
-		return errors.New("password must contain at least one lowercase letter")
+	if p.RequireLower && !hasLower {
+		return &FieldError{Code: FieldWeak, Message: "password must contain at least one lowercase letter"}
 	}
-	if !hasNumber {
-		return errors.New("password must contain at least one number")
+	if p.RequireNumber && !hasNumber {
+		return &FieldError{Code: FieldWeak, Message: "password must contain at least one number"}
 	}
-	if !hasSpecial {
-		return errors.New("password must contain at least one special character")
+	if p.RequireSpecial && !hasSpecial {
+		return &FieldError{Code: FieldWeak, Message: "password must contain at least one special character"}
 	}
 
 	return nil
 }
-*/
+
+// ValidatePasswordStrength validates a new password (registration, reset or
+// change) against the basic rules and the configured password policy
+func ValidatePasswordStrength(password string, policy PasswordPolicy) error {
+	// First do basic validation
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+
+	return policy.Validate(password)
+}
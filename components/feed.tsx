@@ -16,6 +16,7 @@ import {
 } from "lucide-react";
 import { sanitize } from "@/utils/sanitize";
 import { formatDateTime } from "@/utils/formatDate";
+import { fetchPostsPage, fetchCommentsPage } from "@/lib/posts";
 
 
 interface Comment {
@@ -46,7 +47,8 @@ export interface Post {
   file_id?: number;
   filename_new?: string;
   avatar?: string;
-  comments?: Comment[];
+  comments?: Comment[]; // the latest few, newest first
+  comment_count?: number;
 }
 
 // Reusable Avatar component
@@ -83,6 +85,10 @@ export function Feed({
 }) {
   const [content, setContent] = useState("");
   const [posts, setPosts] = useState<Post[]>([]);
+  const [nextCursor, setNextCursor] = useState<string | null>(null);
+  const [loadingMore, setLoadingMore] = useState(false);
+  // Full comment threads loaded page by page, replacing the preview of their post
+  const [threads, setThreads] = useState<Record<string, { comments: Comment[]; nextCursor: string | null }>>({});
   const [image, setImage] = useState<File | null>(null);
   const [imagePreview, setImagePreview] = useState<string | null>(null);
   const [privacy, setPrivacy] = useState("public");
@@ -103,18 +109,27 @@ export function Feed({
 
   const fetchPosts = async () => {
     try {
-      const res = await fetch(postsUrl, {
-        method: "GET",
-        credentials: "include",
-        headers: { "Content-Type": "application/json" },
-      });
-      if (res.ok) {
-        const posts = await res.json();
-        setPosts(Array.isArray(posts) ? posts : []);
-      }
+      const page = await fetchPostsPage(postsUrl);
+      setPosts(page.posts);
+      setNextCursor(page.nextCursor);
     } catch (err) {
       console.error("Failed to fetch posts:", err);
       setPosts([]);
+      setNextCursor(null);
+    }
+  };
+
+  const loadMorePosts = async () => {
+    if (!nextCursor) return;
+    setLoadingMore(true);
+    try {
+      const page = await fetchPostsPage(postsUrl, nextCursor);
+      setPosts((prev) => [...prev, ...page.posts]);
+      setNextCursor(page.nextCursor);
+    } catch (err) {
+      console.error("Failed to fetch more posts:", err);
+    } finally {
+      setLoadingMore(false);
     }
   };
 
@@ -270,6 +285,11 @@ export function Feed({
       setCommentImage(prev => ({ ...prev, [postUUID]: null }));
       setCommentImagePreview(prev => ({ ...prev, [postUUID]: null }));
 
+      setThreads(prev => {
+        const next = { ...prev };
+        delete next[postUUID];
+        return next;
+      });
       await fetchPosts();
     } catch (err) {
       alert("Error creating comment: " + (err instanceof Error ? err.message : String(err)));
@@ -280,6 +300,28 @@ export function Feed({
     setExpandedPostUUID(expandedPostUUID === postUUID ? null : postUUID);
   };
 
+  const loadMoreComments = async (postUUID: string) => {
+    try {
+      const thread = threads[postUUID];
+      const page = await fetchCommentsPage(postUUID, thread?.nextCursor);
+      setThreads(prev => ({
+        ...prev,
+        [postUUID]: {
+          comments: thread ? [...thread.comments, ...page.comments] : page.comments,
+          nextCursor: page.nextCursor,
+        },
+      }));
+    } catch (err) {
+      console.error("Failed to fetch comments:", err);
+    }
+  };
+
+  const shownComments = (post: Post) => threads[post.post_uuid]?.comments ?? post.comments ?? [];
+  const hasMoreComments = (post: Post) => {
+    const thread = threads[post.post_uuid];
+    return thread ? thread.nextCursor !== null : (post.comment_count || 0) > (post.comments?.length || 0);
+  };
+
   if (!currentUser) {
     return (
       <div className="text-center py-10">
@@ -437,7 +479,7 @@ export function Feed({
                 <div className="flex items-center justify-between pt-2">
                   <Button variant="ghost" size="sm" onClick={() => toggleComments(post.post_uuid)}>
                     <MessageCircle className="h-4 w-4 mr-2" />
-                    {post.comments?.length || 0}
+                    {post.comment_count || 0}
                   </Button>
                 </div>
 
@@ -505,10 +547,10 @@ export function Feed({
 
                     {/* Comments List */}
                     <div className="space-y-3">
-                      {!post.comments?.length ? (
+                      {!shownComments(post).length ? (
                         <p className="text-center text-muted-foreground py-4">No comments yet.</p>
                       ) : (
-                        post.comments.map((comment) => (
+                        shownComments(post).map((comment) => (
                           <Card key={comment.comment_id} className="p-3">
                             <div className="flex items-center gap-2 mb-2">
                               <UserAvatar user={comment} size="sm" />
@@ -534,6 +576,13 @@ export function Feed({
                           </Card>
                         ))
                       )}
+                      {hasMoreComments(post) && (
+                        <div className="flex justify-center">
+                          <Button variant="ghost" size="sm" onClick={() => loadMoreComments(post.post_uuid)}>
+                            Show older comments
+                          </Button>
+                        </div>
+                      )}
                     </div>
                   </div>
                 )}
@@ -541,6 +590,13 @@ export function Feed({
             </Card>
           ))
         )}
+        {nextCursor && (
+          <div className="flex justify-center">
+            <Button variant="outline" onClick={loadMorePosts} disabled={loadingMore}>
+              {loadingMore ? "Loading..." : "Load more"}
+            </Button>
+          </div>
+        )}
       </div>
     </div>
   );
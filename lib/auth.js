@@ -1,5 +1,16 @@
 const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080";
 
+// Turns an API error response into an Error that keeps the error code and the
+// field errors, so forms can show each problem next to its field
+const apiError = (data, fallback) => {
+  const error = new Error((data && data.message) || (typeof data === 'string' && data) || fallback);
+  if (data && typeof data === 'object') {
+    error.code = data.code;
+    error.fields = data.fields || [];
+  }
+  return error;
+};
+
 export const registerUser = async (formData) => {
   const response = await fetch(`${API_URL}/api/register`, {
     method: 'POST',
@@ -13,11 +24,8 @@ export const registerUser = async (formData) => {
   } catch {
     data = text;
   }
-  if (!response.ok) {
-    throw new Error((data && data.message) || data || 'Registration failed');
-  }
-  if (data && data.success === false) {
-    throw new Error(data.message || 'Registration failed');
+  if (!response.ok || (data && data.success === false)) {
+    throw apiError(data, 'Registration failed');
   }
   return data;
 };
@@ -38,11 +46,8 @@ export const loginUser = async ({ email, password }) => {
   } catch (e) {
     throw new Error('Invalid email or password');
   }
-  if (!response.ok) {
-    throw new Error((data && data.message) || text || 'Login failed');
-  }
-  if (!data.success) {
-    throw new Error(data.message || 'Login failed');
+  if (!response.ok || !data.success) {
+    throw apiError(data, 'Login failed');
   }
   return data.user;
 };
@@ -0,0 +1,32 @@
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080";
+
+// Post listings come a page at a time: { posts, next_cursor }. next_cursor is
+// null on the last page.
+export const fetchPostsPage = async (url, cursor) => {
+  const pageUrl = cursor ? `${url}${url.includes('?') ? '&' : '?'}cursor=${encodeURIComponent(cursor)}` : url;
+  const response = await fetch(pageUrl, {
+    method: 'GET',
+    headers: { 'Content-Type': 'application/json' },
+    credentials: 'include',
+  });
+  const data = await response.json();
+  if (!response.ok || !data.success) {
+    throw new Error(data.message || 'Failed to load posts');
+  }
+  return { posts: data.posts || [], nextCursor: data.next_cursor || null };
+};
+
+// The full comment thread of a post, newest first: { comments, next_cursor }
+export const fetchCommentsPage = async (postUUID, cursor) => {
+  const url = `${API_URL}/api/v1/posts/${postUUID}/comments`;
+  const response = await fetch(cursor ? `${url}?cursor=${encodeURIComponent(cursor)}` : url, {
+    method: 'GET',
+    headers: { 'Content-Type': 'application/json' },
+    credentials: 'include',
+  });
+  const data = await response.json();
+  if (!response.ok || !data.success) {
+    throw new Error(data.message || 'Failed to load comments');
+  }
+  return { comments: data.comments || [], nextCursor: data.next_cursor || null };
+};